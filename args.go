@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
@@ -16,6 +18,7 @@ var connectAddress string
 var username string
 var password string
 var civAddress byte
+var civAddressSet bool
 var serialTCPPort uint16
 var enableSerialDevice bool
 var rigctldPort uint16
@@ -23,8 +26,73 @@ var runCmd string
 var runCmdOnSerialPortCreated string
 var statusLogInterval time.Duration
 var setDataModeOnTx bool
+var httpPort uint16
+var httpAddress string
+var httpToken string
+var enableScope bool
+var httpTLSCertFile string
+var httpTLSKeyFile string
+var mqttBroker string
+var mqttUsername string
+var mqttPassword string
+var mqttTopicPrefix string
+var logFormat string
+var logFile string
+var logFileMaxSizeMB uint
+var logFileMaxBackups uint
+var logFileRotateInterval time.Duration
+var logLevels string
+var civTraceEnabled bool
+var configFile string
+var daemonMode bool
+var daemonStatusInterval time.Duration
+var reconnectInitialDelay time.Duration
+var reconnectMaxDelay time.Duration
+var reconnectJitterPercent uint
+var reconnectGiveUpAfter uint
+var waitIfBusy bool
+var listenOnly bool
+var txSegments []txPolicySegment
+var txTimeout time.Duration
+var txCooldown time.Duration
+var civRules []civRule
+var pttPriority []civSource
+var pttPreemption bool
+var arpMenuItem uint16
+
+var errArgsUsage = errors.New("invalid arguments")
+
+// Reads options from the config file. Each line contains a long option name and its value separated
+// by a space or an equal sign, for example "address 192.168.1.2". Lines starting with # are ignored.
+func readConfigFile(filename string) (args []string, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		l := strings.TrimSpace(scanner.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		i := strings.IndexAny(l, " \t=")
+		if i < 0 {
+			args = append(args, "--"+l)
+			continue
+		}
+		v := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(l[i:]), "="))
+		args = append(args, "--"+l[:i]+"="+v)
+	}
+	return args, scanner.Err()
+}
+
+// Parses the config file and the command line, command line options override the config file options.
+// All options are set again on each call, so this is also used when reloading the config.
+func loadArgs() error {
+	getopt.CommandLine = getopt.New()
 
-func parseArgs() {
 	h := getopt.BoolLong("help", 'h', "display help")
 	v := getopt.BoolLong("verbose", 'v', "Enable verbose (debug) logging")
 	q := getopt.BoolLong("quiet", 'q', "Disable logging")
@@ -39,13 +107,91 @@ func parseArgs() {
 	o := getopt.StringLong("exec-serial", 'o', "socat /tmp/kappanhang-IC-705.pty /tmp/vmware.pty", "Exec cmd when virtual serial port is created, set to - to disable")
 	i := getopt.Uint16Long("log-interval", 'i', 100, "Status bar/log interval in milliseconds")
 	d := getopt.BoolLong("set-data-tx", 'd', "Automatically enable data mode on TX")
+	httpPortArg := getopt.Uint16Long("http-port", 0, 0, "Start the HTTP/WebSocket server on this TCP port, 0 disables it")
+	httpAddressArg := getopt.StringLong("http-address", 0, "127.0.0.1", "Listen on this address with the HTTP server, use 0.0.0.0 for all interfaces")
+	httpTokenArg := getopt.StringLong("http-token", 0, "", "Token needed for accessing the HTTP server, needed if not listening on localhost")
+	scopeArg := getopt.BoolLong("scope", 0, "Enable the radio's scope on connect")
+	httpTLSCertArg := getopt.StringLong("http-tls-cert", 0, "", "Serve HTTPS using this certificate file (needed for browser mic access)")
+	httpTLSKeyArg := getopt.StringLong("http-tls-key", 0, "", "Private key file for the HTTPS certificate")
+	mqttBrokerArg := getopt.StringLong("mqtt-broker", 0, "", "Connect to this MQTT broker, for example tcp://localhost:1883")
+	mqttUsernameArg := getopt.StringLong("mqtt-username", 0, "", "MQTT username")
+	mqttPasswordArg := getopt.StringLong("mqtt-password", 0, "", "MQTT password")
+	mqttTopicPrefixArg := getopt.StringLong("mqtt-topic-prefix", 0, "kappanhang", "MQTT topic prefix")
+	logFormatArg := getopt.StringLong("log-format", 0, "console", "Log format: console or json")
+	logFileArg := getopt.StringLong("log-file", 0, "", "Also write the log to this file")
+	logFileMaxSizeArg := getopt.UintLong("log-file-max-size", 0, 10, "Rotate the log file when it reaches this size in megabytes, must be at least 1")
+	logFileMaxBackupsArg := getopt.UintLong("log-file-max-backups", 0, 5, "Number of rotated log files to keep")
+	logFileRotateArg := getopt.DurationLong("log-file-rotate", 0, 0, "Also rotate the log file in this interval (for example 24h), 0 disables it")
+	logLevelsArg := getopt.StringLong("log-levels", 0, "", "Per-subsystem log levels, for example seqbuf=debug,rigctld=error")
+	civTraceArg := getopt.BoolLong("civ-trace", 0, "Log all CI-V frames with their source and decoded command")
+	configArg := getopt.StringLong("config", 0, "", "Read options from this file, reloaded on SIGHUP")
+	daemonArg := getopt.BoolLong("daemon", 0, "Run as a service: no keyboard input and status bar, only periodic status logs")
+	daemonStatusIntervalArg := getopt.DurationLong("daemon-status-interval", 0, time.Minute, "Status log interval in daemon mode")
+	reconnectInitialDelayArg := getopt.DurationLong("reconnect-initial-delay", 0, time.Second, "Delay before the first reconnect attempt")
+	reconnectMaxDelayArg := getopt.DurationLong("reconnect-max-delay", 0, 65*time.Second, "The reconnect delay is doubled on each failed attempt up to this value")
+	reconnectJitterArg := getopt.UintLong("reconnect-jitter", 0, 10, "Randomize reconnect delays by this percent")
+	reconnectGiveUpArg := getopt.UintLong("reconnect-give-up", 0, 0, "Exit after this many failed reconnect attempts, 0 retries forever")
+	waitIfBusyArg := getopt.BoolLong("wait-if-busy", 0, "If the radio is in use by another computer, keep requesting the streams instead of reconnecting")
+	listenOnlyArg := getopt.BoolLong("listen-only", 0, "Request RX audio only, refuse PTT, tune, power and frequency changes")
+	txSegmentsArg := getopt.StringLong("tx-segments", 0, "", "Only allow TX in these segments, for example 3500000-3800000:50,7000000-7200000 (Hz, optional max power %)")
+	txTimeoutArg := getopt.DurationLong("tx-timeout", 0, 3*time.Minute, "Release PTT after this time")
+	txCooldownArg := getopt.DurationLong("tx-cooldown", 0, 0, "Minimum time between transmissions")
+	pttPriorityArg := getopt.StringLong("ptt-priority", 0, "hotkey,api,rigctld,tcp,pty", "PTT owners in decreasing priority")
+	pttNoPreemptArg := getopt.BoolLong("ptt-no-preempt", 0, "Don't let higher priority sources take over the PTT")
+	arpMenuItemArg := getopt.StringLong("arp-menu-item", 0, "", "Menu item number of the radio's auto repeater setting, for example 0123")
+	civRulesArg := getopt.StringLong("civ-rules", 0, "", "CI-V firewall rules for serial clients, for example allow:tcp:*:read,deny:tcp:*")
 
-	getopt.Parse()
+	if err := getopt.CommandLine.Getopt(os.Args, nil); err != nil {
+		return err
+	}
+	if *configArg != "" {
+		configArgs, err := readConfigFile(*configArg)
+		if err != nil {
+			return err
+		}
+		// Parsing the command line again so it overrides the config file.
+		args := append([]string{os.Args[0]}, configArgs...)
+		args = append(args, os.Args[1:]...)
+		if err := getopt.CommandLine.Getopt(args, nil); err != nil {
+			return err
+		}
+	}
 
-	if *h || *a == "" || (*q && *v) {
-		fmt.Println(getAboutStr())
-		getopt.Usage()
-		os.Exit(1)
+	if *h || *a == "" || (*q && *v) || ((*httpTLSCertArg == "") != (*httpTLSKeyArg == "")) ||
+		(*logFormatArg != "console" && *logFormatArg != "json") ||
+		*reconnectInitialDelayArg <= 0 || *reconnectMaxDelayArg < *reconnectInitialDelayArg || *reconnectJitterArg > 100 ||
+		*txTimeoutArg <= 0 || *txCooldownArg < 0 || *logFileMaxSizeArg == 0 {
+		return errArgsUsage
+	}
+
+	*c = strings.Replace(*c, "0x", "", -1)
+	*c = strings.Replace(*c, "0X", "", -1)
+	civAddressInt, err := strconv.ParseInt(*c, 16, 64)
+	if err != nil {
+		return errors.New("invalid CI-V address: can't parse " + *c)
+	}
+	txSegmentsParsed, err := parseTxPolicySegments(*txSegmentsArg)
+	if err != nil {
+		return err
+	}
+	civRulesParsed, err := parseCIVRules(*civRulesArg)
+	if err != nil {
+		return err
+	}
+	pttPriorityParsed, err := parsePTTPriority(*pttPriorityArg)
+	if err != nil {
+		return err
+	}
+	if *httpPortArg != 0 && *httpTokenArg == "" && !isLoopbackAddress(*httpAddressArg) {
+		return errors.New("--http-token is needed if the HTTP server is not listening on localhost")
+	}
+	var arpMenuItemParsed uint64
+	if *arpMenuItemArg != "" {
+		// The menu item number is sent as BCD, so it is parsed as a hex number.
+		arpMenuItemParsed, err = strconv.ParseUint(strings.TrimPrefix(*arpMenuItemArg, "0x"), 16, 16)
+		if err != nil {
+			return errors.New("invalid auto repeater menu item: can't parse " + *arpMenuItemArg)
+		}
 	}
 
 	verboseLog = *v
@@ -54,15 +200,8 @@ func parseArgs() {
 	username = *u
 	password = *p
 
-	*c = strings.Replace(*c, "0x", "", -1)
-	*c = strings.Replace(*c, "0X", "", -1)
-	civAddressInt, err := strconv.ParseInt(*c, 16, 64)
-	if err != nil {
-		fmt.Println("invalid CI-V address: can't parse", *c)
-		os.Exit(1)
-	}
 	civAddress = byte(civAddressInt)
-
+	civAddressSet = getopt.IsSet("civ-address")
 	serialTCPPort = *t
 	enableSerialDevice = *s
 	rigctldPort = *r
@@ -70,4 +209,49 @@ func parseArgs() {
 	runCmdOnSerialPortCreated = *o
 	statusLogInterval = time.Duration(*i) * time.Millisecond
 	setDataModeOnTx = *d
+	httpPort = *httpPortArg
+	httpAddress = *httpAddressArg
+	httpToken = *httpTokenArg
+	enableScope = *scopeArg
+	httpTLSCertFile = *httpTLSCertArg
+	httpTLSKeyFile = *httpTLSKeyArg
+	mqttBroker = *mqttBrokerArg
+	mqttUsername = *mqttUsernameArg
+	mqttPassword = *mqttPasswordArg
+	mqttTopicPrefix = *mqttTopicPrefixArg
+	logFormat = *logFormatArg
+	logFile = *logFileArg
+	logFileMaxSizeMB = *logFileMaxSizeArg
+	logFileMaxBackups = *logFileMaxBackupsArg
+	logFileRotateInterval = *logFileRotateArg
+	logLevels = *logLevelsArg
+	civTraceEnabled = *civTraceArg
+	configFile = *configArg
+	daemonMode = *daemonArg
+	daemonStatusInterval = *daemonStatusIntervalArg
+	reconnectInitialDelay = *reconnectInitialDelayArg
+	reconnectMaxDelay = *reconnectMaxDelayArg
+	reconnectJitterPercent = *reconnectJitterArg
+	reconnectGiveUpAfter = *reconnectGiveUpArg
+	waitIfBusy = *waitIfBusyArg
+	listenOnly = *listenOnlyArg
+	txSegments = txSegmentsParsed
+	txTimeout = *txTimeoutArg
+	txCooldown = *txCooldownArg
+	civRules = civRulesParsed
+	pttPriority = pttPriorityParsed
+	pttPreemption = !*pttNoPreemptArg
+	arpMenuItem = uint16(arpMenuItemParsed)
+	return nil
+}
+
+func parseArgs() {
+	if err := loadArgs(); err != nil {
+		fmt.Println(getAboutStr())
+		if err != errArgsUsage {
+			fmt.Println(err)
+		}
+		getopt.Usage()
+		os.Exit(1)
+	}
 }
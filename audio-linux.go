@@ -86,6 +86,11 @@ func (a *audioStruct) togglePlaybackToDefaultSoundcard() {
 }
 
 func (a *audioStruct) toggleRecFromDefaultSoundcard() {
+	if listenOnly {
+		log.Error("can't turn on rec: ", errListenOnly)
+		return
+	}
+
 	if a.defaultSoundcardStream.recStream == nil {
 		ss := pulse.SampleSpec{Format: pulse.SAMPLE_S16LE, Rate: audioSampleRate, Channels: 1}
 		battr := pulse.NewBufferAttr()
@@ -101,12 +106,15 @@ func (a *audioStruct) toggleRecFromDefaultSoundcard() {
 			statusLog.reportAudioRec(true)
 
 			if setDataModeOnTx {
-				if err := civControl.setDataMode(true); err != nil {
+				if err := civControl.setDataMode(civSourceInternal, true); err != nil {
 					log.Error("can't enable data mode: ", err)
 				}
 			}
-			if err := civControl.setPTT(true); err != nil {
+			if err := pttArbiter.requestPTT(civSourceHotkey, true); err != nil {
 				log.Error("can't turn on ptt: ", err)
+				a.defaultSoundCardRecStreamDeinit()
+				statusLog.reportAudioRec(false)
+				log.Print("turned off audio rec")
 			}
 		} else {
 			log.Error("can't turn on rec: ", err)
@@ -116,7 +124,7 @@ func (a *audioStruct) toggleRecFromDefaultSoundcard() {
 		a.defaultSoundCardRecStreamDeinit()
 		statusLog.reportAudioRec(false)
 		log.Print("turned off audio rec")
-		if err := civControl.setPTT(false); err != nil {
+		if err := pttArbiter.requestPTT(civSourceHotkey, false); err != nil {
 			log.Error("can't turn off ptt: ", err)
 		}
 	}
@@ -217,6 +225,9 @@ func (a *audioStruct) recLoopFromDefaultSoundcard() {
 			if n != len(frameBuf) {
 				reportError(errors.New("audio buffer read error"))
 			}
+			if !pttArbiter.isAudioTxRouteActive(audioTxRouteDefaultSoundcard) {
+				continue
+			}
 
 			select {
 			case a.rec <- b:
@@ -311,6 +322,9 @@ func (a *audioStruct) recLoopFromVirtualSoundcard(deinitNeededChan, deinitFinish
 			if n != len(frameBuf) {
 				reportError(errors.New("audio buffer read error"))
 			}
+			if !pttArbiter.isAudioTxRouteActive(audioTxRouteVirtualSoundcard) {
+				continue
+			}
 
 			select {
 			case a.rec <- b:
@@ -331,7 +345,10 @@ func (a *audioStruct) loop() {
 
 	recLoopFromVirtualSoundcardDeinitNeededChan := make(chan bool)
 	recLoopFromVirtualSoundcardDeinitFinishedChan := make(chan bool)
-	go a.recLoopFromVirtualSoundcard(recLoopFromVirtualSoundcardDeinitNeededChan, recLoopFromVirtualSoundcardDeinitFinishedChan)
+	recLoopFromVirtualSoundcardStarted := a.virtualSoundcardStream.sink.IsOpen()
+	if recLoopFromVirtualSoundcardStarted {
+		go a.recLoopFromVirtualSoundcard(recLoopFromVirtualSoundcardDeinitNeededChan, recLoopFromVirtualSoundcardDeinitFinishedChan)
+	}
 
 	var d []byte
 	for {
@@ -340,8 +357,10 @@ func (a *audioStruct) loop() {
 		case <-a.deinitNeededChan:
 			a.closeIfNeeded()
 
-			recLoopFromVirtualSoundcardDeinitNeededChan <- true
-			<-recLoopFromVirtualSoundcardDeinitFinishedChan
+			if recLoopFromVirtualSoundcardStarted {
+				recLoopFromVirtualSoundcardDeinitNeededChan <- true
+				<-recLoopFromVirtualSoundcardDeinitFinishedChan
+			}
 			playLoopToVirtualSoundcardDeinitNeededChan <- true
 			<-playLoopToVirtualSoundcardDeinitFinishedChan
 
@@ -420,7 +439,8 @@ func (a *audioStruct) initIfNeeded(devName string) error {
 		}
 	}
 
-	if !a.virtualSoundcardStream.sink.IsOpen() {
+	// No TX sink is opened in listen-only mode.
+	if !listenOnly && !a.virtualSoundcardStream.sink.IsOpen() {
 		a.virtualSoundcardStream.sink.Name = "kappanhang-" + a.devName
 		a.virtualSoundcardStream.sink.Filename = "/tmp/kappanhang-" + a.devName + ".sink"
 		a.virtualSoundcardStream.sink.Rate = audioSampleRate
@@ -3,7 +3,6 @@ package main
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"time"
 )
@@ -62,7 +61,7 @@ func (s *audioStream) handleRxSeqBufEntry(e seqBufEntry) {
 	if s.receivedAudio {
 		// Out of order packets can happen if we receive a retransmitted packet, but too late.
 		if s.rxSeqBuf.compareSeq(e.seq, seqNum(s.lastReceivedSeq)) != larger {
-			log.Debug("got out of order pkt seq #", e.seq)
+			log.Debugw("got out of order pkt", "stream", "audio", "seq", e.seq)
 			return
 		}
 
@@ -74,8 +73,9 @@ func (s *audioStream) handleRxSeqBufEntry(e seqBufEntry) {
 			} else {
 				missingPkts = int(gotSeq) + 65536 - int(expectedSeq)
 			}
-			netstat.reportLoss(missingPkts)
-			log.Error("lost ", missingPkts, " audio packets")
+			netstat.reportLoss("audio", missingPkts)
+			log.Errorw("lost packets", "stream", "audio", "count", missingPkts)
+			eventBus.publishEvent("loss", map[string]interface{}{"stream": "audio", "pkts": missingPkts})
 			s.serverAudioTime = s.serverAudioTime.Add(time.Duration(10*missingPkts) * time.Millisecond)
 		}
 		s.serverAudioTime = s.serverAudioTime.Add(10 * time.Millisecond)
@@ -86,6 +86,7 @@ func (s *audioStream) handleRxSeqBufEntry(e seqBufEntry) {
 	s.receivedAudio = true
 
 	audio.play <- e.data
+	webSrv.broadcastAudio(e.data)
 }
 
 // var drop int
@@ -130,11 +131,14 @@ func (s *audioStream) loop() {
 				reportError(err)
 			}
 		case <-s.timeoutTimer.C:
-			reportError(errors.New(fmt.Sprint("audio stream timeout after ",
+			reportError(newConnError(connErrorAudioTimeout, fmt.Sprint("audio stream timeout after ",
 				time.Since(statusLog.data.startTime), ", try rebooting the radio")))
 		case e := <-s.rxSeqBufEntryChan:
 			s.handleRxSeqBufEntry(e)
 		case d := <-audio.rec:
+			if listenOnly { // The TX sink can still be open if listen-only mode was enabled on reload.
+				break
+			}
 			if err := s.sendPart1(d[:1364]); err != nil {
 				reportError(err)
 			}
@@ -168,7 +172,7 @@ func (s *audioStream) init(devName string) error {
 	log.Print("stream started")
 
 	s.rxSeqBufEntryChan = make(chan seqBufEntry)
-	s.rxSeqBuf.init(audioRxSeqBufLength, 0xffff, 0, s.rxSeqBufEntryChan, s.common.requestRetransmit)
+	s.rxSeqBuf.init("audio", audioRxSeqBufLength, 0xffff, 0, s.rxSeqBufEntryChan, s.common.requestRetransmit)
 
 	s.timeoutTimer = time.NewTimer(audioTimeoutDuration)
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"sync"
@@ -9,7 +10,9 @@ import (
 
 const statusPollInterval = time.Second
 const commandRetryTimeout = 500 * time.Millisecond
-const pttTimeout = 3 * time.Minute
+
+// If the radio sends transceive broadcasts, the selected VFO's frequency is only polled in this interval.
+const transceivePollInterval = 10 * time.Second
 const tuneTimeout = 30 * time.Second
 
 // Commands reference: https://www.icomeurope.com/wp-content/uploads/2020/08/IC-705_ENG_CI-V_1_20200721.pdf
@@ -19,19 +22,6 @@ type civOperatingMode struct {
 	code byte
 }
 
-var civOperatingModes = []civOperatingMode{
-	{name: "LSB", code: 0x00},
-	{name: "USB", code: 0x01},
-	{name: "AM", code: 0x02},
-	{name: "CW", code: 0x03},
-	{name: "RTTY", code: 0x04},
-	{name: "FM", code: 0x05},
-	{name: "WFM", code: 0x06},
-	{name: "CW-R", code: 0x07},
-	{name: "RTTY-R", code: 0x08},
-	{name: "DV", code: 0x17},
-}
-
 type civFilter struct {
 	name string
 	code byte
@@ -49,23 +39,43 @@ type civBand struct {
 	freq     uint
 }
 
-var civBands = []civBand{
-	{freqFrom: 1800000, freqTo: 1999999},     // 1.9
-	{freqFrom: 3400000, freqTo: 4099999},     // 3.5
-	{freqFrom: 6900000, freqTo: 7499999},     // 7
-	{freqFrom: 9900000, freqTo: 10499999},    // 10
-	{freqFrom: 13900000, freqTo: 14499999},   // 14
-	{freqFrom: 17900000, freqTo: 18499999},   // 18
-	{freqFrom: 20900000, freqTo: 21499999},   // 21
-	{freqFrom: 24400000, freqTo: 25099999},   // 24
-	{freqFrom: 28000000, freqTo: 29999999},   // 28
-	{freqFrom: 50000000, freqTo: 54000000},   // 50
-	{freqFrom: 74800000, freqTo: 107999999},  // WFM
-	{freqFrom: 108000000, freqTo: 136999999}, // AIR
-	{freqFrom: 144000000, freqTo: 148000000}, // 144
-	{freqFrom: 420000000, freqTo: 450000000}, // 430
-	{freqFrom: 0, freqTo: 0},                 // GENE
-}
+// The index is the CI-V code of the tuning step.
+var civTuningSteps = []uint{1, 100, 500, 1000, 5000, 6250, 8330, 9000, 10000, 12500, 20000, 25000, 50000, 100000}
+
+// CTCSS tones in 0.1 Hz.
+var civCTCSSTones = []int{670, 693, 719, 744, 770, 797, 825, 854, 885, 915, 948, 974, 1000, 1035, 1072, 1109,
+	1148, 1188, 1230, 1273, 1318, 1365, 1413, 1462, 1514, 1567, 1598, 1622, 1655, 1679, 1713, 1738, 1773, 1799,
+	1835, 1862, 1899, 1928, 1966, 1995, 2035, 2065, 2107, 2181, 2257, 2291, 2336, 2418, 2503, 2541}
+
+// DCS codes, these are octal numbers written in decimal.
+var civDCSCodes = []int{23, 25, 26, 31, 32, 36, 43, 47, 51, 53, 54, 65, 71, 72, 73, 74, 114, 115, 116, 122, 125,
+	131, 132, 134, 143, 145, 152, 155, 156, 162, 165, 172, 174, 205, 212, 223, 225, 226, 243, 244, 245, 246, 251,
+	252, 255, 261, 263, 265, 266, 271, 274, 306, 311, 315, 325, 331, 332, 343, 346, 351, 356, 364, 365, 371, 411,
+	412, 413, 423, 431, 432, 445, 446, 452, 454, 455, 462, 464, 465, 466, 503, 506, 516, 523, 526, 532, 546, 565,
+	606, 612, 624, 627, 631, 632, 654, 662, 664, 703, 712, 723, 731, 732, 734, 743, 754}
+
+// Common repeater offsets in Hz.
+var civRepeaterOffsets = []uint{100000, 500000, 600000, 1600000, 5000000, 7600000}
+
+// The index is the CI-V code of the tone squelch function.
+var civToneModeNames = []string{"OFF", "TONE", "TSQL", "DTCS"}
+
+const (
+	civToneModeOff = iota
+	civToneModeTone
+	civToneModeTSQL
+	civToneModeDTCS
+)
+
+var civARPNames = []string{"OFF", "ON-1", "ON-2"}
+
+const civMaxRITOffset = 9999
+const civRITStep = 10
+
+// Twin PBT positions are sent as 0-255, 128 is the center.
+const civPBTHzPerStep = 25
+const civPBTStep = 50
+const civMaxPBT = 127 * civPBTHzPerStep
 
 type splitMode int
 
@@ -76,69 +86,37 @@ const (
 	splitModeDUPPlus
 )
 
-type civCmd struct {
-	pending bool
-	sentAt  time.Time
-	name    string
-	cmd     []byte
-}
-
 type civControlStruct struct {
-	st                 *serialStream
-	deinitNeeded       chan bool
-	deinitFinished     chan bool
-	resetSReadTimer    chan bool
-	newPendingCmdAdded chan bool
+	st              *serialStream
+	deinitNeeded    chan bool
+	deinitFinished  chan bool
+	resetSReadTimer chan bool
 
 	state struct {
-		mutex       sync.Mutex
-		pendingCmds []*civCmd
-
-		getPwr            civCmd
-		getS              civCmd
-		getOVF            civCmd
-		getSWR            civCmd
-		getTransmitStatus civCmd
-		getPreamp         civCmd
-		getAGC            civCmd
-		getTuneStatus     civCmd
-		getVd             civCmd
-		getTS             civCmd
-		getRFGain         civCmd
-		getSQL            civCmd
-		getNR             civCmd
-		getNREnabled      civCmd
-		getSplit          civCmd
-		getMainVFOFreq    civCmd
-		getSubVFOFreq     civCmd
-		getMainVFOMode    civCmd
-		getSubVFOMode     civCmd
+		mutex sync.Mutex
+
+		// The last meter and frequency polls, these are only polled again if the previous poll has finished.
+		pollS           *civFuture
+		pollOVF         *civFuture
+		pollSWR         *civFuture
+		pollMainVFOFreq *civFuture
+		pollSubVFOFreq  *civFuture
+		pollPo          *civFuture
+		pollALC         *civFuture
+		pollComp        *civFuture
+		pollId          *civFuture
 
 		lastSReceivedAt       time.Time
 		lastOVFReceivedAt     time.Time
 		lastSWRReceivedAt     time.Time
 		lastVFOFreqReceivedAt time.Time
 
-		setPwr         civCmd
-		setRFGain      civCmd
-		setSQL         civCmd
-		setNR          civCmd
-		setMainVFOFreq civCmd
-		setSubVFOFreq  civCmd
-		setMode        civCmd
-		setSubVFOMode  civCmd
-		setPTT         civCmd
-		setTune        civCmd
-		setDataMode    civCmd
-		setPreamp      civCmd
-		setAGC         civCmd
-		setNREnabled   civCmd
-		setTS          civCmd
-		setVFO         civCmd
-		setSplit       civCmd
+		// True if we've received a transceive broadcast from the radio since we've connected.
+		transceiveActive bool
 
 		pttTimeoutTimer  *time.Timer
 		tuneTimeoutTimer *time.Timer
+		tuneStartedAt    time.Time
 
 		freq                uint
 		subFreq             uint
@@ -158,10 +136,49 @@ type civControlStruct struct {
 		bandIdx             int
 		preamp              int
 		agc                 int
+		atuEnabled          bool
+		antenna             int // 0 is ANT1.
+		offset              uint
+		toneMode            int
+		tone                int // 0.1 Hz
+		tsqlTone            int // 0.1 Hz
+		dcsCode             int
+		arp                 int
+		ritOffset           int // Hz, shared by RIT and XIT (delta TX).
+		ritEnabled          bool
+		xitEnabled          bool
+		pbtIn               int // Hz
+		pbtOut              int // Hz
+		att                 int // dB
+		nbEnabled           bool
+		anfEnabled          bool
+		mnEnabled           bool
+		compEnabled         bool
+		monEnabled          bool
+		voxEnabled          bool
+		nbPercent           int
+		compPercent         int
+		monPercent          int
+		voxPercent          int
 		tsValue             byte
 		ts                  uint
 		vfoBActive          bool
 		splitMode           splitMode
+		sValue              string
+		sDB                 float64 // Relative to S9.
+		sDBm                float64
+		ovf                 bool
+		swr                 float64
+		vd                  float64
+		poWatts             float64
+		alc                 float64
+		comp                float64
+		id                  float64
+
+		// This is only set if we've enabled the scope, as the waveform data is filtered in this case.
+		scopeEnabled    bool
+		scopeCenterMode bool
+		scopeSpan       uint
 	}
 }
 
@@ -169,26 +186,38 @@ var civControl civControlStruct
 
 // Returns false if the message should not be forwarded to the serial port TCP server or the virtual serial port.
 func (s *civControlStruct) decode(d []byte) bool {
-	if len(d) < 6 || d[0] != 0xfe || d[1] != 0xfe || d[len(d)-1] != 0xfd {
+	f, ok := parseCIVFrame(d)
+	if !ok {
 		return true
 	}
-
-	payload := d[5 : len(d)-1]
+	payload := f.payload
 
 	s.state.mutex.Lock()
 	defer s.state.mutex.Unlock()
 
-	switch d[4] {
-	// case 0x00:
-	// 	return s.decodeFreq(payload)
+	if f.to == civBroadcastAddress && !f.isFromController() {
+		s.decodeTransceiveBroadcast()
+	}
+
+	forward := s.decodeCmd(f.cmd, payload)
+	// Echoes of and replies to requests sent through the queue are not forwarded.
+	if civQueue.handleReply(&f, d) {
+		return false
+	}
+	return forward
+}
+
+// Should be called with the mutex locked.
+func (s *civControlStruct) decodeCmd(cmd byte, payload []byte) bool {
+	switch cmd {
+	case 0x00:
+		return s.decodeFreq(payload)
 	case 0x01:
 		return s.decodeMode(payload)
-	// case 0x03:
-	// 	return s.decodeFreq(payload)
+	case 0x03:
+		return s.decodeFreq(payload)
 	case 0x04:
 		return s.decodeMode(payload)
-	// case 0x05:
-	// 	return s.decodeFreq(payload)
 	case 0x06:
 		return s.decodeMode(payload)
 	case 0x07:
@@ -207,14 +236,44 @@ func (s *civControlStruct) decode(d []byte) bool {
 		return s.decodeVdSWRS(payload)
 	case 0x16:
 		return s.decodePreampAGCNREnabled(payload)
+	case 0x12:
+		return s.decodeAntenna(payload)
+	case 0x0c:
+		return s.decodeOffset(payload)
+	case 0x1b:
+		return s.decodeTone(payload)
+	case 0x21:
+		return s.decodeRIT(payload)
+	case 0x11:
+		return s.decodeATT(payload)
 	case 0x25:
 		return s.decodeVFOFreq(payload)
 	case 0x26:
 		return s.decodeVFOMode(payload)
+	case 0x27:
+		return s.decodeScope(payload)
 	}
 	return true
 }
 
+// Decodes big endian BCD data, for example 0x08 0x85 is 885.
+func (s *civControlStruct) decodeBCD(d []byte) (v int) {
+	for _, b := range d {
+		v = v*100 + int(b>>4)*10 + int(b&0x0f)
+	}
+	return
+}
+
+// Encodes v as big endian BCD data with the given number of bytes.
+func (s *civControlStruct) encodeBCD(v int, n int) []byte {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte((v/10)%10)<<4 | byte(v%10)
+		v /= 100
+	}
+	return b
+}
+
 func (s *civControlStruct) decodeFreqData(d []byte) (f uint) {
 	var pos int
 	for _, v := range d {
@@ -228,33 +287,42 @@ func (s *civControlStruct) decodeFreqData(d []byte) (f uint) {
 	return
 }
 
-// func (s *civControlStruct) decodeFreq(d []byte) bool {
-// 	if len(d) < 2 {
-// 		return !s.state.getFreq.pending && !s.state.setMainVFOFreq.pending
-// 	}
-
-// 	s.state.freq = s.decodeFreqData(d)
-// 	statusLog.reportFrequency(s.state.freq)
-
-// 	s.state.bandIdx = len(civBands) - 1 // Set the band idx to GENE by default.
-// 	for i := range civBands {
-// 		if s.state.freq >= civBands[i].freqFrom && s.state.freq <= civBands[i].freqTo {
-// 			s.state.bandIdx = i
-// 			civBands[s.state.bandIdx].freq = s.state.freq
-// 			break
-// 		}
-// 	}
-
-// 	if s.state.getFreq.pending {
-// 		s.removePendingCmd(&s.state.getFreq)
-// 		return false
-// 	}
-// 	if s.state.setMainVFOFreq.pending {
-// 		s.removePendingCmd(&s.state.setMainVFOFreq)
-// 		return false
-// 	}
-// 	return true
-// }
+// Called when the radio sends a frame to the broadcast address, which means CI-V transceive is on.
+func (s *civControlStruct) decodeTransceiveBroadcast() {
+	if s.state.transceiveActive {
+		return
+	}
+	s.state.transceiveActive = true
+	log.Print("got CI-V transceive broadcast, reducing selected VFO frequency polling")
+	eventBus.publishState(eventBusState{"civTransceive": true})
+}
+
+// Sets the selected VFO's frequency and the current band.
+func (s *civControlStruct) updateFreq(f uint) {
+	s.state.freq = f
+	s.state.lastVFOFreqReceivedAt = time.Now()
+	statusLog.reportFrequency(s.state.freq)
+
+	s.state.bandIdx = len(activeRadioModel.bands) - 1 // Set the band idx to GENE by default.
+	for i := range activeRadioModel.bands {
+		if s.state.freq >= activeRadioModel.bands[i].freqFrom && s.state.freq <= activeRadioModel.bands[i].freqTo {
+			s.state.bandIdx = i
+			activeRadioModel.bands[s.state.bandIdx].freq = s.state.freq
+			break
+		}
+	}
+}
+
+// Decodes the frequency of the selected VFO. The radio sends this as a transceive broadcast (0x00) when the
+// frequency is changed on the radio, or as an answer to a frequency read (0x03).
+func (s *civControlStruct) decodeFreq(d []byte) bool {
+	if len(d) < 2 {
+		return true
+	}
+
+	s.updateFreq(s.decodeFreqData(d))
+	return true
+}
 
 func (s *civControlStruct) decodeFilterValueToFilterIdx(v byte) int {
 	for i := range civFilters {
@@ -267,11 +335,11 @@ func (s *civControlStruct) decodeFilterValueToFilterIdx(v byte) int {
 
 func (s *civControlStruct) decodeMode(d []byte) bool {
 	if len(d) < 1 {
-		return !s.state.setMode.pending
+		return true
 	}
 
-	for i := range civOperatingModes {
-		if civOperatingModes[i].code == d[0] {
+	for i := range activeRadioModel.modes {
+		if activeRadioModel.modes[i].code == d[0] {
 			s.state.operatingModeIdx = i
 			break
 		}
@@ -280,41 +348,26 @@ func (s *civControlStruct) decodeMode(d []byte) bool {
 	if len(d) > 1 {
 		s.state.filterIdx = s.decodeFilterValueToFilterIdx(d[1])
 	}
-	statusLog.reportMode(civOperatingModes[s.state.operatingModeIdx].name, s.state.dataMode,
+	statusLog.reportMode(activeRadioModel.modes[s.state.operatingModeIdx].name, s.state.dataMode,
 		civFilters[s.state.filterIdx].name)
 
-	if s.state.setMode.pending {
-		s.removePendingCmd(&s.state.setMode)
-		return false
-	}
 	return true
 }
 
 func (s *civControlStruct) decodeVFO(d []byte) bool {
 	if len(d) < 1 {
-		return !s.state.setVFO.pending
-	}
-
-	if d[0] == 1 {
-		s.state.vfoBActive = true
-		log.Print("active vfo: B")
-	} else {
-		s.state.vfoBActive = false
-		log.Print("active vfo: A")
+		return true
 	}
 
-	if s.state.setVFO.pending {
-		// The radio does not send frequencies automatically.
-		_ = s.getBothVFOFreq()
-		s.removePendingCmd(&s.state.setVFO)
-		return false
-	}
+	// Dual receiver radios use 0xd0 for main and 0xd1 for sub.
+	s.state.vfoBActive = d[0] == 1 || d[0] == 0xd1
+	log.Print("active vfo: ", activeRadioModel.getVFOName(s.state.vfoBActive))
 	return true
 }
 
 func (s *civControlStruct) decodeSplit(d []byte) bool {
 	if len(d) < 1 {
-		return !s.state.getSplit.pending && !s.state.setSplit.pending
+		return true
 	}
 
 	var str string
@@ -333,64 +386,23 @@ func (s *civControlStruct) decodeSplit(d []byte) bool {
 	}
 	statusLog.reportSplit(s.state.splitMode, str)
 
-	if s.state.getSplit.pending {
-		s.removePendingCmd(&s.state.getSplit)
-		return false
-	}
-	if s.state.setSplit.pending {
-		s.removePendingCmd(&s.state.setSplit)
-		return false
-	}
 	return true
 }
 
 func (s *civControlStruct) decodeTS(d []byte) bool {
 	if len(d) < 1 {
-		return !s.state.getTS.pending && !s.state.setTS.pending
+		return true
 	}
 
 	s.state.tsValue = d[0]
 
-	switch s.state.tsValue {
-	default:
-		s.state.ts = 1
-	case 1:
-		s.state.ts = 100
-	case 2:
-		s.state.ts = 500
-	case 3:
-		s.state.ts = 1000
-	case 4:
-		s.state.ts = 5000
-	case 5:
-		s.state.ts = 6250
-	case 6:
-		s.state.ts = 8330
-	case 7:
-		s.state.ts = 9000
-	case 8:
-		s.state.ts = 10000
-	case 9:
-		s.state.ts = 12500
-	case 10:
-		s.state.ts = 20000
-	case 11:
-		s.state.ts = 25000
-	case 12:
-		s.state.ts = 50000
-	case 13:
-		s.state.ts = 100000
+	if int(s.state.tsValue) < len(civTuningSteps) {
+		s.state.ts = civTuningSteps[s.state.tsValue]
+	} else {
+		s.state.ts = civTuningSteps[0]
 	}
 	statusLog.reportTS(s.state.ts)
 
-	if s.state.getTS.pending {
-		s.removePendingCmd(&s.state.getTS)
-		return false
-	}
-	if s.state.setTS.pending {
-		s.removePendingCmd(&s.state.setTS)
-		return false
-	}
 	return true
 }
 
@@ -398,7 +410,7 @@ func (s *civControlStruct) decodeDataModeAndOVF(d []byte) bool {
 	switch d[0] {
 	case 0x06:
 		if len(d) < 3 {
-			return !s.state.setDataMode.pending
+			return true
 		}
 		if d[1] == 1 {
 			s.state.dataMode = true
@@ -407,27 +419,25 @@ func (s *civControlStruct) decodeDataModeAndOVF(d []byte) bool {
 			s.state.dataMode = false
 		}
 
-		statusLog.reportMode(civOperatingModes[s.state.operatingModeIdx].name, s.state.dataMode,
+		statusLog.reportMode(activeRadioModel.modes[s.state.operatingModeIdx].name, s.state.dataMode,
 			civFilters[s.state.filterIdx].name)
 
-		if s.state.setDataMode.pending {
-			s.removePendingCmd(&s.state.setDataMode)
-			return false
+	case 0x05:
+		if arpMenuItem == 0 || len(d) < 3 || uint16(d[1])<<8|uint16(d[2]) != arpMenuItem {
+			return true
 		}
+		if len(d) < 4 {
+			return true
+		}
+		s.state.arp = int(d[3])
+		statusLog.reportARP(s.getARPName())
 	case 0x09:
 		if len(d) < 2 {
-			return !s.state.getOVF.pending
-		}
-		if d[1] != 0 {
-			statusLog.reportOVF(true)
-		} else {
-			statusLog.reportOVF(false)
+			return true
 		}
+		s.state.ovf = d[1] != 0
+		statusLog.reportOVF(s.state.ovf)
 		s.state.lastOVFReceivedAt = time.Now()
-		if s.state.getOVF.pending {
-			s.removePendingCmd(&s.state.getOVF)
-			return false
-		}
 	}
 	return true
 }
@@ -436,93 +446,107 @@ func (s *civControlStruct) decodePowerRFGainSQLNRPwr(d []byte) bool {
 	switch d[0] {
 	case 0x02:
 		if len(d) < 3 {
-			return !s.state.getRFGain.pending && !s.state.setRFGain.pending
+			return true
 		}
 		hex := uint16(d[1])<<8 | uint16(d[2])
 		s.state.rfGainPercent = int(math.Round((float64(hex) / 0x0255) * 100))
 		statusLog.reportRFGain(s.state.rfGainPercent)
-		if s.state.getRFGain.pending {
-			s.removePendingCmd(&s.state.getRFGain)
-			return false
-		}
-		if s.state.setRFGain.pending {
-			s.removePendingCmd(&s.state.setRFGain)
-			return false
-		}
 	case 0x03:
 		if len(d) < 3 {
-			return !s.state.getSQL.pending && !s.state.setSQL.pending
+			return true
 		}
 		hex := uint16(d[1])<<8 | uint16(d[2])
 		s.state.sqlPercent = int(math.Round((float64(hex) / 0x0255) * 100))
 		statusLog.reportSQL(s.state.sqlPercent)
-		if s.state.getSQL.pending {
-			s.removePendingCmd(&s.state.getSQL)
-			return false
-		}
-		if s.state.setSQL.pending {
-			s.removePendingCmd(&s.state.setSQL)
-			return false
-		}
 	case 0x06:
 		if len(d) < 3 {
-			return !s.state.getNR.pending && !s.state.setNR.pending
+			return true
 		}
 		hex := uint16(d[1])<<8 | uint16(d[2])
 		s.state.nrPercent = int(math.Round((float64(hex) / 0x0255) * 100))
 		statusLog.reportNR(s.state.nrPercent)
-		if s.state.getNR.pending {
-			s.removePendingCmd(&s.state.getNR)
-			return false
+	case 0x07:
+		if len(d) < 3 {
+			return true
+		}
+		s.state.pbtIn = (s.decodeBCD(d[1:3]) - 128) * civPBTHzPerStep
+		statusLog.reportPBT(s.state.pbtIn, s.state.pbtOut)
+	case 0x08:
+		if len(d) < 3 {
+			return true
+		}
+		s.state.pbtOut = (s.decodeBCD(d[1:3]) - 128) * civPBTHzPerStep
+		statusLog.reportPBT(s.state.pbtIn, s.state.pbtOut)
+	case 0x12:
+		if len(d) < 3 {
+			return true
+		}
+		hex := uint16(d[1])<<8 | uint16(d[2])
+		s.state.nbPercent = int(math.Round((float64(hex) / 0x0255) * 100))
+		statusLog.reportFuncLevel("nb", s.state.nbPercent)
+	case 0x0e:
+		if len(d) < 3 {
+			return true
+		}
+		hex := uint16(d[1])<<8 | uint16(d[2])
+		s.state.compPercent = int(math.Round((float64(hex) / 0x0255) * 100))
+		statusLog.reportFuncLevel("compLevel", s.state.compPercent)
+	case 0x15:
+		if len(d) < 3 {
+			return true
 		}
-		if s.state.setNR.pending {
-			s.removePendingCmd(&s.state.setNR)
-			return false
+		hex := uint16(d[1])<<8 | uint16(d[2])
+		s.state.monPercent = int(math.Round((float64(hex) / 0x0255) * 100))
+		statusLog.reportFuncLevel("monGain", s.state.monPercent)
+	case 0x16:
+		if len(d) < 3 {
+			return true
 		}
+		hex := uint16(d[1])<<8 | uint16(d[2])
+		s.state.voxPercent = int(math.Round((float64(hex) / 0x0255) * 100))
+		statusLog.reportFuncLevel("voxGain", s.state.voxPercent)
 	case 0x0a:
 		if len(d) < 3 {
-			return !s.state.getPwr.pending && !s.state.setPwr.pending
+			return true
 		}
 		hex := uint16(d[1])<<8 | uint16(d[2])
 		s.state.pwrPercent = int(math.Round((float64(hex) / 0x0255) * 100))
 		statusLog.reportTxPower(s.state.pwrPercent)
-		if s.state.getPwr.pending {
-			s.removePendingCmd(&s.state.getPwr)
-			return false
-		}
-		if s.state.setPwr.pending {
-			s.removePendingCmd(&s.state.setPwr)
-			return false
-		}
 	}
 	return true
 }
 
 func (s *civControlStruct) decodeTransmitStatus(d []byte) bool {
 	if len(d) < 2 {
-		return !s.state.getTuneStatus.pending && !s.state.getTransmitStatus.pending && !s.state.setPTT.pending
+		return true
 	}
 
 	switch d[0] {
 	case 0:
 		if d[1] == 1 {
-			s.state.ptt = true
+			if !s.state.ptt {
+				s.state.ptt = true
+				pttArbiter.reportPTT(true)
+				s.armPTTTimeoutTimer()
+			}
 		} else {
 			if s.state.ptt { // PTT released?
 				s.state.ptt = false
+				pttArbiter.reportPTT(false)
 				if s.state.pttTimeoutTimer != nil {
 					s.state.pttTimeoutTimer.Stop()
 				}
+				txPolicy.reportTxEnd()
 				_ = s.getVd()
 			}
 		}
 		statusLog.reportPTT(s.state.ptt, s.state.tune)
-		if s.state.setPTT.pending {
-			s.removePendingCmd(&s.state.setPTT)
-			return false
-		}
+		metrics.reportTxActive(s.state.ptt || s.state.tune)
 	case 1:
 		if d[1] == 2 {
+			if !s.state.tune {
+				s.state.tuneStartedAt = time.Now()
+			}
 			s.state.tune = true
 
 			// The transceiver does not send the tune state after it's finished.
@@ -530,31 +554,53 @@ func (s *civControlStruct) decodeTransmitStatus(d []byte) bool {
 				_ = s.getTransmitStatus()
 			})
 		} else {
+			s.state.atuEnabled = d[1] == 1
+			statusLog.reportATU(s.state.atuEnabled)
 			if s.state.tune { // Tune finished?
 				s.state.tune = false
 				if s.state.tuneTimeoutTimer != nil {
 					s.state.tuneTimeoutTimer.Stop()
 					s.state.tuneTimeoutTimer = nil
 				}
+				txPolicy.reportTxEnd()
+				s.reportTuneFinished()
 				_ = s.getVd()
 			}
 		}
 
 		statusLog.reportPTT(s.state.ptt, s.state.tune)
-		if s.state.setTune.pending {
-			s.removePendingCmd(&s.state.setTune)
-			return false
-		}
+		metrics.reportTxActive(s.state.ptt || s.state.tune)
 	}
 
-	if s.state.getTuneStatus.pending {
-		s.removePendingCmd(&s.state.getTuneStatus)
-		return false
+	return true
+}
+
+// Logs the result of the tune with the last SWR read during the tune.
+func (s *civControlStruct) reportTuneFinished() {
+	duration := time.Since(s.state.tuneStartedAt).Round(100 * time.Millisecond)
+	swr := "unknown"
+	if s.state.lastSWRReceivedAt.After(s.state.tuneStartedAt) {
+		swr = fmt.Sprintf("%.1f", s.state.swr)
 	}
-	if s.state.getTransmitStatus.pending {
-		s.removePendingCmd(&s.state.getTransmitStatus)
-		return false
+	log.Printw("tune finished", "duration", duration.String(), "swr", swr, "atu", s.state.atuEnabled)
+	eventBus.publishEvent("tuneFinished", map[string]string{"duration": duration.String(), "swr": swr})
+}
+
+func (s *civControlStruct) decodeAntenna(d []byte) bool {
+	if len(d) < 1 {
+		return true
+	}
+	s.state.antenna = int(d[0])
+	statusLog.reportAntenna(s.state.antenna)
+	return true
+}
+
+func (s *civControlStruct) decodeATT(d []byte) bool {
+	if len(d) < 1 {
+		return true
 	}
+	s.state.att = s.decodeBCD(d[:1])
+	statusLog.reportATT(s.state.att)
 	return true
 }
 
@@ -562,63 +608,50 @@ func (s *civControlStruct) decodeVdSWRS(d []byte) bool {
 	switch d[0] {
 	case 0x02:
 		if len(d) < 3 {
-			return !s.state.getS.pending
-		}
-		sValue := (int(math.Round(((float64(int(d[1])<<8) + float64(d[2])) / 0x0241) * 18)))
-		sStr := "S"
-		if sValue <= 9 {
-			sStr += fmt.Sprint(sValue)
-		} else {
-			sStr += "9+"
-
-			switch sValue {
-			case 10:
-				sStr += "10"
-			case 11:
-				sStr += "20"
-			case 12:
-				sStr += "30"
-			case 13:
-				sStr += "40"
-			case 14:
-				sStr += "40"
-			case 15:
-				sStr += "40"
-			case 16:
-				sStr += "40"
-			case 17:
-				sStr += "50"
-			case 18:
-				sStr += "50"
-			default:
-				sStr += "60"
-			}
+			return true
 		}
+		s.state.sDB = activeRadioModel.sMeter.get(decodeMeterRaw(d[1:]))
+		s.state.sDBm = activeRadioModel.s9DBm + s.state.sDB
 		s.state.lastSReceivedAt = time.Now()
-		statusLog.reportS(sStr)
-		if s.state.getS.pending {
-			s.removePendingCmd(&s.state.getS)
-			return false
+		s.state.sValue = getSMeterStr(s.state.sDB)
+		statusLog.reportS(s.state.sValue, s.state.sDBm)
+	case 0x11:
+		if len(d) < 3 {
+			return true
 		}
+		s.state.poWatts = activeRadioModel.pwrMeter.get(decodeMeterRaw(d[1:])) / 100 * activeRadioModel.maxPwrWatts
+		statusLog.reportPo(s.state.poWatts)
 	case 0x12:
 		if len(d) < 3 {
-			return !s.state.getSWR.pending
+			return true
 		}
 		s.state.lastSWRReceivedAt = time.Now()
-		statusLog.reportSWR(((float64(int(d[1])<<8)+float64(d[2]))/0x0120)*2 + 1)
-		if s.state.getSWR.pending {
-			s.removePendingCmd(&s.state.getSWR)
-			return false
+		s.state.swr = activeRadioModel.swrMeter.get(decodeMeterRaw(d[1:]))
+		statusLog.reportSWR(s.state.swr)
+	case 0x13:
+		if len(d) < 3 {
+			return true
 		}
-	case 0x15:
+		s.state.alc = activeRadioModel.alcMeter.get(decodeMeterRaw(d[1:]))
+		statusLog.reportALC(s.state.alc)
+	case 0x14:
 		if len(d) < 3 {
-			return !s.state.getVd.pending
+			return true
 		}
-		statusLog.reportVd(((float64(int(d[1])<<8) + float64(d[2])) / 0x0241) * 16)
-		if s.state.getVd.pending {
-			s.removePendingCmd(&s.state.getVd)
-			return false
+		s.state.comp = activeRadioModel.compMeter.get(decodeMeterRaw(d[1:]))
+		statusLog.reportComp(s.state.comp)
+	case 0x16:
+		if len(d) < 3 {
+			return true
+		}
+		s.state.id = activeRadioModel.idMeter.get(decodeMeterRaw(d[1:]))
+		statusLog.reportId(s.state.id)
+	case 0x15:
+		if len(d) < 3 {
+			return true
 		}
+		s.state.vd = activeRadioModel.vdMeter.get(decodeMeterRaw(d[1:]))
+		statusLog.reportVd(s.state.vd)
 	}
 	return true
 }
@@ -627,21 +660,13 @@ func (s *civControlStruct) decodePreampAGCNREnabled(d []byte) bool {
 	switch d[0] {
 	case 0x02:
 		if len(d) < 2 {
-			return !s.state.getPreamp.pending && !s.state.setPreamp.pending
+			return true
 		}
 		s.state.preamp = int(d[1])
 		statusLog.reportPreamp(s.state.preamp)
-		if s.state.getPreamp.pending {
-			s.removePendingCmd(&s.state.getPreamp)
-			return false
-		}
-		if s.state.setPreamp.pending {
-			s.removePendingCmd(&s.state.setPreamp)
-			return false
-		}
 	case 0x12:
 		if len(d) < 2 {
-			return !s.state.getAGC.pending && !s.state.setAGC.pending
+			return true
 		}
 		s.state.agc = int(d[1])
 		var agc string
@@ -654,17 +679,9 @@ func (s *civControlStruct) decodePreampAGCNREnabled(d []byte) bool {
 			agc = "S"
 		}
 		statusLog.reportAGC(agc)
-		if s.state.getAGC.pending {
-			s.removePendingCmd(&s.state.getAGC)
-			return false
-		}
-		if s.state.setAGC.pending {
-			s.removePendingCmd(&s.state.setAGC)
-			return false
-		}
 	case 0x40:
 		if len(d) < 2 {
-			return !s.state.getNREnabled.pending && !s.state.setNREnabled.pending
+			return true
 		}
 		if d[1] == 1 {
 			s.state.nrEnabled = true
@@ -672,69 +689,165 @@ func (s *civControlStruct) decodePreampAGCNREnabled(d []byte) bool {
 			s.state.nrEnabled = false
 		}
 		statusLog.reportNREnabled(s.state.nrEnabled)
-		if s.state.getNREnabled.pending {
-			s.removePendingCmd(&s.state.getNREnabled)
-			return false
+	case 0x22:
+		if len(d) < 2 {
+			return true
+		}
+		s.state.nbEnabled = d[1] == 1
+		statusLog.reportFunc("nbEnabled", s.state.nbEnabled)
+	case 0x41:
+		if len(d) < 2 {
+			return true
+		}
+		s.state.anfEnabled = d[1] == 1
+		statusLog.reportFunc("anfEnabled", s.state.anfEnabled)
+	case 0x48:
+		if len(d) < 2 {
+			return true
+		}
+		s.state.mnEnabled = d[1] == 1
+		statusLog.reportFunc("mnEnabled", s.state.mnEnabled)
+	case 0x44:
+		if len(d) < 2 {
+			return true
+		}
+		s.state.compEnabled = d[1] == 1
+		statusLog.reportFunc("compEnabled", s.state.compEnabled)
+	case 0x45:
+		if len(d) < 2 {
+			return true
+		}
+		s.state.monEnabled = d[1] == 1
+		statusLog.reportFunc("monEnabled", s.state.monEnabled)
+	case 0x46:
+		if len(d) < 2 {
+			return true
+		}
+		s.state.voxEnabled = d[1] == 1
+		statusLog.reportFunc("voxEnabled", s.state.voxEnabled)
+	case 0x5d:
+		if len(d) < 2 {
+			return true
+		}
+		s.state.toneMode = int(d[1])
+		s.reportTone()
+	}
+	return true
+}
+
+func (s *civControlStruct) decodeOffset(d []byte) bool {
+	if len(d) < 3 {
+		return true
+	}
+	s.state.offset = s.decodeFreqData(d[:3]) * 100
+	statusLog.reportOffset(s.state.offset)
+	return true
+}
+
+func (s *civControlStruct) decodeTone(d []byte) bool {
+	switch d[0] {
+	case 0x00:
+		if len(d) < 4 {
+			return true
+		}
+		s.state.tone = s.decodeBCD(d[2:4])
+		s.reportTone()
+	case 0x01:
+		if len(d) < 4 {
+			return true
+		}
+		s.state.tsqlTone = s.decodeBCD(d[2:4])
+		s.reportTone()
+	case 0x02:
+		if len(d) < 4 {
+			return true
+		}
+		s.state.dcsCode = s.decodeBCD(d[2:4])
+		s.reportTone()
+	}
+	return true
+}
+
+func (s *civControlStruct) decodeRIT(d []byte) bool {
+	switch d[0] {
+	case 0x00:
+		if len(d) < 4 {
+			return true
+		}
+		s.state.ritOffset = int(s.decodeFreqData(d[1:3]))
+		if d[3] == 0x01 {
+			s.state.ritOffset = -s.state.ritOffset
 		}
-		if s.state.setNREnabled.pending {
-			s.removePendingCmd(&s.state.setNREnabled)
-			return false
+		statusLog.reportRIT(s.state.ritOffset, s.state.ritEnabled, s.state.xitEnabled)
+	case 0x01:
+		if len(d) < 2 {
+			return true
+		}
+		s.state.ritEnabled = d[1] == 0x01
+		statusLog.reportRIT(s.state.ritOffset, s.state.ritEnabled, s.state.xitEnabled)
+	case 0x02:
+		if len(d) < 2 {
+			return true
 		}
+		s.state.xitEnabled = d[1] == 0x01
+		statusLog.reportRIT(s.state.ritOffset, s.state.ritEnabled, s.state.xitEnabled)
 	}
 	return true
 }
 
+func (s *civControlStruct) getToneModeName() string {
+	if s.state.toneMode < len(civToneModeNames) {
+		return civToneModeNames[s.state.toneMode]
+	}
+	return fmt.Sprint("0x", s.state.toneMode)
+}
+
+func (s *civControlStruct) getARPName() string {
+	if s.state.arp < len(civARPNames) {
+		return civARPNames[s.state.arp]
+	}
+	return fmt.Sprint(s.state.arp)
+}
+
+// Reports the tone mode with the tone or code which is used in that mode.
+func (s *civControlStruct) reportTone() {
+	var value string
+	switch s.state.toneMode {
+	case civToneModeTone:
+		value = fmt.Sprintf("%.1f", float64(s.state.tone)/10)
+	case civToneModeTSQL:
+		value = fmt.Sprintf("%.1f", float64(s.state.tsqlTone)/10)
+	case civToneModeDTCS:
+		value = fmt.Sprintf("%03d", s.state.dcsCode)
+	}
+	statusLog.reportTone(s.getToneModeName(), value, s.state.tone, s.state.tsqlTone, s.state.dcsCode)
+}
+
 func (s *civControlStruct) decodeVFOFreq(d []byte) bool {
 	if len(d) < 2 {
-		return !s.state.getMainVFOFreq.pending && !s.state.getSubVFOFreq.pending && !s.state.setSubVFOFreq.pending
+		return true
 	}
 
 	f := s.decodeFreqData(d[1:])
 	switch d[0] {
 	default:
-		s.state.freq = f
-		statusLog.reportFrequency(s.state.freq)
-
-		s.state.bandIdx = len(civBands) - 1 // Set the band idx to GENE by default.
-		for i := range civBands {
-			if s.state.freq >= civBands[i].freqFrom && s.state.freq <= civBands[i].freqTo {
-				s.state.bandIdx = i
-				civBands[s.state.bandIdx].freq = s.state.freq
-				break
-			}
-		}
+		s.updateFreq(f)
 
-		if s.state.getMainVFOFreq.pending {
-			s.removePendingCmd(&s.state.getMainVFOFreq)
-			return false
-		}
-		if s.state.setMainVFOFreq.pending {
-			s.removePendingCmd(&s.state.setMainVFOFreq)
-			return false
-		}
 	case 0x01:
 		s.state.subFreq = f
 		statusLog.reportSubFrequency(s.state.subFreq)
-		if s.state.getSubVFOFreq.pending {
-			s.removePendingCmd(&s.state.getSubVFOFreq)
-			return false
-		}
-		if s.state.setSubVFOFreq.pending {
-			s.removePendingCmd(&s.state.setSubVFOFreq)
-			return false
-		}
 	}
 	return true
 }
 
 func (s *civControlStruct) decodeVFOMode(d []byte) bool {
 	if len(d) < 2 {
-		return !s.state.getMainVFOMode.pending && !s.state.getSubVFOMode.pending && !s.state.setSubVFOMode.pending
+		return true
 	}
 
 	operatingModeIdx := -1
-	for i := range civOperatingModes {
-		if civOperatingModes[i].code == d[1] {
+	for i := range activeRadioModel.modes {
+		if activeRadioModel.modes[i].code == d[1] {
 			operatingModeIdx = i
 			break
 		}
@@ -755,156 +868,164 @@ func (s *civControlStruct) decodeVFOMode(d []byte) bool {
 		if filterIdx >= 0 {
 			s.state.filterIdx = filterIdx
 		}
-		statusLog.reportMode(civOperatingModes[s.state.operatingModeIdx].name, s.state.dataMode,
+		statusLog.reportMode(activeRadioModel.modes[s.state.operatingModeIdx].name, s.state.dataMode,
 			civFilters[s.state.filterIdx].name)
 
-		if s.state.getMainVFOMode.pending {
-			s.removePendingCmd(&s.state.getMainVFOMode)
-			return false
-		}
 	case 0x01:
 		s.state.subOperatingModeIdx = operatingModeIdx
 		s.state.subDataMode = dataMode
 		s.state.subFilterIdx = filterIdx
-		statusLog.reportSubMode(civOperatingModes[s.state.subOperatingModeIdx].name, s.state.subDataMode,
+		statusLog.reportSubMode(activeRadioModel.modes[s.state.subOperatingModeIdx].name, s.state.subDataMode,
 			civFilters[s.state.subFilterIdx].name)
 
-		if s.state.getSubVFOMode.pending {
-			s.removePendingCmd(&s.state.getSubVFOMode)
-			return false
-		}
-		if s.state.setSubVFOMode.pending {
-			s.removePendingCmd(&s.state.setSubVFOMode)
-			return false
-		}
 	}
 	return true
 }
 
-func (s *civControlStruct) initCmd(cmd *civCmd, name string, data []byte) {
-	*cmd = civCmd{}
-	cmd.name = name
-	cmd.cmd = data
-}
+func (s *civControlStruct) decodeScope(d []byte) bool {
+	if len(d) < 1 {
+		return true
+	}
 
-func (s *civControlStruct) getPendingCmdIndex(cmd *civCmd) int {
-	for i := range s.state.pendingCmds {
-		if cmd == s.state.pendingCmds[i] {
-			return i
+	switch d[0] {
+	case 0x00:
+		scope.add(d[1:])
+		return !s.state.scopeEnabled
+	case 0x14:
+		if len(d) < 3 {
+			return true
+		}
+		s.state.scopeCenterMode = d[2] == 0x00 || d[2] == 0x02
+	case 0x15:
+		if len(d) < 7 {
+			return true
 		}
+		s.state.scopeSpan = s.decodeFreqData(d[2:7])
 	}
-	return -1
+	return true
 }
 
-func (s *civControlStruct) removePendingCmd(cmd *civCmd) {
-	cmd.pending = false
-	index := s.getPendingCmdIndex(cmd)
-	if index < 0 {
-		return
-	}
-	s.state.pendingCmds[index] = s.state.pendingCmds[len(s.state.pendingCmds)-1]
-	s.state.pendingCmds[len(s.state.pendingCmds)-1] = nil
-	s.state.pendingCmds = s.state.pendingCmds[:len(s.state.pendingCmds)-1]
+// Sends the command through the CI-V queue. Set commands wait for the radio's OK/NG reply, so errors are
+// returned to the caller. Reads and commands sent during init (when the serial stream is not processing
+// replies yet) don't wait.
+func (s *civControlStruct) submit(src civSource, name string, frame []byte) error {
+	return s.submitRequest(civQueue.newRequest(src, name, frame))
 }
 
-func (s *civControlStruct) sendCmd(cmd *civCmd) error {
+func (s *civControlStruct) submitRequest(r *civRequest) error {
 	if s.st == nil {
 		return nil
 	}
 
-	cmd.pending = true
-	cmd.sentAt = time.Now()
-	if s.getPendingCmdIndex(cmd) < 0 {
-		s.state.pendingCmds = append(s.state.pendingCmds, cmd)
-		select {
-		case s.newPendingCmdAdded <- true:
-		default:
-		}
+	res := civQueue.submit(r)
+	if r.isRead || s.deinitNeeded == nil {
+		return nil
+	}
+	_, err := res.wait()
+	return err
+}
+
+// Sends a periodic read with poll priority, if the previous one (stored in prev) has already finished.
+func (s *civControlStruct) poll(prev **civFuture, name string, frame []byte) {
+	if s.st == nil || (*prev != nil && !(*prev).isDone()) {
+		return
 	}
-	return s.st.send(cmd.cmd)
+
+	r := civQueue.newRequest(civSourceInternal, name, frame)
+	r.priority = civPriorityPoll
+	*prev = civQueue.submit(r)
 }
 
-func (s *civControlStruct) setPwr(percent int) error {
+func (s *civControlStruct) setPwr(src civSource, percent int) error {
+	if listenOnly {
+		return errListenOnly
+	}
+	if err := txPolicy.checkPwr(src, s.getTxFreq(), percent); err != nil {
+		return err
+	}
 	v := uint16(0x0255 * (float64(percent) / 100))
-	s.initCmd(&s.state.setPwr, "setPwr", []byte{254, 254, civAddress, 224, 0x14, 0x0a, byte(v >> 8), byte(v & 0xff), 253})
-	return s.sendCmd(&s.state.setPwr)
+	return s.submit(src, "setPwr", []byte{254, 254, civAddress, 224, 0x14, 0x0a, byte(v >> 8), byte(v & 0xff), 253})
 }
 
-func (s *civControlStruct) incPwr() error {
+func (s *civControlStruct) incPwr(src civSource) error {
 	if s.state.pwrPercent < 100 {
-		return s.setPwr(s.state.pwrPercent + 1)
+		return s.setPwr(src, s.state.pwrPercent+1)
 	}
 	return nil
 }
 
-func (s *civControlStruct) decPwr() error {
+func (s *civControlStruct) decPwr(src civSource) error {
 	if s.state.pwrPercent > 0 {
-		return s.setPwr(s.state.pwrPercent - 1)
+		return s.setPwr(src, s.state.pwrPercent-1)
 	}
 	return nil
 }
 
-func (s *civControlStruct) setRFGain(percent int) error {
+func (s *civControlStruct) setRFGain(src civSource, percent int) error {
 	v := uint16(0x0255 * (float64(percent) / 100))
-	s.initCmd(&s.state.setRFGain, "setRFGain", []byte{254, 254, civAddress, 224, 0x14, 0x02, byte(v >> 8), byte(v & 0xff), 253})
-	return s.sendCmd(&s.state.setRFGain)
+	return s.submit(src, "setRFGain", []byte{254, 254, civAddress, 224, 0x14, 0x02, byte(v >> 8), byte(v & 0xff), 253})
 }
 
-func (s *civControlStruct) incRFGain() error {
+func (s *civControlStruct) incRFGain(src civSource) error {
 	if s.state.rfGainPercent < 100 {
-		return s.setRFGain(s.state.rfGainPercent + 1)
+		return s.setRFGain(src, s.state.rfGainPercent+1)
 	}
 	return nil
 }
 
-func (s *civControlStruct) decRFGain() error {
+func (s *civControlStruct) decRFGain(src civSource) error {
 	if s.state.rfGainPercent > 0 {
-		return s.setRFGain(s.state.rfGainPercent - 1)
+		return s.setRFGain(src, s.state.rfGainPercent-1)
 	}
 	return nil
 }
 
-func (s *civControlStruct) setSQL(percent int) error {
+func (s *civControlStruct) setSQL(src civSource, percent int) error {
 	v := uint16(0x0255 * (float64(percent) / 100))
-	s.initCmd(&s.state.setSQL, "setSQL", []byte{254, 254, civAddress, 224, 0x14, 0x03, byte(v >> 8), byte(v & 0xff), 253})
-	return s.sendCmd(&s.state.setSQL)
+	return s.submit(src, "setSQL", []byte{254, 254, civAddress, 224, 0x14, 0x03, byte(v >> 8), byte(v & 0xff), 253})
 }
 
-func (s *civControlStruct) incSQL() error {
+func (s *civControlStruct) incSQL(src civSource) error {
 	if s.state.sqlPercent < 100 {
-		return s.setSQL(s.state.sqlPercent + 1)
+		return s.setSQL(src, s.state.sqlPercent+1)
 	}
 	return nil
 }
 
-func (s *civControlStruct) decSQL() error {
+func (s *civControlStruct) decSQL(src civSource) error {
 	if s.state.sqlPercent > 0 {
-		return s.setSQL(s.state.sqlPercent - 1)
+		return s.setSQL(src, s.state.sqlPercent-1)
 	}
 	return nil
 }
 
-func (s *civControlStruct) setNR(percent int) error {
+func (s *civControlStruct) setNREnabled(src civSource, enable bool) error {
+	if s.state.nrEnabled == enable {
+		return nil
+	}
+	return s.toggleNR(src)
+}
+
+func (s *civControlStruct) setNR(src civSource, percent int) error {
 	if !s.state.nrEnabled {
-		if err := s.toggleNR(); err != nil {
+		if err := s.toggleNR(src); err != nil {
 			return err
 		}
 	}
 	v := uint16(0x0255 * (float64(percent) / 100))
-	s.initCmd(&s.state.setNR, "setNR", []byte{254, 254, civAddress, 224, 0x14, 0x06, byte(v >> 8), byte(v & 0xff), 253})
-	return s.sendCmd(&s.state.setNR)
+	return s.submit(src, "setNR", []byte{254, 254, civAddress, 224, 0x14, 0x06, byte(v >> 8), byte(v & 0xff), 253})
 }
 
-func (s *civControlStruct) incNR() error {
+func (s *civControlStruct) incNR(src civSource) error {
 	if s.state.nrPercent < 100 {
-		return s.setNR(s.state.nrPercent + 1)
+		return s.setNR(src, s.state.nrPercent+1)
 	}
 	return nil
 }
 
-func (s *civControlStruct) decNR() error {
+func (s *civControlStruct) decNR(src civSource) error {
 	if s.state.nrPercent > 0 {
-		return s.setNR(s.state.nrPercent - 1)
+		return s.setNR(src, s.state.nrPercent-1)
 	}
 	return nil
 }
@@ -918,12 +1039,12 @@ func (s *civControlStruct) getDigit(v uint, n int) byte {
 	return byte(uint(f) % 10)
 }
 
-func (s *civControlStruct) incFreq() error {
-	return s.setMainVFOFreq(s.state.freq + s.state.ts)
+func (s *civControlStruct) incFreq(src civSource) error {
+	return s.setMainVFOFreq(src, s.state.freq+s.state.ts)
 }
 
-func (s *civControlStruct) decFreq() error {
-	return s.setMainVFOFreq(s.state.freq - s.state.ts)
+func (s *civControlStruct) decFreq(src civSource) error {
+	return s.setMainVFOFreq(src, s.state.freq-s.state.ts)
 }
 
 func (s *civControlStruct) encodeFreqData(f uint) (b [5]byte) {
@@ -945,103 +1066,594 @@ func (s *civControlStruct) encodeFreqData(f uint) (b [5]byte) {
 	return
 }
 
-func (s *civControlStruct) setMainVFOFreq(f uint) error {
+// Returns the frequency we transmit on. This is the unselected VFO's frequency in split mode, the
+// frequency shifted by the offset in DUP mode, and the XIT offset is added if XIT is enabled.
+func (s *civControlStruct) getTxFreq() uint {
+	f := s.state.freq
+	switch s.state.splitMode {
+	case splitModeOn:
+		f = s.state.subFreq
+	case splitModeDUPMinus:
+		if s.state.offset < f {
+			f -= s.state.offset
+		} else {
+			f = 0
+		}
+	case splitModeDUPPlus:
+		f += s.state.offset
+	}
+	if s.state.xitEnabled {
+		if s.state.ritOffset < 0 && uint(-s.state.ritOffset) > f {
+			return 0
+		}
+		f = uint(int(f) + s.state.ritOffset)
+	}
+	return f
+}
+
+func (s *civControlStruct) setMainVFOFreq(src civSource, f uint) error {
+	if listenOnly {
+		return errListenOnly
+	}
+	if (s.state.ptt || s.state.tune) && s.state.splitMode != splitModeOn {
+		if err := txPolicy.checkFreq(src, f); err != nil {
+			return err
+		}
+	}
 	b := s.encodeFreqData(f)
-	s.initCmd(&s.state.setMainVFOFreq, "setMainVFOFreq", []byte{254, 254, civAddress, 224, 0x25, 0x00, b[0], b[1], b[2], b[3], b[4], 253})
-	return s.sendCmd(&s.state.setMainVFOFreq)
+	return s.submit(src, "setMainVFOFreq", []byte{254, 254, civAddress, 224, 0x25, 0x00, b[0], b[1], b[2], b[3], b[4], 253})
 }
 
-func (s *civControlStruct) setSubVFOFreq(f uint) error {
+func (s *civControlStruct) setSubVFOFreq(src civSource, f uint) error {
+	if listenOnly {
+		return errListenOnly
+	}
+	if (s.state.ptt || s.state.tune) && s.state.splitMode == splitModeOn {
+		if err := txPolicy.checkFreq(src, f); err != nil {
+			return err
+		}
+	}
 	b := s.encodeFreqData(f)
-	s.initCmd(&s.state.setSubVFOFreq, "setSubVFOFreq", []byte{254, 254, civAddress, 224, 0x25, 0x01, b[0], b[1], b[2], b[3], b[4], 253})
-	return s.sendCmd(&s.state.setSubVFOFreq)
+	return s.submit(src, "setSubVFOFreq", []byte{254, 254, civAddress, 224, 0x25, 0x01, b[0], b[1], b[2], b[3], b[4], 253})
 }
 
-func (s *civControlStruct) incOperatingMode() error {
+func (s *civControlStruct) incOperatingMode(src civSource) error {
 	s.state.operatingModeIdx++
-	if s.state.operatingModeIdx >= len(civOperatingModes) {
+	if s.state.operatingModeIdx >= len(activeRadioModel.modes) {
 		s.state.operatingModeIdx = 0
 	}
-	return civControl.setOperatingModeAndFilter(civOperatingModes[s.state.operatingModeIdx].code,
+	return s.setOperatingModeAndFilter(src, activeRadioModel.modes[s.state.operatingModeIdx].code,
 		civFilters[s.state.filterIdx].code)
 }
 
-func (s *civControlStruct) decOperatingMode() error {
+func (s *civControlStruct) decOperatingMode(src civSource) error {
 	s.state.operatingModeIdx--
 	if s.state.operatingModeIdx < 0 {
-		s.state.operatingModeIdx = len(civOperatingModes) - 1
+		s.state.operatingModeIdx = len(activeRadioModel.modes) - 1
 	}
-	return civControl.setOperatingModeAndFilter(civOperatingModes[s.state.operatingModeIdx].code,
+	return s.setOperatingModeAndFilter(src, activeRadioModel.modes[s.state.operatingModeIdx].code,
 		civFilters[s.state.filterIdx].code)
 }
 
-func (s *civControlStruct) incFilter() error {
+func (s *civControlStruct) incFilter(src civSource) error {
 	s.state.filterIdx++
 	if s.state.filterIdx >= len(civFilters) {
 		s.state.filterIdx = 0
 	}
-	return civControl.setOperatingModeAndFilter(civOperatingModes[s.state.operatingModeIdx].code,
+	return s.setOperatingModeAndFilter(src, activeRadioModel.modes[s.state.operatingModeIdx].code,
 		civFilters[s.state.filterIdx].code)
 }
 
-func (s *civControlStruct) decFilter() error {
+func (s *civControlStruct) decFilter(src civSource) error {
 	s.state.filterIdx--
 	if s.state.filterIdx < 0 {
 		s.state.filterIdx = len(civFilters) - 1
 	}
-	return civControl.setOperatingModeAndFilter(civOperatingModes[s.state.operatingModeIdx].code,
+	return s.setOperatingModeAndFilter(src, activeRadioModel.modes[s.state.operatingModeIdx].code,
 		civFilters[s.state.filterIdx].code)
 }
 
-func (s *civControlStruct) setOperatingModeAndFilter(modeCode, filterCode byte) error {
-	s.initCmd(&s.state.setMode, "setMode", []byte{254, 254, civAddress, 224, 0x06, modeCode, filterCode, 253})
-	if err := s.sendCmd(&s.state.setMode); err != nil {
+func (s *civControlStruct) setOperatingModeAndFilter(src civSource, modeCode, filterCode byte) error {
+	if err := s.submit(src, "setMode", []byte{254, 254, civAddress, 224, 0x06, modeCode, filterCode, 253}); err != nil {
 		return err
 	}
 	return s.getBothVFOMode()
 }
 
-func (s *civControlStruct) setSubVFOMode(modeCode, dataMode, filterCode byte) error {
-	s.initCmd(&s.state.setSubVFOMode, "setSubVFOMode", []byte{254, 254, civAddress, 224, 0x26, 0x01, modeCode, dataMode, filterCode, 253})
-	return s.sendCmd(&s.state.setSubVFOMode)
+func (s *civControlStruct) setSubVFOMode(src civSource, modeCode, dataMode, filterCode byte) error {
+	return s.submit(src, "setSubVFOMode", []byte{254, 254, civAddress, 224, 0x26, 0x01, modeCode, dataMode, filterCode, 253})
 }
 
-func (s *civControlStruct) setPTT(enable bool) error {
+// Use pttArbiter.requestPTT() instead, so the PTT owner is tracked.
+func (s *civControlStruct) setPTT(src civSource, enable bool) error {
+	return s.sendPTT(src, enable)
+}
+
+func (s *civControlStruct) sendPTT(src civSource, enable bool) error {
+	if listenOnly && enable {
+		return errListenOnly
+	}
+
+	if enable && !s.state.ptt && !s.state.tune {
+		if err := txPolicy.checkTx(src, s.getTxFreq(), s.state.pwrPercent); err != nil {
+			return err
+		}
+	}
+
 	var b byte
 	if enable {
 		b = 1
-		s.state.pttTimeoutTimer = time.AfterFunc(pttTimeout, func() {
-			_ = s.setPTT(false)
-		})
 	}
-	s.initCmd(&s.state.setPTT, "setPTT", []byte{254, 254, civAddress, 224, 0x1c, 0, b, 253})
-	return s.sendCmd(&s.state.setPTT)
+	return s.submit(src, "setPTT", []byte{254, 254, civAddress, 224, 0x1c, 0, b, 253})
 }
 
-func (s *civControlStruct) setTune(enable bool) error {
-	if s.state.ptt {
-		return nil
+// Called when the radio reports PTT on, no matter who has keyed it (us, a raw CI-V client, VOX or
+// the radio's own PTT button).
+func (s *civControlStruct) armPTTTimeoutTimer() {
+	if s.state.pttTimeoutTimer != nil {
+		s.state.pttTimeoutTimer.Stop()
+	}
+	s.state.pttTimeoutTimer = time.AfterFunc(txTimeout, func() {
+		log.Print("tx timeout, releasing ptt")
+		_ = s.sendPTT(civSourceInternal, false)
+	})
+}
+
+func (s *civControlStruct) setTune(src civSource, enable bool) error {
+	if listenOnly && enable {
+		return errListenOnly
+	}
+	if s.state.ptt {
+		return nil
+	}
+	if enable && !s.state.tune {
+		if err := txPolicy.checkTx(src, s.getTxFreq(), s.state.pwrPercent); err != nil {
+			return err
+		}
 	}
 
 	var b byte
 	if enable {
 		b = 2
-		s.state.tuneTimeoutTimer = time.AfterFunc(tuneTimeout, func() {
+		timeout := tuneTimeout
+		if txTimeout < timeout {
+			timeout = txTimeout
+		}
+		s.state.tuneTimeoutTimer = time.AfterFunc(timeout, func() {
 			s.state.tuneTimeoutTimer = nil
-			_ = s.setTune(false)
+			_ = s.setTune(src, false)
 		})
 	} else {
 		b = 1
 	}
-	s.initCmd(&s.state.setTune, "setTune", []byte{254, 254, civAddress, 224, 0x1c, 1, b, 253})
-	return s.sendCmd(&s.state.setTune)
+	return s.submit(src, "setTune", []byte{254, 254, civAddress, 224, 0x1c, 1, b, 253})
 }
 
-func (s *civControlStruct) toggleTune() error {
-	return s.setTune(!s.state.tune)
+func (s *civControlStruct) toggleTune(src civSource) error {
+	return s.setTune(src, !s.state.tune)
 }
 
-func (s *civControlStruct) setDataMode(enable bool) error {
+// Enables the antenna tuner, or bypasses it.
+func (s *civControlStruct) setATU(src civSource, enable bool) error {
+	var b byte
+	if enable {
+		b = 1
+	}
+	return s.submit(src, "setATU", []byte{254, 254, civAddress, 224, 0x1c, 1, b, 253})
+}
+
+func (s *civControlStruct) toggleATU(src civSource) error {
+	return s.setATU(src, !s.state.atuEnabled)
+}
+
+// Selects the antenna connector, nr 0 is ANT1.
+func (s *civControlStruct) setAntenna(src civSource, nr int) error {
+	if nr < 0 || nr >= activeRadioModel.antennas {
+		return fmt.Errorf("%s has no ANT%d", activeRadioModel.name, nr+1)
+	}
+	return s.submit(src, "setAntenna", []byte{254, 254, civAddress, 224, 0x12, byte(nr), 253})
+}
+
+func (s *civControlStruct) cycleAntenna(src civSource) error {
+	if activeRadioModel.antennas < 2 {
+		return fmt.Errorf("%s has no antenna selection", activeRadioModel.name)
+	}
+	return s.setAntenna(src, (s.state.antenna+1)%activeRadioModel.antennas)
+}
+
+// Sets the repeater offset frequency in Hz.
+func (s *civControlStruct) setOffset(src civSource, f uint) error {
+	if f >= 100000000 {
+		return fmt.Errorf("invalid repeater offset %d", f)
+	}
+	b := s.encodeFreqData(f / 100)
+	if err := s.submit(src, "setOffset", []byte{254, 254, civAddress, 224, 0x0d, b[0], b[1], b[2], 253}); err != nil {
+		return err
+	}
+	// The radio does not send back the offset after setting it.
+	return s.getOffset()
+}
+
+func (s *civControlStruct) cycleOffset(src civSource) error {
+	for _, o := range civRepeaterOffsets {
+		if o > s.state.offset {
+			return s.setOffset(src, o)
+		}
+	}
+	return s.setOffset(src, civRepeaterOffsets[0])
+}
+
+// Sets the repeater shift, DUP- or DUP+. Any other mode sets simplex operation.
+func (s *civControlStruct) setDuplex(src civSource, mode splitMode) error {
+	if mode != splitModeDUPMinus && mode != splitModeDUPPlus {
+		mode = splitModeOff
+	}
+	return s.setSplit(src, mode)
+}
+
+func (s *civControlStruct) cycleDuplex(src civSource) error {
+	switch s.state.splitMode {
+	case splitModeDUPMinus:
+		return s.setDuplex(src, splitModeDUPPlus)
+	case splitModeDUPPlus:
+		return s.setDuplex(src, splitModeOff)
+	}
+	return s.setDuplex(src, splitModeDUPMinus)
+}
+
+func (s *civControlStruct) setToneMode(src civSource, mode int) error {
+	if mode < 0 || mode >= len(civToneModeNames) {
+		return fmt.Errorf("invalid tone mode %d", mode)
+	}
+	return s.submit(src, "setToneMode", []byte{254, 254, civAddress, 224, 0x16, 0x5d, byte(mode), 253})
+}
+
+func (s *civControlStruct) cycleToneMode(src civSource) error {
+	mode := s.state.toneMode + 1
+	if mode >= len(civToneModeNames) {
+		mode = civToneModeOff
+	}
+	return s.setToneMode(src, mode)
+}
+
+func (s *civControlStruct) checkCTCSSTone(tone int) error {
+	for _, t := range civCTCSSTones {
+		if t == tone {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid CTCSS tone %.1f", float64(tone)/10)
+}
+
+// Sets the repeater (TX) tone, tone is in 0.1 Hz.
+func (s *civControlStruct) setTone(src civSource, tone int) error {
+	if err := s.checkCTCSSTone(tone); err != nil {
+		return err
+	}
+	b := s.encodeBCD(tone, 3)
+	return s.submit(src, "setTone", []byte{254, 254, civAddress, 224, 0x1b, 0x00, b[0], b[1], b[2], 253})
+}
+
+// Sets the tone squelch (RX) tone, tone is in 0.1 Hz.
+func (s *civControlStruct) setTSQLTone(src civSource, tone int) error {
+	if err := s.checkCTCSSTone(tone); err != nil {
+		return err
+	}
+	b := s.encodeBCD(tone, 3)
+	return s.submit(src, "setTSQLTone", []byte{254, 254, civAddress, 224, 0x1b, 0x01, b[0], b[1], b[2], 253})
+}
+
+// Sets the DCS code with normal TX and RX polarity.
+func (s *civControlStruct) setDCSCode(src civSource, code int) error {
+	found := false
+	for _, c := range civDCSCodes {
+		if c == code {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("invalid DCS code %03d", code)
+	}
+	b := s.encodeBCD(code, 2)
+	return s.submit(src, "setDCSCode", []byte{254, 254, civAddress, 224, 0x1b, 0x02, 0x00, b[0], b[1], 253})
+}
+
+func (s *civControlStruct) stepInList(list []int, current int, inc bool) int {
+	i := 0
+	for i < len(list) && list[i] < current {
+		i++
+	}
+	if inc {
+		if i < len(list) && list[i] == current {
+			i++
+		}
+		if i >= len(list) {
+			i = 0
+		}
+	} else {
+		i--
+		if i < 0 {
+			i = len(list) - 1
+		}
+	}
+	return list[i]
+}
+
+// Steps the tone or code which is used by the current tone mode.
+func (s *civControlStruct) stepTone(src civSource, inc bool) error {
+	switch s.state.toneMode {
+	case civToneModeTSQL:
+		return s.setTSQLTone(src, s.stepInList(civCTCSSTones, s.state.tsqlTone, inc))
+	case civToneModeDTCS:
+		return s.setDCSCode(src, s.stepInList(civDCSCodes, s.state.dcsCode, inc))
+	}
+	return s.setTone(src, s.stepInList(civCTCSSTones, s.state.tone, inc))
+}
+
+func (s *civControlStruct) incTone(src civSource) error {
+	return s.stepTone(src, true)
+}
+
+func (s *civControlStruct) decTone(src civSource) error {
+	return s.stepTone(src, false)
+}
+
+// Sets the auto repeater function, 0 is off. This is a menu setting, so it is only available if its menu item
+// number is set with --arp-menu-item.
+func (s *civControlStruct) setARP(src civSource, v int) error {
+	if arpMenuItem == 0 {
+		return errors.New("the auto repeater menu item is not set")
+	}
+	if v < 0 || v >= len(civARPNames) {
+		return fmt.Errorf("invalid auto repeater value %d", v)
+	}
+	return s.submit(src, "setARP", []byte{254, 254, civAddress, 224, 0x1a, 0x05,
+		byte(arpMenuItem >> 8), byte(arpMenuItem & 0xff), byte(v), 253})
+}
+
+func (s *civControlStruct) cycleARP(src civSource) error {
+	return s.setARP(src, (s.state.arp+1)%len(civARPNames))
+}
+
+// Sets the offset used by RIT and XIT in Hz.
+func (s *civControlStruct) setRITOffset(src civSource, offset int) error {
+	if offset < -civMaxRITOffset || offset > civMaxRITOffset {
+		return fmt.Errorf("invalid RIT offset %d", offset)
+	}
+	var sign byte
+	if offset < 0 {
+		sign = 0x01
+		offset = -offset
+	}
+	b := s.encodeFreqData(uint(offset))
+	return s.submit(src, "setRITOffset", []byte{254, 254, civAddress, 224, 0x21, 0x00, b[0], b[1], sign, 253})
+}
+
+func (s *civControlStruct) incRITOffset(src civSource) error {
+	if s.state.ritOffset+civRITStep > civMaxRITOffset {
+		return nil
+	}
+	return s.setRITOffset(src, s.state.ritOffset+civRITStep)
+}
+
+func (s *civControlStruct) decRITOffset(src civSource) error {
+	if s.state.ritOffset-civRITStep < -civMaxRITOffset {
+		return nil
+	}
+	return s.setRITOffset(src, s.state.ritOffset-civRITStep)
+}
+
+func (s *civControlStruct) setRITEnabled(src civSource, enable bool) error {
+	var b byte
+	if enable {
+		b = 1
+	}
+	return s.submit(src, "setRITEnabled", []byte{254, 254, civAddress, 224, 0x21, 0x01, b, 253})
+}
+
+func (s *civControlStruct) toggleRIT(src civSource) error {
+	return s.setRITEnabled(src, !s.state.ritEnabled)
+}
+
+// Enables or disables XIT (delta TX).
+func (s *civControlStruct) setXITEnabled(src civSource, enable bool) error {
+	var b byte
+	if enable {
+		b = 1
+	}
+	return s.submit(src, "setXITEnabled", []byte{254, 254, civAddress, 224, 0x21, 0x02, b, 253})
+}
+
+func (s *civControlStruct) toggleXIT(src civSource) error {
+	return s.setXITEnabled(src, !s.state.xitEnabled)
+}
+
+func (s *civControlStruct) encodePBT(hz int) ([]byte, error) {
+	if hz < -civMaxPBT || hz > civMaxPBT {
+		return nil, fmt.Errorf("invalid PBT value %d", hz)
+	}
+	return s.encodeBCD(128+int(math.Round(float64(hz)/civPBTHzPerStep)), 2), nil
+}
+
+// Sets the inner twin PBT position in Hz, 0 is the center.
+func (s *civControlStruct) setPBTIn(src civSource, hz int) error {
+	b, err := s.encodePBT(hz)
+	if err != nil {
+		return err
+	}
+	return s.submit(src, "setPBTIn", []byte{254, 254, civAddress, 224, 0x14, 0x07, b[0], b[1], 253})
+}
+
+// Sets the outer twin PBT position in Hz, 0 is the center.
+func (s *civControlStruct) setPBTOut(src civSource, hz int) error {
+	b, err := s.encodePBT(hz)
+	if err != nil {
+		return err
+	}
+	return s.submit(src, "setPBTOut", []byte{254, 254, civAddress, 224, 0x14, 0x08, b[0], b[1], 253})
+}
+
+func (s *civControlStruct) incPBTIn(src civSource) error {
+	if s.state.pbtIn+civPBTStep > civMaxPBT {
+		return nil
+	}
+	return s.setPBTIn(src, s.state.pbtIn+civPBTStep)
+}
+
+func (s *civControlStruct) decPBTIn(src civSource) error {
+	if s.state.pbtIn-civPBTStep < -civMaxPBT {
+		return nil
+	}
+	return s.setPBTIn(src, s.state.pbtIn-civPBTStep)
+}
+
+func (s *civControlStruct) incPBTOut(src civSource) error {
+	if s.state.pbtOut+civPBTStep > civMaxPBT {
+		return nil
+	}
+	return s.setPBTOut(src, s.state.pbtOut+civPBTStep)
+}
+
+func (s *civControlStruct) decPBTOut(src civSource) error {
+	if s.state.pbtOut-civPBTStep < -civMaxPBT {
+		return nil
+	}
+	return s.setPBTOut(src, s.state.pbtOut-civPBTStep)
+}
+
+// Sets the attenuator in dB, 0 turns it off.
+func (s *civControlStruct) setATT(src civSource, db int) error {
+	valid := db == 0
+	for _, a := range activeRadioModel.attenuators {
+		if a == db {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("unsupported attenuator value %d dB", db)
+	}
+	return s.submit(src, "setATT", []byte{254, 254, civAddress, 224, 0x11, s.encodeBCD(db, 1)[0], 253})
+}
+
+func (s *civControlStruct) cycleATT(src civSource) error {
+	for _, a := range activeRadioModel.attenuators {
+		if a > s.state.att {
+			return s.setATT(src, a)
+		}
+	}
+	return s.setATT(src, 0)
+}
+
+func (s *civControlStruct) setNBEnabled(src civSource, enable bool) error {
+	var b byte
+	if enable {
+		b = 1
+	}
+	return s.submit(src, "setNBEnabled", []byte{254, 254, civAddress, 224, 0x16, 0x22, b, 253})
+}
+
+func (s *civControlStruct) toggleNB(src civSource) error {
+	return s.setNBEnabled(src, !s.state.nbEnabled)
+}
+
+func (s *civControlStruct) setANFEnabled(src civSource, enable bool) error {
+	var b byte
+	if enable {
+		b = 1
+	}
+	return s.submit(src, "setANFEnabled", []byte{254, 254, civAddress, 224, 0x16, 0x41, b, 253})
+}
+
+func (s *civControlStruct) toggleANF(src civSource) error {
+	return s.setANFEnabled(src, !s.state.anfEnabled)
+}
+
+func (s *civControlStruct) setMNEnabled(src civSource, enable bool) error {
+	var b byte
+	if enable {
+		b = 1
+	}
+	return s.submit(src, "setMNEnabled", []byte{254, 254, civAddress, 224, 0x16, 0x48, b, 253})
+}
+
+func (s *civControlStruct) toggleMN(src civSource) error {
+	return s.setMNEnabled(src, !s.state.mnEnabled)
+}
+
+func (s *civControlStruct) setCompEnabled(src civSource, enable bool) error {
+	var b byte
+	if enable {
+		b = 1
+	}
+	return s.submit(src, "setCompEnabled", []byte{254, 254, civAddress, 224, 0x16, 0x44, b, 253})
+}
+
+func (s *civControlStruct) toggleComp(src civSource) error {
+	return s.setCompEnabled(src, !s.state.compEnabled)
+}
+
+func (s *civControlStruct) setMonEnabled(src civSource, enable bool) error {
+	var b byte
+	if enable {
+		b = 1
+	}
+	return s.submit(src, "setMonEnabled", []byte{254, 254, civAddress, 224, 0x16, 0x45, b, 253})
+}
+
+func (s *civControlStruct) toggleMon(src civSource) error {
+	return s.setMonEnabled(src, !s.state.monEnabled)
+}
+
+func (s *civControlStruct) setVOXEnabled(src civSource, enable bool) error {
+	if listenOnly && enable {
+		return errListenOnly
+	}
+	var b byte
+	if enable {
+		b = 1
+	}
+	return s.submit(src, "setVOXEnabled", []byte{254, 254, civAddress, 224, 0x16, 0x46, b, 253})
+}
+
+func (s *civControlStruct) toggleVOX(src civSource) error {
+	return s.setVOXEnabled(src, !s.state.voxEnabled)
+}
+
+func (s *civControlStruct) setNBLevel(src civSource, percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("invalid noise blanker level %d", percent)
+	}
+	v := uint16(0x0255 * (float64(percent) / 100))
+	return s.submit(src, "setNBLevel", []byte{254, 254, civAddress, 224, 0x14, 0x12, byte(v >> 8), byte(v & 0xff), 253})
+}
+
+func (s *civControlStruct) setCompLevel(src civSource, percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("invalid speech compressor level %d", percent)
+	}
+	v := uint16(0x0255 * (float64(percent) / 100))
+	return s.submit(src, "setCompLevel", []byte{254, 254, civAddress, 224, 0x14, 0x0e, byte(v >> 8), byte(v & 0xff), 253})
+}
+
+func (s *civControlStruct) setMonGain(src civSource, percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("invalid TX monitor gain %d", percent)
+	}
+	v := uint16(0x0255 * (float64(percent) / 100))
+	return s.submit(src, "setMonGain", []byte{254, 254, civAddress, 224, 0x14, 0x15, byte(v >> 8), byte(v & 0xff), 253})
+}
+
+func (s *civControlStruct) setVOXGain(src civSource, percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("invalid VOX gain %d", percent)
+	}
+	v := uint16(0x0255 * (float64(percent) / 100))
+	return s.submit(src, "setVOXGain", []byte{254, 254, civAddress, 224, 0x14, 0x16, byte(v >> 8), byte(v & 0xff), 253})
+}
+
+func (s *civControlStruct) setDataMode(src civSource, enable bool) error {
 	var b byte
 	var f byte
 	if enable {
@@ -1051,107 +1663,113 @@ func (s *civControlStruct) setDataMode(enable bool) error {
 		b = 0
 		f = 0
 	}
-	s.initCmd(&s.state.setDataMode, "setDataMode", []byte{254, 254, civAddress, 224, 0x1a, 0x06, b, f, 253})
-	return s.sendCmd(&s.state.setDataMode)
+	return s.submit(src, "setDataMode", []byte{254, 254, civAddress, 224, 0x1a, 0x06, b, f, 253})
 }
 
-func (s *civControlStruct) toggleDataMode() error {
-	return s.setDataMode(!s.state.dataMode)
+func (s *civControlStruct) toggleDataMode(src civSource) error {
+	return s.setDataMode(src, !s.state.dataMode)
 }
 
-func (s *civControlStruct) incBand() error {
+func (s *civControlStruct) incBand(src civSource) error {
 	i := s.state.bandIdx + 1
-	if i >= len(civBands) {
+	if i >= len(activeRadioModel.bands) {
 		i = 0
 	}
-	f := civBands[i].freq
+	f := activeRadioModel.bands[i].freq
 	if f == 0 {
-		f = (civBands[i].freqFrom + civBands[i].freqTo) / 2
+		f = (activeRadioModel.bands[i].freqFrom + activeRadioModel.bands[i].freqTo) / 2
 	}
-	return s.setMainVFOFreq(f)
+	return s.setMainVFOFreq(src, f)
 }
 
-func (s *civControlStruct) decBand() error {
+func (s *civControlStruct) decBand(src civSource) error {
 	i := s.state.bandIdx - 1
 	if i < 0 {
-		i = len(civBands) - 1
+		i = len(activeRadioModel.bands) - 1
 	}
-	f := civBands[i].freq
+	f := activeRadioModel.bands[i].freq
 	if f == 0 {
-		f = civBands[i].freqFrom
+		f = activeRadioModel.bands[i].freqFrom
 	}
-	return s.setMainVFOFreq(f)
+	return s.setMainVFOFreq(src, f)
+}
+
+func (s *civControlStruct) setPreamp(src civSource, b byte) error {
+	return s.submit(src, "setPreamp", []byte{254, 254, civAddress, 224, 0x16, 0x02, b, 253})
 }
 
-func (s *civControlStruct) togglePreamp() error {
+func (s *civControlStruct) togglePreamp(src civSource) error {
 	b := byte(s.state.preamp + 1)
 	if b > 2 {
 		b = 0
 	}
-	s.initCmd(&s.state.setPreamp, "setPreamp", []byte{254, 254, civAddress, 224, 0x16, 0x02, b, 253})
-	return s.sendCmd(&s.state.setPreamp)
+	return s.setPreamp(src, b)
+}
+
+func (s *civControlStruct) setAGC(src civSource, b byte) error {
+	return s.submit(src, "setAGC", []byte{254, 254, civAddress, 224, 0x16, 0x12, b, 253})
 }
 
-func (s *civControlStruct) toggleAGC() error {
+func (s *civControlStruct) toggleAGC(src civSource) error {
 	b := byte(s.state.agc + 1)
 	if b > 3 {
 		b = 1
 	}
-	s.initCmd(&s.state.setAGC, "setAGC", []byte{254, 254, civAddress, 224, 0x16, 0x12, b, 253})
-	return s.sendCmd(&s.state.setAGC)
+	return s.setAGC(src, b)
 }
 
-func (s *civControlStruct) toggleNR() error {
+func (s *civControlStruct) toggleNR(src civSource) error {
 	var b byte
 	if !s.state.nrEnabled {
 		b = 1
 	}
-	s.initCmd(&s.state.setNREnabled, "setNREnabled", []byte{254, 254, civAddress, 224, 0x16, 0x40, b, 253})
-	return s.sendCmd(&s.state.setNREnabled)
+	return s.submit(src, "setNREnabled", []byte{254, 254, civAddress, 224, 0x16, 0x40, b, 253})
 }
 
-func (s *civControlStruct) setTS(b byte) error {
-	s.initCmd(&s.state.setTS, "setTS", []byte{254, 254, civAddress, 224, 0x10, b, 253})
-	return s.sendCmd(&s.state.setTS)
+func (s *civControlStruct) setTS(src civSource, b byte) error {
+	return s.submit(src, "setTS", []byte{254, 254, civAddress, 224, 0x10, b, 253})
 }
 
-func (s *civControlStruct) incTS() error {
+func (s *civControlStruct) incTS(src civSource) error {
 	var b byte
-	if s.state.tsValue == 13 {
+	if int(s.state.tsValue) >= len(civTuningSteps)-1 {
 		b = 0
 	} else {
 		b = s.state.tsValue + 1
 	}
-	return s.setTS(b)
+	return s.setTS(src, b)
 }
 
-func (s *civControlStruct) decTS() error {
+func (s *civControlStruct) decTS(src civSource) error {
 	var b byte
 	if s.state.tsValue == 0 {
-		b = 13
+		b = byte(len(civTuningSteps) - 1)
 	} else {
 		b = s.state.tsValue - 1
 	}
-	return s.setTS(b)
+	return s.setTS(src, b)
 }
 
-func (s *civControlStruct) setVFO(nr byte) error {
-	s.initCmd(&s.state.setVFO, "setVFO", []byte{254, 254, civAddress, 224, 0x07, nr, 253})
-	if err := s.sendCmd(&s.state.setVFO); err != nil {
+func (s *civControlStruct) setVFO(src civSource, nr byte) error {
+	if err := s.submit(src, "setVFO", []byte{254, 254, civAddress, 224, 0x07, activeRadioModel.getVFOSelectCode(nr), 253}); err != nil {
+		return err
+	}
+	// The radio does not send the frequencies automatically.
+	if err := s.getBothVFOFreq(); err != nil {
 		return err
 	}
 	return s.getBothVFOMode()
 }
 
-func (s *civControlStruct) toggleVFO() error {
+func (s *civControlStruct) toggleVFO(src civSource) error {
 	var b byte
 	if !s.state.vfoBActive {
 		b = 1
 	}
-	return s.setVFO(b)
+	return s.setVFO(src, b)
 }
 
-func (s *civControlStruct) setSplit(mode splitMode) error {
+func (s *civControlStruct) setSplit(src civSource, mode splitMode) error {
 	var b byte
 	switch mode {
 	default:
@@ -1163,11 +1781,10 @@ func (s *civControlStruct) setSplit(mode splitMode) error {
 	case splitModeDUPPlus:
 		b = 0x12
 	}
-	s.initCmd(&s.state.setSplit, "setSplit", []byte{254, 254, civAddress, 224, 0x0f, b, 253})
-	return s.sendCmd(&s.state.setSplit)
+	return s.submit(src, "setSplit", []byte{254, 254, civAddress, 224, 0x0f, b, 253})
 }
 
-func (s *civControlStruct) toggleSplit() error {
+func (s *civControlStruct) toggleSplit(src civSource) error {
 	var mode splitMode
 	switch s.state.splitMode {
 	case splitModeOff:
@@ -1179,170 +1796,324 @@ func (s *civControlStruct) toggleSplit() error {
 	default:
 		mode = splitModeOff
 	}
-	return s.setSplit(mode)
+	return s.setSplit(src, mode)
+}
+
+func (s *civControlStruct) setScopeEnabled(src civSource, enable bool) error {
+	var b byte
+	if enable {
+		b = 1
+	}
+	if err := s.submit(src, "setScopeEnabled", []byte{254, 254, civAddress, 224, 0x27, 0x10, b, 253}); err != nil {
+		return err
+	}
+	if err := s.submit(src, "setScopeDataOutput", []byte{254, 254, civAddress, 224, 0x27, 0x11, b, 253}); err != nil {
+		return err
+	}
+
+	s.state.scopeEnabled = enable
+	if !enable {
+		scope.reset()
+		statusLog.reportScope(nil)
+		return nil
+	}
+	if err := s.getScopeMode(); err != nil {
+		return err
+	}
+	return s.getScopeSpan()
 }
 
-// func (s *civControlStruct) getFreq() error {
-// 	s.initCmd(&s.state.getFreq, "getFreq", []byte{254, 254, civAddress, 224, 3, 253})
-// 	return s.sendCmd(&s.state.getFreq)
-// }
+func (s *civControlStruct) toggleScope(src civSource) error {
+	return s.setScopeEnabled(src, !s.state.scopeEnabled)
+}
 
-// func (s *civControlStruct) getMode() error {
-// 	s.initCmd(&s.state.getMode, "getMode", []byte{254, 254, civAddress, 224, 4, 253})
-// 	return s.sendCmd(&s.state.getMode)
-// }
+func (s *civControlStruct) setScopeMode(src civSource, center bool) error {
+	var b byte
+	if !center {
+		b = 1
+	}
+	return s.submit(src, "setScopeMode", []byte{254, 254, civAddress, 224, 0x27, 0x14, 0x00, b, 253})
+}
 
-// func (s *civControlStruct) getDataMode() error {
-// 	s.initCmd(&s.state.getDataMode, "getDataMode", []byte{254, 254, civAddress, 224, 0x1a, 0x06, 253})
-// 	return s.sendCmd(&s.state.getDataMode)
-// }
+// The span is the half width of the displayed range in center mode.
+func (s *civControlStruct) setScopeSpan(src civSource, span uint) error {
+	b := s.encodeFreqData(span)
+	if err := s.submit(src, "setScopeSpan", []byte{254, 254, civAddress, 224, 0x27, 0x15, 0x00, b[0], b[1], b[2], b[3], b[4], 253}); err != nil {
+		return err
+	}
+	if !s.state.scopeCenterMode {
+		return s.setScopeMode(src, true)
+	}
+	return nil
+}
+
+func (s *civControlStruct) getScopeSpanIdx() int {
+	for i := range scopeSpans {
+		if scopeSpans[i] >= s.state.scopeSpan {
+			return i
+		}
+	}
+	return len(scopeSpans) - 1
+}
+
+func (s *civControlStruct) incScopeSpan(src civSource) error {
+	i := s.getScopeSpanIdx()
+	if i < len(scopeSpans)-1 {
+		i++
+	}
+	return s.setScopeSpan(src, scopeSpans[i])
+}
+
+func (s *civControlStruct) decScopeSpan(src civSource) error {
+	i := s.getScopeSpanIdx()
+	if i > 0 {
+		i--
+	}
+	return s.setScopeSpan(src, scopeSpans[i])
+}
+
+// Sets the lower and upper edge frequencies of the first fixed edge, and switches the scope to fixed mode.
+func (s *civControlStruct) setScopeEdges(src civSource, lower, upper uint) error {
+	if lower >= upper {
+		return fmt.Errorf("invalid scope edges %d-%d", lower, upper)
+	}
+	rangeCode, found := scope.getFixedEdgeRangeCode(lower)
+	if !found {
+		return fmt.Errorf("no scope range found for %d", lower)
+	}
+	if upperRangeCode, _ := scope.getFixedEdgeRangeCode(upper); upperRangeCode != rangeCode {
+		return fmt.Errorf("scope edges %d-%d are not in the same range", lower, upper)
+	}
+
+	l := s.encodeFreqData(lower)
+	u := s.encodeFreqData(upper)
+	if err := s.submit(src, "setScopeEdges", []byte{254, 254, civAddress, 224, 0x27, 0x1e, rangeCode, 0x01,
+		l[0], l[1], l[2], l[3], l[4], u[0], u[1], u[2], u[3], u[4], 253}); err != nil {
+		return err
+	}
+	if err := s.submit(src, "setScopeEdgeNr", []byte{254, 254, civAddress, 224, 0x27, 0x16, 0x00, 0x01, 253}); err != nil {
+		return err
+	}
+	return s.setScopeMode(src, false)
+}
 
 func (s *civControlStruct) getPwr() error {
-	s.initCmd(&s.state.getPwr, "getPwr", []byte{254, 254, civAddress, 224, 0x14, 0x0a, 253})
-	return s.sendCmd(&s.state.getPwr)
+	return s.submit(civSourceInternal, "getPwr", []byte{254, 254, civAddress, 224, 0x14, 0x0a, 253})
 }
 
 func (s *civControlStruct) getTransmitStatus() error {
-	s.initCmd(&s.state.getTransmitStatus, "getTransmitStatus", []byte{254, 254, civAddress, 224, 0x1c, 0, 253})
-	if err := s.sendCmd(&s.state.getTransmitStatus); err != nil {
+	if err := s.submit(civSourceInternal, "getTransmitStatus", []byte{254, 254, civAddress, 224, 0x1c, 0, 253}); err != nil {
 		return err
 	}
-	s.initCmd(&s.state.getTuneStatus, "getTuneStatus", []byte{254, 254, civAddress, 224, 0x1c, 1, 253})
-	return s.sendCmd(&s.state.getTuneStatus)
+	return s.submit(civSourceInternal, "getTuneStatus", []byte{254, 254, civAddress, 224, 0x1c, 1, 253})
 }
 
 func (s *civControlStruct) getPreamp() error {
-	s.initCmd(&s.state.getPreamp, "getPreamp", []byte{254, 254, civAddress, 224, 0x16, 0x02, 253})
-	return s.sendCmd(&s.state.getPreamp)
+	return s.submit(civSourceInternal, "getPreamp", []byte{254, 254, civAddress, 224, 0x16, 0x02, 253})
 }
 
 func (s *civControlStruct) getAGC() error {
-	s.initCmd(&s.state.getAGC, "getAGC", []byte{254, 254, civAddress, 224, 0x16, 0x12, 253})
-	return s.sendCmd(&s.state.getAGC)
+	return s.submit(civSourceInternal, "getAGC", []byte{254, 254, civAddress, 224, 0x16, 0x12, 253})
+}
+
+func (s *civControlStruct) getOffset() error {
+	return s.submit(civSourceInternal, "getOffset", []byte{254, 254, civAddress, 224, 0x0c, 253})
+}
+
+// Reads the tone mode, the tones and the DCS code.
+func (s *civControlStruct) getTones() error {
+	if err := s.submit(civSourceInternal, "getToneMode", []byte{254, 254, civAddress, 224, 0x16, 0x5d, 253}); err != nil {
+		return err
+	}
+	if err := s.submit(civSourceInternal, "getTone", []byte{254, 254, civAddress, 224, 0x1b, 0x00, 253}); err != nil {
+		return err
+	}
+	if err := s.submit(civSourceInternal, "getTSQLTone", []byte{254, 254, civAddress, 224, 0x1b, 0x01, 253}); err != nil {
+		return err
+	}
+	return s.submit(civSourceInternal, "getDCSCode", []byte{254, 254, civAddress, 224, 0x1b, 0x02, 253})
+}
+
+func (s *civControlStruct) getARP() error {
+	if arpMenuItem == 0 {
+		return nil
+	}
+	return s.submit(civSourceInternal, "getARP", []byte{254, 254, civAddress, 224, 0x1a, 0x05,
+		byte(arpMenuItem >> 8), byte(arpMenuItem & 0xff), 253})
+}
+
+// Reads the states and levels of the noise blanker, notch filters, speech compressor, TX monitor and VOX.
+func (s *civControlStruct) getFuncs() error {
+	if err := s.submit(civSourceInternal, "getNBEnabled", []byte{254, 254, civAddress, 224, 0x16, 0x22, 253}); err != nil {
+		return err
+	}
+	if err := s.submit(civSourceInternal, "getANFEnabled", []byte{254, 254, civAddress, 224, 0x16, 0x41, 253}); err != nil {
+		return err
+	}
+	if err := s.submit(civSourceInternal, "getMNEnabled", []byte{254, 254, civAddress, 224, 0x16, 0x48, 253}); err != nil {
+		return err
+	}
+	if err := s.submit(civSourceInternal, "getCompEnabled", []byte{254, 254, civAddress, 224, 0x16, 0x44, 253}); err != nil {
+		return err
+	}
+	if err := s.submit(civSourceInternal, "getMonEnabled", []byte{254, 254, civAddress, 224, 0x16, 0x45, 253}); err != nil {
+		return err
+	}
+	if err := s.submit(civSourceInternal, "getVOXEnabled", []byte{254, 254, civAddress, 224, 0x16, 0x46, 253}); err != nil {
+		return err
+	}
+	if err := s.submit(civSourceInternal, "getNBLevel", []byte{254, 254, civAddress, 224, 0x14, 0x12, 253}); err != nil {
+		return err
+	}
+	if err := s.submit(civSourceInternal, "getCompLevel", []byte{254, 254, civAddress, 224, 0x14, 0x0e, 253}); err != nil {
+		return err
+	}
+	if err := s.submit(civSourceInternal, "getMonGain", []byte{254, 254, civAddress, 224, 0x14, 0x15, 253}); err != nil {
+		return err
+	}
+	return s.submit(civSourceInternal, "getVOXGain", []byte{254, 254, civAddress, 224, 0x14, 0x16, 253})
+}
+
+func (s *civControlStruct) getATT() error {
+	return s.submit(civSourceInternal, "getATT", []byte{254, 254, civAddress, 224, 0x11, 253})
+}
+
+// Reads the RIT offset and the RIT/XIT states.
+func (s *civControlStruct) getRIT() error {
+	if err := s.submit(civSourceInternal, "getRITOffset", []byte{254, 254, civAddress, 224, 0x21, 0x00, 253}); err != nil {
+		return err
+	}
+	if err := s.submit(civSourceInternal, "getRITEnabled", []byte{254, 254, civAddress, 224, 0x21, 0x01, 253}); err != nil {
+		return err
+	}
+	return s.submit(civSourceInternal, "getXITEnabled", []byte{254, 254, civAddress, 224, 0x21, 0x02, 253})
+}
+
+func (s *civControlStruct) getPBT() error {
+	if err := s.submit(civSourceInternal, "getPBTIn", []byte{254, 254, civAddress, 224, 0x14, 0x07, 253}); err != nil {
+		return err
+	}
+	return s.submit(civSourceInternal, "getPBTOut", []byte{254, 254, civAddress, 224, 0x14, 0x08, 253})
+}
+
+func (s *civControlStruct) getAntenna() error {
+	return s.submit(civSourceInternal, "getAntenna", []byte{254, 254, civAddress, 224, 0x12, 253})
 }
 
 func (s *civControlStruct) getVd() error {
-	s.initCmd(&s.state.getVd, "getVd", []byte{254, 254, civAddress, 224, 0x15, 0x15, 253})
-	return s.sendCmd(&s.state.getVd)
+	return s.submit(civSourceInternal, "getVd", []byte{254, 254, civAddress, 224, 0x15, 0x15, 253})
+}
+
+func (s *civControlStruct) getS() {
+	s.poll(&s.state.pollS, "getS", []byte{254, 254, civAddress, 224, 0x15, 0x02, 253})
 }
 
-func (s *civControlStruct) getS() error {
-	s.initCmd(&s.state.getS, "getS", []byte{254, 254, civAddress, 224, 0x15, 0x02, 253})
-	return s.sendCmd(&s.state.getS)
+func (s *civControlStruct) getOVF() {
+	s.poll(&s.state.pollOVF, "getOVF", []byte{254, 254, civAddress, 224, 0x1a, 0x09, 253})
 }
 
-func (s *civControlStruct) getOVF() error {
-	s.initCmd(&s.state.getOVF, "getOVF", []byte{254, 254, civAddress, 224, 0x1a, 0x09, 253})
-	return s.sendCmd(&s.state.getOVF)
+func (s *civControlStruct) getSWR() {
+	s.poll(&s.state.pollSWR, "getSWR", []byte{254, 254, civAddress, 224, 0x15, 0x12, 253})
 }
 
-func (s *civControlStruct) getSWR() error {
-	s.initCmd(&s.state.getSWR, "getSWR", []byte{254, 254, civAddress, 224, 0x15, 0x12, 253})
-	return s.sendCmd(&s.state.getSWR)
+// Polls the meters which are only valid during TX.
+func (s *civControlStruct) getTxMeters() {
+	s.poll(&s.state.pollPo, "getPo", []byte{254, 254, civAddress, 224, 0x15, 0x11, 253})
+	s.poll(&s.state.pollALC, "getALC", []byte{254, 254, civAddress, 224, 0x15, 0x13, 253})
+	s.poll(&s.state.pollComp, "getComp", []byte{254, 254, civAddress, 224, 0x15, 0x14, 253})
+	s.poll(&s.state.pollId, "getId", []byte{254, 254, civAddress, 224, 0x15, 0x16, 253})
 }
 
 func (s *civControlStruct) getTS() error {
-	s.initCmd(&s.state.getTS, "getTS", []byte{254, 254, civAddress, 224, 0x10, 253})
-	return s.sendCmd(&s.state.getTS)
+	return s.submit(civSourceInternal, "getTS", []byte{254, 254, civAddress, 224, 0x10, 253})
 }
 
 func (s *civControlStruct) getRFGain() error {
-	s.initCmd(&s.state.getRFGain, "getRFGain", []byte{254, 254, civAddress, 224, 0x14, 0x02, 253})
-	return s.sendCmd(&s.state.getRFGain)
+	return s.submit(civSourceInternal, "getRFGain", []byte{254, 254, civAddress, 224, 0x14, 0x02, 253})
 }
 
 func (s *civControlStruct) getSQL() error {
-	s.initCmd(&s.state.getSQL, "getSQL", []byte{254, 254, civAddress, 224, 0x14, 0x03, 253})
-	return s.sendCmd(&s.state.getSQL)
+	return s.submit(civSourceInternal, "getSQL", []byte{254, 254, civAddress, 224, 0x14, 0x03, 253})
 }
 
 func (s *civControlStruct) getNR() error {
-	s.initCmd(&s.state.getNR, "getNR", []byte{254, 254, civAddress, 224, 0x14, 0x06, 253})
-	return s.sendCmd(&s.state.getNR)
+	return s.submit(civSourceInternal, "getNR", []byte{254, 254, civAddress, 224, 0x14, 0x06, 253})
 }
 
 func (s *civControlStruct) getNREnabled() error {
-	s.initCmd(&s.state.getNREnabled, "getNREnabled", []byte{254, 254, civAddress, 224, 0x16, 0x40, 253})
-	return s.sendCmd(&s.state.getNREnabled)
+	return s.submit(civSourceInternal, "getNREnabled", []byte{254, 254, civAddress, 224, 0x16, 0x40, 253})
 }
 
 func (s *civControlStruct) getSplit() error {
-	s.initCmd(&s.state.getSplit, "getSplit", []byte{254, 254, civAddress, 224, 0x0f, 253})
-	return s.sendCmd(&s.state.getSplit)
+	return s.submit(civSourceInternal, "getSplit", []byte{254, 254, civAddress, 224, 0x0f, 253})
 }
 
 func (s *civControlStruct) getBothVFOFreq() error {
-	s.initCmd(&s.state.getMainVFOFreq, "getMainVFOFreq", []byte{254, 254, civAddress, 224, 0x25, 0, 253})
-	if err := s.sendCmd(&s.state.getMainVFOFreq); err != nil {
+	if err := s.submit(civSourceInternal, "getMainVFOFreq", []byte{254, 254, civAddress, 224, 0x25, 0, 253}); err != nil {
 		return err
 	}
-	s.initCmd(&s.state.getSubVFOFreq, "getSubVFOFreq", []byte{254, 254, civAddress, 224, 0x25, 1, 253})
-	return s.sendCmd(&s.state.getSubVFOFreq)
+	return s.submit(civSourceInternal, "getSubVFOFreq", []byte{254, 254, civAddress, 224, 0x25, 1, 253})
+}
+
+func (s *civControlStruct) pollMainVFOFreq() {
+	s.poll(&s.state.pollMainVFOFreq, "getMainVFOFreq", []byte{254, 254, civAddress, 224, 0x25, 0, 253})
+}
+
+func (s *civControlStruct) pollSubVFOFreq() {
+	s.poll(&s.state.pollSubVFOFreq, "getSubVFOFreq", []byte{254, 254, civAddress, 224, 0x25, 1, 253})
 }
 
 func (s *civControlStruct) getBothVFOMode() error {
-	s.initCmd(&s.state.getMainVFOMode, "getMainVFOMode", []byte{254, 254, civAddress, 224, 0x26, 0, 253})
-	if err := s.sendCmd(&s.state.getMainVFOMode); err != nil {
+	if err := s.submit(civSourceInternal, "getMainVFOMode", []byte{254, 254, civAddress, 224, 0x26, 0, 253}); err != nil {
 		return err
 	}
-	s.initCmd(&s.state.getSubVFOMode, "getSubVFOMode", []byte{254, 254, civAddress, 224, 0x26, 1, 253})
-	return s.sendCmd(&s.state.getSubVFOMode)
+	return s.submit(civSourceInternal, "getSubVFOMode", []byte{254, 254, civAddress, 224, 0x26, 1, 253})
+}
+
+func (s *civControlStruct) getScopeMode() error {
+	return s.submit(civSourceInternal, "getScopeMode", []byte{254, 254, civAddress, 224, 0x27, 0x14, 0x00, 253})
+}
+
+func (s *civControlStruct) getScopeSpan() error {
+	return s.submit(civSourceInternal, "getScopeSpan", []byte{254, 254, civAddress, 224, 0x27, 0x15, 0x00, 253})
 }
 
 func (s *civControlStruct) loop() {
 	for {
-		s.state.mutex.Lock()
-		nextPendingCmdTimeout := time.Hour
-		for i := range s.state.pendingCmds {
-			diff := time.Since(s.state.pendingCmds[i].sentAt)
-			if diff >= commandRetryTimeout {
-				nextPendingCmdTimeout = 0
-				break
-			}
-			if diff < nextPendingCmdTimeout {
-				nextPendingCmdTimeout = diff
-			}
-		}
-		s.state.mutex.Unlock()
-
 		select {
 		case <-s.deinitNeeded:
 			s.deinitFinished <- true
 			return
 		case <-time.After(statusPollInterval):
 			if s.state.ptt || s.state.tune {
-				if !s.state.getSWR.pending && time.Since(s.state.lastSWRReceivedAt) >= statusPollInterval {
-					_ = s.getSWR()
+				if time.Since(s.state.lastSWRReceivedAt) >= statusPollInterval {
+					s.getSWR()
 				}
+				s.getTxMeters()
 			} else {
-				if !s.state.getS.pending && time.Since(s.state.lastSReceivedAt) >= statusPollInterval {
-					_ = s.getS()
+				if time.Since(s.state.lastSReceivedAt) >= statusPollInterval {
+					s.getS()
 				}
-				if !s.state.getOVF.pending && time.Since(s.state.lastOVFReceivedAt) >= statusPollInterval {
-					_ = s.getOVF()
+				if time.Since(s.state.lastOVFReceivedAt) >= statusPollInterval {
+					s.getOVF()
 				}
 			}
-			if !s.state.getMainVFOFreq.pending && !s.state.getSubVFOFreq.pending &&
-				time.Since(s.state.lastVFOFreqReceivedAt) >= statusPollInterval {
-				_ = s.getBothVFOFreq()
+			// Frequency changes of the selected VFO are sent by the radio automatically if transceive is on,
+			// but changes of the unselected VFO are not.
+			if !s.state.transceiveActive || time.Since(s.state.lastVFOFreqReceivedAt) >= transceivePollInterval {
+				s.pollMainVFOFreq()
 			}
+			s.pollSubVFOFreq()
 		case <-s.resetSReadTimer:
-		case <-s.newPendingCmdAdded:
-		case <-time.After(nextPendingCmdTimeout):
-			s.state.mutex.Lock()
-			for _, cmd := range s.state.pendingCmds {
-				if time.Since(cmd.sentAt) >= commandRetryTimeout {
-					log.Debug("retrying cmd send ", cmd.name)
-					_ = s.sendCmd(cmd)
-				}
-			}
-			s.state.mutex.Unlock()
 		}
 	}
 }
 
 func (s *civControlStruct) init(st *serialStream) error {
 	s.st = st
+	s.state.transceiveActive = false
+	civQueue.init(st.send)
+	eventBus.publishState(eventBusState{"civTransceive": false})
 
 	if err := s.getBothVFOFreq(); err != nil {
 		return err
@@ -1365,15 +2136,9 @@ func (s *civControlStruct) init(st *serialStream) error {
 	if err := s.getVd(); err != nil {
 		return err
 	}
-	if err := s.getS(); err != nil {
-		return err
-	}
-	if err := s.getOVF(); err != nil {
-		return err
-	}
-	if err := s.getSWR(); err != nil {
-		return err
-	}
+	s.getS()
+	s.getOVF()
+	s.getSWR()
 	if err := s.getTS(); err != nil {
 		return err
 	}
@@ -1392,11 +2157,43 @@ func (s *civControlStruct) init(st *serialStream) error {
 	if err := s.getSplit(); err != nil {
 		return err
 	}
+	if activeRadioModel.antennas > 1 {
+		if err := s.getAntenna(); err != nil {
+			return err
+		}
+	}
+	if err := s.getOffset(); err != nil {
+		return err
+	}
+	if err := s.getTones(); err != nil {
+		return err
+	}
+	if err := s.getARP(); err != nil {
+		return err
+	}
+	if err := s.getRIT(); err != nil {
+		return err
+	}
+	if err := s.getPBT(); err != nil {
+		return err
+	}
+	if len(activeRadioModel.attenuators) > 0 {
+		if err := s.getATT(); err != nil {
+			return err
+		}
+	}
+	if err := s.getFuncs(); err != nil {
+		return err
+	}
+	if enableScope {
+		if err := s.setScopeEnabled(civSourceInternal, true); err != nil {
+			return err
+		}
+	}
 
 	s.deinitNeeded = make(chan bool)
 	s.deinitFinished = make(chan bool)
 	s.resetSReadTimer = make(chan bool)
-	s.newPendingCmdAdded = make(chan bool)
 	go s.loop()
 	return nil
 }
@@ -1409,5 +2206,6 @@ func (s *civControlStruct) deinit() {
 	s.deinitNeeded <- true
 	<-s.deinitFinished
 	s.deinitNeeded = nil
+	civQueue.deinit()
 	s.st = nil
 }
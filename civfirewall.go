@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+type civRuleAccess int
+
+const (
+	civRuleAccessAny civRuleAccess = iota
+	civRuleAccessRead
+	civRuleAccessSet
+)
+
+// A CI-V firewall rule in the format action:source:cmd[.subcmd][:access], for example deny:tcp:1a.05:set
+type civRule struct {
+	str string
+
+	allow     bool
+	anySource bool
+	source    civSource
+	anyCmd    bool
+	cmd       byte
+	hasSubCmd bool
+	subCmd    byte
+	access    civRuleAccess
+}
+
+func parseCIVRuleHex(s string) (byte, error) {
+	s = strings.TrimPrefix(strings.ToLower(s), "0x")
+	v, err := strconv.ParseUint(s, 16, 8)
+	return byte(v), err
+}
+
+func parseCIVRule(s string) (r civRule, err error) {
+	r.str = s
+	errInvalid := errors.New("invalid CI-V rule " + s)
+
+	fields := strings.Split(s, ":")
+	if len(fields) < 3 || len(fields) > 4 {
+		return r, errInvalid
+	}
+
+	switch fields[0] {
+	case "allow":
+		r.allow = true
+	case "deny":
+	default:
+		return r, errInvalid
+	}
+
+	switch fields[1] {
+	case "*":
+		r.anySource = true
+	case civSourceTCP.String():
+		r.source = civSourceTCP
+	case civSourcePTY.String():
+		r.source = civSourcePTY
+	default:
+		return r, errInvalid
+	}
+
+	if fields[2] == "*" {
+		r.anyCmd = true
+	} else {
+		cmdFields := strings.Split(fields[2], ".")
+		if len(cmdFields) > 2 {
+			return r, errInvalid
+		}
+		if r.cmd, err = parseCIVRuleHex(cmdFields[0]); err != nil {
+			return r, errInvalid
+		}
+		if len(cmdFields) == 2 {
+			if r.subCmd, err = parseCIVRuleHex(cmdFields[1]); err != nil {
+				return r, errInvalid
+			}
+			r.hasSubCmd = true
+		}
+	}
+
+	if len(fields) == 4 {
+		switch fields[3] {
+		case "*":
+		case "read":
+			r.access = civRuleAccessRead
+		case "set":
+			r.access = civRuleAccessSet
+		default:
+			return r, errInvalid
+		}
+	}
+	return r, nil
+}
+
+// Parses a comma separated list of CI-V rules.
+func parseCIVRules(str string) (res []civRule, err error) {
+	for _, s := range strings.Split(str, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		r, err := parseCIVRule(s)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func (r *civRule) matches(f *civFrame, source civSource) bool {
+	if !r.anySource && r.source != source {
+		return false
+	}
+	if !r.anyCmd {
+		if r.cmd != f.cmd {
+			return false
+		}
+		if r.hasSubCmd && (!f.hasSubCmd || r.subCmd != f.subCmd) {
+			return false
+		}
+	}
+	switch r.access {
+	case civRuleAccessRead:
+		return f.isRead()
+	case civRuleAccessSet:
+		return !f.isRead()
+	}
+	return true
+}
+
+// Filters CI-V frames coming from raw clients on the virtual serial port and the serial TCP port.
+// The first matching rule decides, frames not matching any rule are allowed. The from address is
+// set by the client, so it is not used for skipping the rules.
+type civFirewallStruct struct{}
+
+var civFirewall civFirewallStruct
+
+func (fw *civFirewallStruct) check(f *civFrame, source civSource) error {
+	for i := range civRules {
+		if civRules[i].matches(f, source) {
+			if civRules[i].allow {
+				return nil
+			}
+			return errors.New("denied by CI-V rule " + civRules[i].str)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import "testing"
+
+func TestParseCIVRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		ok   bool
+		want civRule
+	}{
+		{rule: "deny:tcp:1a.05:set", ok: true, want: civRule{source: civSourceTCP, cmd: 0x1a, hasSubCmd: true,
+			subCmd: 0x05, access: civRuleAccessSet}},
+		{rule: "allow:*:*", ok: true, want: civRule{allow: true, anySource: true, anyCmd: true}},
+		{rule: "allow:pty:0x1C.0x00:read", ok: true, want: civRule{allow: true, source: civSourcePTY,
+			cmd: 0x1c, hasSubCmd: true, access: civRuleAccessRead}},
+		{rule: "deny:*:07", ok: true, want: civRule{anySource: true, cmd: 0x07}},
+		{rule: "deny:tcp:14:*", ok: true, want: civRule{source: civSourceTCP, cmd: 0x14}},
+		{rule: "deny:tcp"},
+		{rule: "deny:tcp:14:set:x"},
+		{rule: "drop:tcp:14"},
+		{rule: "deny:rigctld:14"},
+		{rule: "deny:tcp:1g"},
+		{rule: "deny:tcp:100"},
+		{rule: "deny:tcp:14.02.01"},
+		{rule: "deny:tcp:14.zz"},
+		{rule: "deny:tcp:14:write"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := parseCIVRule(tt.rule)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err = %v, want ok = %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			tt.want.str = tt.rule
+			if r != tt.want {
+				t.Errorf("got %+v, want %+v", r, tt.want)
+			}
+		})
+	}
+}
+
+func TestCIVRuleMatches(t *testing.T) {
+	pwrRead := []byte{0xfe, 0xfe, 0x98, 0xe0, 0x14, 0x0a, 0xfd}
+	pwrSet := []byte{0xfe, 0xfe, 0x98, 0xe0, 0x14, 0x0a, 0x01, 0x28, 0xfd}
+	pttSet := []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1c, 0x00, 0x01, 0xfd}
+
+	tests := []struct {
+		rule   string
+		d      []byte
+		source civSource
+		want   bool
+	}{
+		{"deny:*:*", pwrSet, civSourcePTY, true},
+		{"deny:tcp:*", pwrSet, civSourcePTY, false},
+		{"deny:tcp:14", pwrSet, civSourceTCP, true},
+		{"deny:tcp:14.0a", pwrRead, civSourceTCP, true},
+		{"deny:tcp:14.02", pwrSet, civSourceTCP, false},
+		{"deny:tcp:14:set", pwrSet, civSourceTCP, true},
+		{"deny:tcp:14:set", pwrRead, civSourceTCP, false},
+		{"allow:tcp:14:read", pwrRead, civSourceTCP, true},
+		{"deny:*:1c.00:set", pttSet, civSourcePTY, true},
+		{"deny:*:1c.01", pttSet, civSourcePTY, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := parseCIVRule(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, ok := parseCIVFrame(tt.d)
+			if !ok {
+				t.Fatal("can't parse frame")
+			}
+			if got := r.matches(&f, tt.source); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCIVFirewallSpoofedFromAddress(t *testing.T) {
+	prevCIVAddress, prevCIVRules := civAddress, civRules
+	t.Cleanup(func() { civAddress, civRules = prevCIVAddress, prevCIVRules })
+	civAddress = testCIVAddress
+
+	var err error
+	civRules, err = parseCIVRules("deny:tcp:14:set")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The client sets the from address to the radio's address.
+	f, ok := parseCIVFrame([]byte{0xfe, 0xfe, testCIVAddress, testCIVAddress, 0x14, 0x0a, 0x01, 0x28, 0xfd})
+	if !ok {
+		t.Fatal("can't parse frame")
+	}
+	if err := civFirewall.check(&f, civSourceTCP); err == nil {
+		t.Error("frame with spoofed from address is allowed")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+// CI-V frame format: FE FE <to> <from> <cmd> [subcmd] [data] FD
+
+// The radio sends transceive broadcasts (for example frequency changes) to this address.
+const civBroadcastAddress = 0x00
+
+type civFrame struct {
+	to        byte
+	from      byte
+	cmd       byte
+	subCmd    byte
+	hasSubCmd bool
+	data      []byte
+	// The sub command and the data.
+	payload []byte
+}
+
+// These commands have a sub command byte after the command byte.
+var civCmdsWithSubCmd = map[byte]bool{
+	0x13: true,
+	0x14: true,
+	0x15: true,
+	0x16: true,
+	0x18: true,
+	0x19: true,
+	0x1a: true,
+	0x1b: true,
+	0x1c: true,
+	0x1e: true,
+	0x21: true,
+	0x25: true,
+	0x26: true,
+	0x27: true,
+}
+
+// Returns false if d is not a complete CI-V frame.
+func parseCIVFrame(d []byte) (f civFrame, ok bool) {
+	if len(d) < 6 || d[0] != 0xfe || d[1] != 0xfe || d[len(d)-1] != 0xfd {
+		return
+	}
+
+	f.to = d[2]
+	f.from = d[3]
+	f.cmd = d[4]
+	f.data = d[5 : len(d)-1]
+	f.payload = f.data
+	if civCmdsWithSubCmd[f.cmd] && len(f.data) > 0 {
+		f.subCmd = f.data[0]
+		f.hasSubCmd = true
+		f.data = f.data[1:]
+	}
+	return f, true
+}
+
+// Reads of these commands have data bytes, for example the menu item number. The key is the command and
+// the sub command.
+var civReadDataLen = map[uint16]int{
+	0x1a00: 2, // Memory contents, memory channel number.
+	0x1a01: 2, // Band stacking register, band and register code.
+	0x1a02: 1, // Memory keyer contents, keyer channel.
+	0x1a05: 2, // Menu setting, menu item number.
+	0x1e01: 1, // TX band edge, edge number.
+	0x1e03: 1, // User TX band edge, edge number.
+	0x2714: 1, // Scope center/fixed mode, receiver.
+	0x2715: 1, // Scope span, receiver.
+	0x2716: 1, // Scope fixed edge number, receiver.
+	0x2717: 1, // Scope hold, receiver.
+	0x2719: 1, // Scope reference level, receiver.
+	0x271a: 1, // Scope sweep speed, receiver.
+	0x271d: 1, // Scope VBW, receiver.
+	0x271e: 2, // Scope fixed edge frequencies, range code and edge number.
+	0x271f: 1, // Scope RBW, receiver.
+}
+
+// Returns true if the frame sent by a controller only reads a value and does not change anything.
+func (f *civFrame) isRead() bool {
+	if f.hasSubCmd {
+		return len(f.data) <= civReadDataLen[uint16(f.cmd)<<8|uint16(f.subCmd)]
+	}
+	return len(f.data) == 0
+}
+
+// Returns true if the frame is sent by a controller (and not by the radio).
+func (f *civFrame) isFromController() bool {
+	return f.from != civAddress
+}
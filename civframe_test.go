@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseCIVFrame(t *testing.T) {
+	tests := []struct {
+		name      string
+		d         []byte
+		ok        bool
+		to        byte
+		from      byte
+		cmd       byte
+		hasSubCmd bool
+		subCmd    byte
+		data      []byte
+		payload   []byte
+	}{
+		{name: "too short", d: []byte{0xfe, 0xfe, 0x98, 0xe0, 0xfd}},
+		{name: "no start", d: []byte{0x00, 0xfe, 0x98, 0xe0, 0x03, 0xfd}},
+		{name: "no end", d: []byte{0xfe, 0xfe, 0x98, 0xe0, 0x03, 0x00}},
+		{name: "read without sub command", d: []byte{0xfe, 0xfe, 0x98, 0xe0, 0x03, 0xfd},
+			ok: true, to: 0x98, from: 0xe0, cmd: 0x03, data: []byte{}, payload: []byte{}},
+		{name: "set without sub command", d: []byte{0xfe, 0xfe, 0x98, 0xe0, 0x07, 0x01, 0xfd},
+			ok: true, to: 0x98, from: 0xe0, cmd: 0x07, data: []byte{0x01}, payload: []byte{0x01}},
+		{name: "read with sub command", d: []byte{0xfe, 0xfe, 0x98, 0xe0, 0x15, 0x02, 0xfd},
+			ok: true, to: 0x98, from: 0xe0, cmd: 0x15, hasSubCmd: true, subCmd: 0x02, data: []byte{},
+			payload: []byte{0x02}},
+		{name: "reply with sub command", d: []byte{0xfe, 0xfe, 0xe0, 0x98, 0x14, 0x0a, 0x01, 0x28, 0xfd},
+			ok: true, to: 0xe0, from: 0x98, cmd: 0x14, hasSubCmd: true, subCmd: 0x0a, data: []byte{0x01, 0x28},
+			payload: []byte{0x0a, 0x01, 0x28}},
+		{name: "sub command missing", d: []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1c, 0xfd},
+			ok: true, to: 0x98, from: 0xe0, cmd: 0x1c, data: []byte{}, payload: []byte{}},
+		{name: "ok reply", d: []byte{0xfe, 0xfe, 0xe0, 0x98, 0xfb, 0xfd},
+			ok: true, to: 0xe0, from: 0x98, cmd: 0xfb, data: []byte{}, payload: []byte{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ok := parseCIVFrame(tt.d)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if f.to != tt.to || f.from != tt.from || f.cmd != tt.cmd {
+				t.Errorf("to/from/cmd = %02x/%02x/%02x, want %02x/%02x/%02x", f.to, f.from, f.cmd,
+					tt.to, tt.from, tt.cmd)
+			}
+			if f.hasSubCmd != tt.hasSubCmd || f.subCmd != tt.subCmd {
+				t.Errorf("sub command = %v %02x, want %v %02x", f.hasSubCmd, f.subCmd, tt.hasSubCmd, tt.subCmd)
+			}
+			if !bytes.Equal(f.data, tt.data) {
+				t.Errorf("data = % x, want % x", f.data, tt.data)
+			}
+			if !bytes.Equal(f.payload, tt.payload) {
+				t.Errorf("payload = % x, want % x", f.payload, tt.payload)
+			}
+		})
+	}
+}
+
+func TestCIVFrameIsRead(t *testing.T) {
+	tests := []struct {
+		name string
+		d    []byte
+		want bool
+	}{
+		{"frequency read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x03, 0xfd}, true},
+		{"frequency set", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x05, 0x00, 0x00, 0x07, 0x14, 0x00, 0xfd}, false},
+		{"S meter read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x15, 0x02, 0xfd}, true},
+		{"power read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x14, 0x0a, 0xfd}, true},
+		{"power set", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x14, 0x0a, 0x01, 0x28, 0xfd}, false},
+		{"transmit status read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1c, 0x00, 0xfd}, true},
+		{"ptt set", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1c, 0x00, 0x01, 0xfd}, false},
+		{"menu read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1a, 0x05, 0x01, 0x17, 0xfd}, true},
+		{"menu set", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1a, 0x05, 0x01, 0x17, 0x01, 0xfd}, false},
+		{"memory contents read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1a, 0x00, 0x00, 0x01, 0xfd}, true},
+		{"band stacking register read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1a, 0x01, 0x03, 0x01, 0xfd}, true},
+		{"data mode read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1a, 0x06, 0xfd}, true},
+		{"data mode set", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1a, 0x06, 0x01, 0x01, 0xfd}, false},
+		{"scope mode read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x27, 0x14, 0x00, 0xfd}, true},
+		{"scope mode set", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x27, 0x14, 0x00, 0x01, 0xfd}, false},
+		{"scope span read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x27, 0x15, 0x00, 0xfd}, true},
+		{"scope fixed edges read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x27, 0x1e, 0x06, 0x01, 0xfd}, true},
+		{"scope enable", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x27, 0x10, 0x01, 0xfd}, false},
+		{"tx band edge read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1e, 0x01, 0x02, 0xfd}, true},
+		{"vfo read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x25, 0x01, 0xfd}, true},
+		{"vfo set", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x25, 0x01, 0x00, 0x00, 0x07, 0x14, 0x00, 0xfd}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ok := parseCIVFrame(tt.d)
+			if !ok {
+				t.Fatal("can't parse frame")
+			}
+			if got := f.isRead(); got != tt.want {
+				t.Errorf("isRead() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
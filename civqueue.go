@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Requests with higher priority are sent first.
+type civPriority int
+
+const (
+	civPriorityPoll   civPriority = iota // Periodic meter and frequency polls.
+	civPriorityNormal                    // Commands sent by kappanhang itself.
+	civPriorityUser                      // Commands coming from the user (hotkeys, rigctld, API).
+	civPriorityCount
+)
+
+const civMaxInFlight = 4
+
+// Unanswered commands are sent again this many times, then they fail with a timeout error.
+const civMaxRetries = 5
+
+// Sent frames are forgotten after this time if the radio does not reply to them.
+const civSentFrameTimeout = 2 * commandRetryTimeout
+
+var errCIVNG = errors.New("radio replied NG")
+var errCIVTimeout = errors.New("no reply from the radio")
+var errCIVQueueClosed = errors.New("CI-V connection closed")
+
+type civResult struct {
+	reply civFrame
+	err   error
+}
+
+// Receives the result of a request. It can be waited for by multiple goroutines.
+type civFuture struct {
+	done chan bool
+	res  civResult
+}
+
+func newCIVFuture() *civFuture {
+	return &civFuture{done: make(chan bool)}
+}
+
+func (f *civFuture) set(res civResult) {
+	f.res = res
+	close(f.done)
+}
+
+func (f *civFuture) isDone() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Blocks until the request is finished. Do not call this with civControl.state.mutex locked.
+func (f *civFuture) wait() (civFrame, error) {
+	<-f.done
+	return f.res.reply, f.res.err
+}
+
+type civRequest struct {
+	name     string
+	frame    []byte
+	source   civSource
+	priority civPriority
+
+	// Returns true if the given frame is the reply for this request. If nil, then the request is finished
+	// when the radio replies OK (set commands) or sends back the same command with data (reads).
+	match func(f *civFrame) bool
+	// Called when the request is finished, with civControl.state.mutex locked.
+	callback func(reply *civFrame, err error)
+
+	retryTimeout time.Duration
+	maxRetries   int
+
+	result   *civFuture
+	res      civResult
+	hasReply bool
+	isRead   bool
+	sent     bool
+	sentAt   time.Time
+	retries  int
+}
+
+// Returns true if f is the radio's answer with data to the read request req.
+func isCIVReadReply(req *civFrame, f *civFrame) bool {
+	if f.isFromController() || req.cmd != f.cmd || req.hasSubCmd != f.hasSubCmd || req.subCmd != f.subCmd {
+		return false
+	}
+	// Reads with data (like menu settings) are answered with the same data followed by the value.
+	if len(f.data) <= len(req.data) {
+		return false
+	}
+	for i := range req.data {
+		if req.data[i] != f.data[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// A frame sent to the radio, which is waiting for the radio's echo and reply.
+type civSentFrame struct {
+	req    *civRequest // Nil for frames sent by the serial clients.
+	source civSource
+	raw    []byte
+	frame  civFrame
+	isRead bool
+	echoed bool
+	sentAt time.Time
+}
+
+// Returns true if the given frame is the data reply for the sent frame.
+func (e *civSentFrame) matches(f *civFrame) bool {
+	if e.req != nil && e.req.match != nil {
+		return e.req.match(f)
+	}
+	return e.isRead && isCIVReadReply(&e.frame, f)
+}
+
+// Sends CI-V commands to the radio, matches the replies and retries commands which are not answered.
+// Lock order: civControl.state.mutex first, then the queue's mutex.
+type civQueueStruct struct {
+	mutex sync.Mutex
+	send  func(d []byte) error
+
+	queued   [civPriorityCount][]*civRequest
+	inFlight []*civRequest
+	// Every frame sent to the radio in order, including the frames of the serial clients. The radio
+	// processes frames in order, so OK/NG replies belong to the oldest frame.
+	sent []*civSentFrame
+	// Finished requests, their results are delivered after the mutex is unlocked.
+	finished []*civRequest
+
+	running        bool
+	wakeChan       chan bool
+	deinitNeeded   chan bool
+	deinitFinished chan bool
+}
+
+var civQueue civQueueStruct
+
+func (q *civQueueStruct) wake() {
+	select {
+	case q.wakeChan <- true:
+	default:
+	}
+}
+
+func (q *civQueueStruct) newRequest(src civSource, name string, frame []byte) *civRequest {
+	r := &civRequest{
+		name:         name,
+		frame:        frame,
+		source:       src,
+		retryTimeout: commandRetryTimeout,
+		maxRetries:   civMaxRetries,
+	}
+	r.priority = civPriorityNormal
+	if r.source != civSourceInternal {
+		r.priority = civPriorityUser
+	}
+	return r
+}
+
+// Adds the request to the queue.
+func (q *civQueueStruct) submit(r *civRequest) *civFuture {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.add(r)
+}
+
+func (q *civQueueStruct) add(r *civRequest) *civFuture {
+	r.result = newCIVFuture()
+	if f, ok := parseCIVFrame(r.frame); ok {
+		r.isRead = f.isRead()
+	}
+
+	if !q.running {
+		r.result.set(civResult{err: errCIVQueueClosed})
+		return r.result
+	}
+
+	q.queued[r.priority] = append(q.queued[r.priority], r)
+	q.wake()
+	return r.result
+}
+
+// Should be called with the mutex locked.
+func (q *civQueueStruct) finish(i int, reply *civFrame, err error) {
+	r := q.inFlight[i]
+	q.inFlight = append(q.inFlight[:i], q.inFlight[i+1:]...)
+
+	if reply != nil {
+		r.res.reply = *reply
+		r.hasReply = true
+	}
+	r.res.err = err
+	if err != nil {
+		log.Debugw("cmd failed", "cmd", r.name, "err", err.Error())
+	}
+	q.finished = append(q.finished, r)
+	q.wake()
+}
+
+// Delivers the results of the finished requests. Should be called with civControl.state.mutex locked, but
+// with the queue's mutex unlocked, so callbacks can submit new requests.
+func (q *civQueueStruct) deliverResults() {
+	q.mutex.Lock()
+	finished := q.finished
+	q.finished = nil
+	q.mutex.Unlock()
+
+	for _, r := range finished {
+		r.result.set(r.res)
+		if r.callback != nil {
+			var reply *civFrame
+			if r.hasReply {
+				reply = &r.res.reply
+			}
+			r.callback(reply, r.res.err)
+		}
+	}
+}
+
+// Called for each frame received from the radio, with civControl.state.mutex locked. Returns true if the
+// frame is the echo of or the reply to one of our requests, and should not be forwarded to the serial clients.
+func (q *civQueueStruct) handleReply(f *civFrame, d []byte) bool {
+	consumed, refused := q.matchReply(f, d)
+	q.deliverResults()
+	if refused != nil {
+		pttArbiter.reportCIVFrameRefused(refused)
+	}
+	return consumed
+}
+
+// Also returns the sent frame if the radio has refused it.
+func (q *civQueueStruct) matchReply(f *civFrame, d []byte) (consumed bool, refused *civFrame) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.expireSent()
+
+	// The radio echoes back every frame it receives.
+	if f.isFromController() {
+		for _, e := range q.sent {
+			if !e.echoed && bytes.Equal(e.raw, d) {
+				e.echoed = true
+				return e.req != nil, nil
+			}
+		}
+		return false, nil
+	}
+
+	if f.to != 224 {
+		return false, nil
+	}
+
+	i := q.findSent(f)
+	if i < 0 {
+		return false, nil
+	}
+	e := q.sent[i]
+	// Older frames won't be answered anymore, as the radio replies in order.
+	q.sent = q.sent[i+1:]
+	if f.cmd == 0xfa {
+		refused = &e.frame
+	}
+
+	if e.req == nil { // Replies to the serial clients are forwarded to them.
+		return false, refused
+	}
+	for j, r := range q.inFlight {
+		if r == e.req {
+			if f.cmd == 0xfa {
+				q.finish(j, f, errCIVNG)
+			} else {
+				q.finish(j, f, nil)
+			}
+			break
+		}
+	}
+	// Late replies to already finished requests (for example to retries) are not forwarded either.
+	return true, refused
+}
+
+// Returns the index of the sent frame which the given reply belongs to, or -1. Should be called with the
+// mutex locked.
+func (q *civQueueStruct) findSent(f *civFrame) int {
+	if len(q.sent) == 0 {
+		return -1
+	}
+	switch f.cmd {
+	case 0xfa: // Both reads and sets can be refused.
+		return 0
+	case 0xfb: // Reads are answered with data, not with OK.
+		for i, e := range q.sent {
+			if !e.isRead {
+				return i
+			}
+		}
+		return 0
+	}
+	for i, e := range q.sent {
+		if e.matches(f) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Should be called with the mutex locked.
+func (q *civQueueStruct) expireSent() {
+	for len(q.sent) > 0 && time.Since(q.sent[0].sentAt) >= civSentFrameTimeout {
+		q.sent = q.sent[1:]
+	}
+}
+
+// Sends the frame and stores it for matching the reply. Should be called with the mutex locked.
+func (q *civQueueStruct) sendFrame(r *civRequest, source civSource, d []byte) error {
+	e := &civSentFrame{
+		req:    r,
+		source: source,
+		raw:    append([]byte{}, d...),
+		sentAt: time.Now(),
+	}
+	var ok bool
+	if e.frame, ok = parseCIVFrame(e.raw); ok {
+		e.isRead = e.frame.isRead()
+	}
+	q.sent = append(q.sent, e)
+	return q.send(d)
+}
+
+// Sends a frame coming from a serial client to the radio. The radio's reply is forwarded to the clients.
+func (q *civQueueStruct) sendClientFrame(d []byte, source civSource) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if !q.running {
+		return errCIVQueueClosed
+	}
+	return q.sendFrame(nil, source, d)
+}
+
+// Should be called with the mutex locked.
+func (q *civQueueStruct) sendRequest(r *civRequest) {
+	r.sent = true
+	r.sentAt = time.Now()
+	civTrace.log(true, r.source, r.frame, false)
+	if err := q.sendFrame(r, r.source, r.frame); err != nil {
+		reportError(err)
+	}
+}
+
+// Retries unanswered requests and sends queued requests if there is a free
+// slot. Returns the time until the next retry. Should be called with civControl.state.mutex locked.
+func (q *civQueueStruct) process() time.Duration {
+	defer q.deliverResults()
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i := 0; i < len(q.inFlight); {
+		r := q.inFlight[i]
+		if r.sent && time.Since(r.sentAt) >= r.retryTimeout {
+			if r.retries >= r.maxRetries {
+				q.finish(i, nil, errCIVTimeout)
+				continue
+			}
+			r.retries++
+			log.Debugw("retrying cmd send", "cmd", r.name, "retry", r.retries)
+			r.sent = false
+		}
+		if !r.sent {
+			q.sendRequest(r)
+		}
+		i++
+	}
+
+	for p := civPriorityCount - 1; p >= 0 && len(q.inFlight) < civMaxInFlight; {
+		if len(q.queued[p]) == 0 {
+			p--
+			continue
+		}
+		r := q.queued[p][0]
+		q.queued[p] = q.queued[p][1:]
+		q.inFlight = append(q.inFlight, r)
+		q.sendRequest(r)
+	}
+
+	next := time.Hour
+	for _, r := range q.inFlight {
+		if d := r.retryTimeout - time.Since(r.sentAt); d < next {
+			next = d
+		}
+	}
+	if next < 0 {
+		next = 0
+	}
+	return next
+}
+
+func (q *civQueueStruct) loop() {
+	next := time.Duration(0)
+	for {
+		select {
+		case <-q.deinitNeeded:
+			civControl.state.mutex.Lock()
+			q.mutex.Lock()
+			for len(q.inFlight) > 0 {
+				q.finish(0, nil, errCIVQueueClosed)
+			}
+			for p := range q.queued {
+				for _, r := range q.queued[p] {
+					r.result.set(civResult{err: errCIVQueueClosed})
+				}
+				q.queued[p] = nil
+			}
+			q.sent = nil
+			q.running = false
+			q.mutex.Unlock()
+			q.deliverResults()
+			civControl.state.mutex.Unlock()
+
+			q.deinitFinished <- true
+			return
+		case <-q.wakeChan:
+		case <-time.After(next):
+		}
+
+		civControl.state.mutex.Lock()
+		next = q.process()
+		civControl.state.mutex.Unlock()
+	}
+}
+
+func (q *civQueueStruct) init(send func(d []byte) error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.send = send
+	q.sent = nil
+	q.running = true
+	if q.wakeChan == nil {
+		q.wakeChan = make(chan bool, 1)
+	}
+	q.deinitNeeded = make(chan bool)
+	q.deinitFinished = make(chan bool)
+	go q.loop()
+}
+
+func (q *civQueueStruct) deinit() {
+	if q.deinitNeeded == nil {
+		return
+	}
+
+	q.deinitNeeded <- true
+	<-q.deinitFinished
+	q.deinitNeeded = nil
+}
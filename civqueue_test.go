@@ -0,0 +1,180 @@
+package main
+
+import "testing"
+
+const testCIVAddress = 0x98
+
+func newTestCIVQueue(t *testing.T) (q *civQueueStruct, sent *[][]byte) {
+	log.Init()
+	prevCIVAddress := civAddress
+	civAddress = testCIVAddress
+	t.Cleanup(func() { civAddress = prevCIVAddress })
+
+	sent = &[][]byte{}
+	q = &civQueueStruct{
+		running:  true,
+		wakeChan: make(chan bool, 1),
+		send: func(d []byte) error {
+			*sent = append(*sent, append([]byte{}, d...))
+			return nil
+		},
+	}
+	return q, sent
+}
+
+// Returns true if the frame should be forwarded to the serial clients.
+func testCIVRadioFrame(t *testing.T, q *civQueueStruct, d []byte) bool {
+	f, ok := parseCIVFrame(d)
+	if !ok {
+		t.Fatalf("can't parse frame % x", d)
+	}
+	return !q.handleReply(&f, d)
+}
+
+func testCIVResult(t *testing.T, res *civFuture) error {
+	select {
+	case <-res.done:
+		return res.res.err
+	default:
+		t.Fatal("request not finished")
+	}
+	return nil
+}
+
+var testCIVPwrSet = []byte{0xfe, 0xfe, testCIVAddress, 0xe0, 0x14, 0x0a, 0x01, 0x28, 0xfd}
+var testCIVPwrRead = []byte{0xfe, 0xfe, testCIVAddress, 0xe0, 0x14, 0x0a, 0xfd}
+var testCIVPwrReply = []byte{0xfe, 0xfe, 0xe0, testCIVAddress, 0x14, 0x0a, 0x01, 0x28, 0xfd}
+var testCIVSQLSet = []byte{0xfe, 0xfe, testCIVAddress, 0xe0, 0x14, 0x03, 0x00, 0x50, 0xfd}
+var testCIVOK = []byte{0xfe, 0xfe, 0xe0, testCIVAddress, 0xfb, 0xfd}
+var testCIVNG = []byte{0xfe, 0xfe, 0xe0, testCIVAddress, 0xfa, 0xfd}
+
+func TestCIVQueueClientReplyForwarded(t *testing.T) {
+	q, _ := newTestCIVQueue(t)
+
+	if err := q.sendClientFrame(testCIVSQLSet, civSourceTCP); err != nil {
+		t.Fatal(err)
+	}
+	res := q.submit(q.newRequest(civSourceInternal, "setPwr", testCIVPwrSet))
+	q.process()
+
+	if !testCIVRadioFrame(t, q, testCIVSQLSet) {
+		t.Error("echo of the client's frame is not forwarded")
+	}
+	if testCIVRadioFrame(t, q, testCIVPwrSet) {
+		t.Error("echo of our frame is forwarded")
+	}
+	if !testCIVRadioFrame(t, q, testCIVOK) {
+		t.Error("OK for the client's frame is not forwarded")
+	}
+	select {
+	case <-res.done:
+		t.Fatal("request finished with the client's OK")
+	default:
+	}
+	if testCIVRadioFrame(t, q, testCIVOK) {
+		t.Error("OK for our frame is forwarded")
+	}
+	if err := testCIVResult(t, res); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCIVQueueNG(t *testing.T) {
+	q, _ := newTestCIVQueue(t)
+
+	res := q.submit(q.newRequest(civSourceRigctld, "setPwr", testCIVPwrSet))
+	q.process()
+	if err := q.sendClientFrame(testCIVSQLSet, civSourcePTY); err != nil {
+		t.Fatal(err)
+	}
+
+	if testCIVRadioFrame(t, q, testCIVNG) {
+		t.Error("NG for our frame is forwarded")
+	}
+	if err := testCIVResult(t, res); err != errCIVNG {
+		t.Errorf("err = %v, want %v", err, errCIVNG)
+	}
+	if !testCIVRadioFrame(t, q, testCIVOK) {
+		t.Error("OK for the client's frame is not forwarded")
+	}
+}
+
+func TestCIVQueueReadReply(t *testing.T) {
+	q, _ := newTestCIVQueue(t)
+
+	if err := q.sendClientFrame(testCIVSQLSet, civSourceTCP); err != nil {
+		t.Fatal(err)
+	}
+	res := q.submit(q.newRequest(civSourceInternal, "getPwr", testCIVPwrRead))
+	q.process()
+
+	if !testCIVRadioFrame(t, q, testCIVOK) {
+		t.Error("OK for the client's frame is not forwarded")
+	}
+	if testCIVRadioFrame(t, q, testCIVPwrReply) {
+		t.Error("reply to our read is forwarded")
+	}
+	if err := testCIVResult(t, res); err != nil {
+		t.Error(err)
+	}
+	if !testCIVRadioFrame(t, q, testCIVPwrReply) {
+		t.Error("unrequested frame is not forwarded")
+	}
+}
+
+func TestCIVQueueClientReadReplyForwarded(t *testing.T) {
+	q, _ := newTestCIVQueue(t)
+
+	if err := q.sendClientFrame(testCIVPwrRead, civSourceTCP); err != nil {
+		t.Fatal(err)
+	}
+	res := q.submit(q.newRequest(civSourceInternal, "getPwr", testCIVPwrRead))
+	q.process()
+
+	if !testCIVRadioFrame(t, q, testCIVPwrReply) {
+		t.Error("reply to the client's read is not forwarded")
+	}
+	if testCIVRadioFrame(t, q, testCIVPwrReply) {
+		t.Error("reply to our read is forwarded")
+	}
+	if err := testCIVResult(t, res); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCIVQueueRetriedRequest(t *testing.T) {
+	q, sent := newTestCIVQueue(t)
+
+	res1 := q.submit(q.newRequest(civSourceInternal, "setPwr", testCIVPwrSet))
+	q.process()
+	// Simulate a retry.
+	q.inFlight[0].sent = false
+	q.process()
+	res2 := q.submit(q.newRequest(civSourceInternal, "setSQL", testCIVSQLSet))
+	q.process()
+	if len(*sent) != 3 {
+		t.Fatalf("sent %d frames, want 3", len(*sent))
+	}
+
+	if testCIVRadioFrame(t, q, testCIVOK) {
+		t.Error("OK is forwarded")
+	}
+	if err := testCIVResult(t, res1); err != nil {
+		t.Error(err)
+	}
+	// The OK for the retry must not finish the next request.
+	if testCIVRadioFrame(t, q, testCIVOK) {
+		t.Error("OK for the retry is forwarded")
+	}
+	select {
+	case <-res2.done:
+		t.Fatal("request finished with the OK of the previous request's retry")
+	default:
+	}
+	if testCIVRadioFrame(t, q, testCIVOK) {
+		t.Error("OK is forwarded")
+	}
+	if err := testCIVResult(t, res2); err != nil {
+		t.Error(err)
+	}
+}
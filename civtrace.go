@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The source of a CI-V frame sent to the radio.
+type civSource int32
+
+const (
+	civSourceInternal civSource = iota // Status polling and other commands sent by kappanhang itself.
+	civSourceRigctld
+	civSourceTCP
+	civSourcePTY
+	civSourceHotkey
+	civSourceAPI // HTTP and MQTT.
+	civSourceRadio
+)
+
+var civSourceNames = []string{"internal", "rigctld", "tcp", "pty", "hotkey", "api", "radio"}
+
+func (s civSource) String() string {
+	if int(s) < len(civSourceNames) {
+		return civSourceNames[s]
+	}
+	return "unknown"
+}
+
+type civValueFormat int
+
+const (
+	civValueNone civValueFormat = iota
+	civValueRaw
+	civValueLevel
+	civValueFreq
+	civValueMode
+	civValueOnOff
+)
+
+type civCmdDesc struct {
+	cmd    byte
+	subCmd int // -1 if the command has no sub command.
+	name   string
+	format civValueFormat
+}
+
+var civCmdDescs = []civCmdDesc{
+	{cmd: 0x00, subCmd: -1, name: "transceive freq", format: civValueFreq},
+	{cmd: 0x01, subCmd: -1, name: "transceive mode", format: civValueMode},
+	{cmd: 0x03, subCmd: -1, name: "freq", format: civValueFreq},
+	{cmd: 0x04, subCmd: -1, name: "mode", format: civValueMode},
+	{cmd: 0x05, subCmd: -1, name: "freq", format: civValueFreq},
+	{cmd: 0x06, subCmd: -1, name: "mode", format: civValueMode},
+	{cmd: 0x07, subCmd: -1, name: "VFO", format: civValueRaw},
+	{cmd: 0x0c, subCmd: -1, name: "repeater offset", format: civValueRaw},
+	{cmd: 0x0d, subCmd: -1, name: "repeater offset", format: civValueRaw},
+	{cmd: 0x0f, subCmd: -1, name: "split", format: civValueRaw},
+	{cmd: 0x10, subCmd: -1, name: "tuning step", format: civValueRaw},
+	{cmd: 0x11, subCmd: -1, name: "attenuator", format: civValueRaw},
+	{cmd: 0x12, subCmd: -1, name: "antenna", format: civValueRaw},
+	{cmd: 0x14, subCmd: 0x01, name: "AF gain", format: civValueLevel},
+	{cmd: 0x14, subCmd: 0x02, name: "RF gain", format: civValueLevel},
+	{cmd: 0x14, subCmd: 0x03, name: "squelch", format: civValueLevel},
+	{cmd: 0x14, subCmd: 0x06, name: "NR level", format: civValueLevel},
+	{cmd: 0x14, subCmd: 0x07, name: "PBT inner", format: civValueLevel},
+	{cmd: 0x14, subCmd: 0x08, name: "PBT outer", format: civValueLevel},
+	{cmd: 0x14, subCmd: 0x0a, name: "RF power", format: civValueLevel},
+	{cmd: 0x14, subCmd: 0x0e, name: "COMP level", format: civValueLevel},
+	{cmd: 0x14, subCmd: 0x12, name: "NB level", format: civValueLevel},
+	{cmd: 0x14, subCmd: 0x15, name: "monitor gain", format: civValueLevel},
+	{cmd: 0x14, subCmd: 0x16, name: "VOX gain", format: civValueLevel},
+	{cmd: 0x15, subCmd: 0x01, name: "squelch status", format: civValueRaw},
+	{cmd: 0x15, subCmd: 0x02, name: "S meter", format: civValueRaw},
+	{cmd: 0x15, subCmd: 0x11, name: "Po meter", format: civValueRaw},
+	{cmd: 0x15, subCmd: 0x12, name: "SWR meter", format: civValueRaw},
+	{cmd: 0x15, subCmd: 0x13, name: "ALC meter", format: civValueRaw},
+	{cmd: 0x15, subCmd: 0x14, name: "COMP meter", format: civValueRaw},
+	{cmd: 0x15, subCmd: 0x15, name: "Vd meter", format: civValueRaw},
+	{cmd: 0x15, subCmd: 0x16, name: "Id meter", format: civValueRaw},
+	{cmd: 0x16, subCmd: 0x02, name: "preamp", format: civValueRaw},
+	{cmd: 0x16, subCmd: 0x12, name: "AGC", format: civValueRaw},
+	{cmd: 0x16, subCmd: 0x22, name: "NB", format: civValueOnOff},
+	{cmd: 0x16, subCmd: 0x40, name: "NR", format: civValueOnOff},
+	{cmd: 0x16, subCmd: 0x41, name: "auto notch", format: civValueOnOff},
+	{cmd: 0x16, subCmd: 0x44, name: "COMP", format: civValueOnOff},
+	{cmd: 0x16, subCmd: 0x45, name: "monitor", format: civValueOnOff},
+	{cmd: 0x16, subCmd: 0x46, name: "VOX", format: civValueOnOff},
+	{cmd: 0x16, subCmd: 0x48, name: "manual notch", format: civValueOnOff},
+	{cmd: 0x16, subCmd: 0x5d, name: "tone mode", format: civValueRaw},
+	{cmd: 0x1a, subCmd: 0x05, name: "menu setting", format: civValueRaw},
+	{cmd: 0x1a, subCmd: 0x06, name: "data mode", format: civValueRaw},
+	{cmd: 0x1a, subCmd: 0x09, name: "OVF status", format: civValueOnOff},
+	{cmd: 0x1b, subCmd: 0x00, name: "repeater tone", format: civValueRaw},
+	{cmd: 0x1b, subCmd: 0x01, name: "TSQL tone", format: civValueRaw},
+	{cmd: 0x1b, subCmd: 0x02, name: "DTCS code", format: civValueRaw},
+	{cmd: 0x1c, subCmd: 0x00, name: "PTT", format: civValueOnOff},
+	{cmd: 0x1c, subCmd: 0x01, name: "ATU", format: civValueRaw},
+	{cmd: 0x21, subCmd: 0x00, name: "RIT offset", format: civValueRaw},
+	{cmd: 0x21, subCmd: 0x01, name: "RIT", format: civValueOnOff},
+	{cmd: 0x21, subCmd: 0x02, name: "XIT", format: civValueOnOff},
+	{cmd: 0x25, subCmd: 0x00, name: "selected VFO freq", format: civValueFreq},
+	{cmd: 0x25, subCmd: 0x01, name: "unselected VFO freq", format: civValueFreq},
+	{cmd: 0x26, subCmd: 0x00, name: "selected VFO mode", format: civValueMode},
+	{cmd: 0x26, subCmd: 0x01, name: "unselected VFO mode", format: civValueMode},
+	{cmd: 0x27, subCmd: 0x00, name: "scope waveform data", format: civValueNone},
+	{cmd: 0x27, subCmd: 0x10, name: "scope", format: civValueOnOff},
+	{cmd: 0x27, subCmd: 0x11, name: "scope data output", format: civValueOnOff},
+	{cmd: 0x27, subCmd: 0x14, name: "scope mode", format: civValueRaw},
+	{cmd: 0x27, subCmd: 0x15, name: "scope span", format: civValueRaw},
+	{cmd: 0xfa, subCmd: -1, name: "NG", format: civValueNone},
+	{cmd: 0xfb, subCmd: -1, name: "OK", format: civValueNone},
+}
+
+type civTraceStruct struct{}
+
+var civTrace civTraceStruct
+
+func (t *civTraceStruct) findDesc(f *civFrame) *civCmdDesc {
+	for i := range civCmdDescs {
+		d := &civCmdDescs[i]
+		if d.cmd != f.cmd {
+			continue
+		}
+		if d.subCmd < 0 || (f.hasSubCmd && byte(d.subCmd) == f.subCmd) {
+			return d
+		}
+	}
+	return nil
+}
+
+func (t *civTraceStruct) formatHex(d []byte) string {
+	var sb strings.Builder
+	for i, b := range d {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		fmt.Fprintf(&sb, "%02x", b)
+	}
+	return sb.String()
+}
+
+func (t *civTraceStruct) formatValue(format civValueFormat, d []byte) string {
+	switch format {
+	case civValueLevel:
+		if len(d) >= 2 {
+			hex := uint16(d[0])<<8 | uint16(d[1])
+			return fmt.Sprint(int(math.Round((float64(hex)/0x0255)*100)), "%")
+		}
+	case civValueFreq:
+		return fmt.Sprint(civControl.decodeFreqData(d), " Hz")
+	case civValueMode:
+		res := fmt.Sprintf("0x%02x", d[0])
+		for i := range activeRadioModel.modes {
+			if activeRadioModel.modes[i].code == d[0] {
+				res = activeRadioModel.modes[i].name
+				break
+			}
+		}
+		if len(d) > 1 {
+			res += " " + getFilterName(civControl.decodeFilterValueToFilterIdx(d[len(d)-1]))
+		}
+		return res
+	case civValueOnOff:
+		if d[0] == 1 {
+			return "on"
+		}
+		return "off"
+	case civValueNone:
+		return ""
+	}
+	return t.formatHex(d)
+}
+
+// Returns a description of the frame, for example "0x14 0x0a set RF power 50%".
+func (t *civTraceStruct) describe(f *civFrame) string {
+	res := fmt.Sprintf("0x%02x", f.cmd)
+	if f.hasSubCmd {
+		res += fmt.Sprintf(" 0x%02x", f.subCmd)
+	}
+
+	desc := t.findDesc(f)
+	if desc == nil {
+		return res + " unknown"
+	}
+
+	switch {
+	case f.cmd == 0xfa || f.cmd == 0xfb:
+	case !f.isFromController():
+	case len(f.data) == 0:
+		res += " read"
+	default:
+		res += " set"
+	}
+	res += " " + desc.name
+
+	if len(f.data) > 0 {
+		if v := t.formatValue(desc.format, f.data); v != "" {
+			res += " " + v
+		}
+	}
+	return res
+}
+
+func (t *civTraceStruct) log(toRadio bool, source civSource, d []byte, filtered bool) {
+	if !civTraceEnabled {
+		return
+	}
+
+	var dir, desc string
+	if toRadio {
+		dir = "to radio"
+	} else {
+		dir = "from radio"
+	}
+	if f, ok := parseCIVFrame(d); ok {
+		desc = t.describe(&f)
+	} else {
+		desc = "incomplete frame"
+	}
+	log.Printw("civ", "dir", dir, "source", source.String(), "frame", t.formatHex(d), "desc", desc,
+		"filtered", filtered)
+}
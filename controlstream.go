@@ -4,7 +4,8 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/binary"
-	"errors"
+	"fmt"
+	"net"
 	"time"
 )
 
@@ -15,6 +16,9 @@ const audioStreamPort = 50003
 const reauthInterval = time.Minute
 const reauthTimeout = 3 * time.Second
 
+const requestSerialAndAudioTimeout = 5 * time.Second
+const radioBusyRetryInterval = time.Second
+
 type controlStream struct {
 	common streamCommon
 	serial serialStream
@@ -34,8 +38,15 @@ type controlStream struct {
 	serialAndAudioStreamOpened bool
 	deinitializing             bool
 
+	requestSerialAndAudioSent    bool
 	requestSerialAndAudioTimeout *time.Timer
 	reauthTimeoutTimer           *time.Timer
+	radioBusyRetryTimer          *time.Timer
+	radioBusyReported            bool
+
+	// The computer which uses the serial and audio streams, as reported by the radio.
+	radioUserComputer string
+	radioUserIP       string
 }
 
 func (s *controlStream) sendPktLogin() error {
@@ -114,6 +125,12 @@ func (s *controlStream) sendRequestSerialAndAudio() error {
 
 	txSeqBufLengthMs := uint16(txSeqBufLength.Milliseconds())
 
+	// In listen-only mode we only request RX audio.
+	var txEnable byte = 0x01
+	if listenOnly {
+		txEnable = 0x00
+	}
+
 	usernameEncoded := passcode(username)
 	p := []byte{0x90, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
 		byte(s.common.localSID >> 24), byte(s.common.localSID >> 16), byte(s.common.localSID >> 8), byte(s.common.localSID),
@@ -132,7 +149,7 @@ func (s *controlStream) sendRequestSerialAndAudio() error {
 		usernameEncoded[4], usernameEncoded[5], usernameEncoded[6], usernameEncoded[7],
 		usernameEncoded[8], usernameEncoded[9], usernameEncoded[10], usernameEncoded[11],
 		usernameEncoded[12], usernameEncoded[13], usernameEncoded[14], usernameEncoded[15],
-		0x01, 0x01, 0x04, 0x04, 0x00, 0x00, byte(audioSampleRate >> 8), byte(audioSampleRate & 0xff),
+		0x01, txEnable, 0x04, 0x04, 0x00, 0x00, byte(audioSampleRate >> 8), byte(audioSampleRate & 0xff),
 		0x00, 0x00, byte(audioSampleRate >> 8), byte(audioSampleRate & 0xff),
 		0x00, 0x00, byte(serialStreamPort >> 8), byte(serialStreamPort & 0xff),
 		0x00, 0x00, byte(audioStreamPort >> 8), byte(audioStreamPort & 0xff), 0x00, 0x00,
@@ -142,6 +159,8 @@ func (s *controlStream) sendRequestSerialAndAudio() error {
 	}
 
 	s.authInnerSendSeq++
+	s.requestSerialAndAudioSent = true
+	s.requestSerialAndAudioTimeout.Reset(requestSerialAndAudioTimeout)
 
 	return nil
 }
@@ -217,16 +236,22 @@ func (s *controlStream) handleRead(r []byte) error {
 			//							  0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00
 
 			if bytes.Equal(r[48:51], []byte{0xff, 0xff, 0xff}) {
+				eventBus.publishEvent("authFailed", nil)
 				if !s.serialAndAudioStreamOpened {
-					return errors.New("auth failed, try rebooting the radio")
+					return newConnError(connErrorAuthFailed, "auth failed, try rebooting the radio")
 				}
-				return errors.New("auth failed")
+				return newConnError(connErrorAuthFailed, "auth failed")
 			}
 			if bytes.Equal(r[48:51], []byte{0x00, 0x00, 0x00}) && r[64] == 0x01 {
-				return errors.New("got radio disconnected")
+				return newConnError(connErrorRadioDisconnected, "got radio disconnected")
 			}
 		}
 	case 144:
+		if !s.serialAndAudioStreamOpened && bytes.Equal(r[:6], []byte{0x90, 0x00, 0x00, 0x00, 0x00, 0x00}) && r[96] == 1 &&
+			s.isRadioUserReport() {
+			s.storeRadioUser(r)
+			return nil
+		}
 		if !s.serialAndAudioStreamOpened && bytes.Equal(r[:6], []byte{0x90, 0x00, 0x00, 0x00, 0x00, 0x00}) && r[96] == 1 {
 			// Example answer:
 			// 0x90, 0x00, 0x00, 0x00, 0x00, 0x00, 0x19, 0x00,
@@ -252,6 +277,7 @@ func (s *controlStream) handleRead(r []byte) error {
 
 			devName := parseNullTerminatedString(r[64:])
 			log.Print("got serial and audio request success, device name: ", devName)
+			selectRadioModelByDeviceName(devName)
 
 			// Stuff can change in the meantime because of a previous login...
 			s.common.remoteSID = binary.BigEndian.Uint32(r[8:12])
@@ -262,14 +288,17 @@ func (s *controlStream) handleRead(r []byte) error {
 			statusLog.startPeriodicPrint()
 
 			if err := s.serial.init(devName); err != nil {
-				return errors.New("serial/" + err.Error())
+				return fmt.Errorf("serial/%w", err)
 			}
 
 			if err := s.audio.init(devName); err != nil {
-				return errors.New("audio/" + err.Error())
+				return fmt.Errorf("audio/%w", err)
 			}
 
 			s.serialAndAudioStreamOpened = true
+			eventBus.publishEvent("connected", map[string]string{"device": devName})
+			sdNotify.ready("connected to " + devName)
+			reconnect.reportConnected()
 
 			runCmdRunner.startIfNeeded(runCmd)
 			if enableSerialDevice {
@@ -278,22 +307,69 @@ func (s *controlStream) handleRead(r []byte) error {
 			if err := rigctld.initIfNeeded(); err != nil {
 				return err
 			}
+			mqttBridge.initIfNeeded(devName)
 		}
 	}
 	return nil
 }
 
+// The 0x90 packet contains the name and IP address of the computer which uses the serial and audio streams.
+// The radio sends it as the answer for our stream request, and it also reports the current user of the
+// streams with it before we request them. The IP address is the one the radio sees, so behind NAT it differs
+// from our local address even in the answer for our request, and it can't be used for detecting if it's us.
+func (s *controlStream) isRadioUserReport() bool {
+	return !s.requestSerialAndAudioSent
+}
+
+func (s *controlStream) storeRadioUser(r []byte) {
+	s.radioUserComputer = parseNullTerminatedString(r[100:116])
+	s.radioUserIP = net.IP(r[132:136]).String()
+	log.Debug("radio is used by ", s.radioUserComputer, " (", s.radioUserIP, ")")
+}
+
+func (s *controlStream) handleRequestSerialAndAudioTimeout() error {
+	// The radio does not answer our stream request while another computer uses the streams.
+	s.requestSerialAndAudioSent = false
+	if s.radioUserComputer == "" && s.radioUserIP == "" {
+		return newConnError(connErrorRequestTimeout, "login/serial/audio request timeout")
+	}
+	return s.handleRadioBusy()
+}
+
+func (s *controlStream) handleRadioBusy() error {
+	msg := "radio is in use by " + s.radioUserComputer + " (" + s.radioUserIP + ")"
+	busyEventData := map[string]string{"computer": s.radioUserComputer, "ip": s.radioUserIP}
+
+	if !waitIfBusy {
+		eventBus.publishEvent("radioBusy", busyEventData)
+		return newConnError(connErrorRadioBusy, msg)
+	}
+
+	if !s.radioBusyReported {
+		log.Print(msg, ", retrying the stream request until the radio hands it over")
+		eventBus.publishEvent("radioBusy", busyEventData)
+		s.radioBusyReported = true
+	} else {
+		log.Debug(msg)
+	}
+	s.radioBusyRetryTimer.Reset(radioBusyRetryInterval)
+	return nil
+}
+
 func (s *controlStream) loop() {
 	netstat.reset()
 
 	s.reauthTimeoutTimer = time.NewTimer(0)
 	<-s.reauthTimeoutTimer.C
+	s.radioBusyRetryTimer = time.NewTimer(0)
+	<-s.radioBusyRetryTimer.C
 
 	reauthTicker := time.NewTicker(reauthInterval)
 
 	for {
 		select {
 		case r := <-s.common.readChan:
+			sdNotify.heartbeat()
 			if !s.deinitializing {
 				if err := s.handleRead(r); err != nil {
 					reportError(err)
@@ -305,8 +381,16 @@ func (s *controlStream) loop() {
 			if err := s.sendPktAuth(0x05); err != nil {
 				reportError(err)
 			}
+		case <-s.requestSerialAndAudioTimeout.C:
+			if err := s.handleRequestSerialAndAudioTimeout(); err != nil {
+				reportError(err)
+			}
+		case <-s.radioBusyRetryTimer.C:
+			s.sendRequestSerialAndAudioIfPossible()
 		case <-s.reauthTimeoutTimer.C:
 			log.Error("auth timeout, audio/serial stream may stop")
+			metrics.reportAuthTimeout()
+			eventBus.publishEvent("authTimeout", nil)
 		case <-s.deinitNeededChan:
 			s.deinitFinishedChan <- true
 			return
@@ -348,7 +432,7 @@ func (s *controlStream) init() error {
 		return err
 	}
 	if bytes.Equal(r[48:52], []byte{0xff, 0xff, 0xff, 0xfe}) {
-		return errors.New("invalid username/password")
+		return newConnError(connErrorInvalidCredentials, "invalid username/password")
 	}
 
 	s.common.pkt7.startPeriodicSend(&s.common, 2, false)
@@ -367,9 +451,7 @@ func (s *controlStream) init() error {
 	}
 	log.Debug("second auth sent...")
 
-	s.requestSerialAndAudioTimeout = time.AfterFunc(5*time.Second, func() {
-		reportError(errors.New("login/serial/audio request timeout"))
-	})
+	s.requestSerialAndAudioTimeout = time.NewTimer(requestSerialAndAudioTimeout)
 
 	s.deinitNeededChan = make(chan bool)
 	s.deinitFinishedChan = make(chan bool)
@@ -379,6 +461,10 @@ func (s *controlStream) init() error {
 
 func (s *controlStream) deinit() {
 	s.deinitializing = true
+	if s.serialAndAudioStreamOpened {
+		eventBus.publishEvent("disconnected", nil)
+		sdNotify.status("disconnected")
+	}
 	s.serialAndAudioStreamOpened = false
 	statusLog.stopPeriodicPrint()
 
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func testControlStreamRadioUserPacket(computer string, ip [4]byte) []byte {
+	r := make([]byte, 144)
+	r[0] = 0x90
+	r[96] = 1
+	copy(r[64:], "IC-705")
+	copy(r[100:116], computer)
+	copy(r[132:136], ip[:])
+	return r
+}
+
+func TestControlStreamRadioBusy(t *testing.T) {
+	log.Init()
+	prevWaitIfBusy := waitIfBusy
+	waitIfBusy = false
+	t.Cleanup(func() { waitIfBusy = prevWaitIfBusy })
+
+	s := controlStream{requestSerialAndAudioTimeout: time.NewTimer(time.Hour)}
+	defer s.requestSerialAndAudioTimeout.Stop()
+
+	if err := s.handleRead(testControlStreamRadioUserPacket("icom-pc", [4]byte{192, 168, 3, 3})); err != nil {
+		t.Fatal(err)
+	}
+	if s.serialAndAudioStreamOpened {
+		t.Fatal("streams are opened on the radio's user report")
+	}
+	err := s.handleRequestSerialAndAudioTimeout()
+	if kind := getConnErrorKind(err); kind != connErrorRadioBusy {
+		t.Fatalf("error kind = %v, want %v", kind, connErrorRadioBusy)
+	}
+	if want := "radio is in use by icom-pc (192.168.3.3)"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
+
+func TestControlStreamRequestAnswerWithOtherAddress(t *testing.T) {
+	s := controlStream{requestSerialAndAudioSent: true}
+
+	// Behind NAT the radio answers our request with an address which differs from our local address.
+	r := testControlStreamRadioUserPacket("icom-pc", [4]byte{10, 0, 0, 2})
+	if s.isRadioUserReport() {
+		t.Fatalf("answer %v for our request is handled as the radio's user report", r[132:136])
+	}
+	if kind := getConnErrorKind(s.handleRequestSerialAndAudioTimeout()); kind != connErrorRequestTimeout {
+		t.Errorf("error kind = %v, want %v", kind, connErrorRequestTimeout)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Events are dropped if a subscriber falls behind this many events. State changes are never dropped.
+const eventBusSubscriberQueueLength = 64
+
+// State deltas use the same keys as the /state HTTP API response.
+type eventBusState map[string]interface{}
+
+type eventBusMsg struct {
+	Type  string        `json:"type"`
+	Time  time.Time     `json:"time"`
+	State eventBusState `json:"state,omitempty"`
+	Event string        `json:"event,omitempty"`
+	Data  interface{}   `json:"data,omitempty"`
+}
+
+type eventBusSubscriber struct {
+	out    chan []byte
+	notify chan bool
+	quit   chan bool
+
+	// Keys of the changed state values which haven't been sent yet. Only the latest values are sent, so
+	// slow subscribers (like the MQTT bridge if the broker is slow) miss only the intermediate values.
+	pendingState  map[string]bool
+	pendingEvents []eventBusMsg
+}
+
+type eventBusStruct struct {
+	mutex       sync.Mutex
+	state       eventBusState
+	subscribers map[chan []byte]*eventBusSubscriber
+}
+
+var eventBus eventBusStruct
+
+// Should be called with the mutex locked.
+func (s *eventBusStruct) wakeSubscriber(sub *eventBusSubscriber) {
+	select {
+	case sub.notify <- true:
+	default:
+	}
+}
+
+// Only the changed values are sent to the subscribers.
+func (s *eventBusStruct) publishState(d eventBusState) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.state == nil {
+		s.state = make(eventBusState)
+	}
+
+	changed := false
+	for k, v := range d {
+		if prev, ok := s.state[k]; ok && prev == v {
+			continue
+		}
+		s.state[k] = v
+		changed = true
+		for _, sub := range s.subscribers {
+			sub.pendingState[k] = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	for _, sub := range s.subscribers {
+		s.wakeSubscriber(sub)
+	}
+}
+
+func (s *eventBusStruct) publishEvent(event string, data interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	m := eventBusMsg{Type: "event", Time: time.Now(), Event: event, Data: data}
+	for _, sub := range s.subscribers {
+		if len(sub.pendingEvents) >= eventBusSubscriberQueueLength {
+			log.Debug("dropping ", sub.pendingEvents[0].Event, " event for a slow subscriber")
+			sub.pendingEvents = sub.pendingEvents[1:]
+		}
+		sub.pendingEvents = append(sub.pendingEvents, m)
+		s.wakeSubscriber(sub)
+	}
+}
+
+// Returns the messages to send to the subscriber, the pending state changes are sent first
+// in one message with their latest values.
+func (s *eventBusStruct) takePending(sub *eventBusSubscriber) (res []eventBusMsg) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(sub.pendingState) > 0 {
+		state := make(eventBusState)
+		for k := range sub.pendingState {
+			state[k] = s.state[k]
+		}
+		sub.pendingState = make(map[string]bool)
+		res = append(res, eventBusMsg{Type: "state", Time: time.Now(), State: state})
+	}
+	res = append(res, sub.pendingEvents...)
+	sub.pendingEvents = nil
+	return
+}
+
+func (s *eventBusStruct) subscriberLoop(sub *eventBusSubscriber) {
+	defer close(sub.out)
+
+	for {
+		select {
+		case <-sub.notify:
+		case <-sub.quit:
+			return
+		}
+
+		for _, m := range s.takePending(sub) {
+			b, err := json.Marshal(m)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			select {
+			case sub.out <- b:
+			case <-sub.quit:
+				return
+			}
+		}
+	}
+}
+
+// The returned channel receives JSON encoded messages, starting with the whole known state.
+func (s *eventBusStruct) subscribe() chan []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.subscribers == nil {
+		s.subscribers = make(map[chan []byte]*eventBusSubscriber)
+	}
+
+	sub := &eventBusSubscriber{
+		out:          make(chan []byte),
+		notify:       make(chan bool, 1),
+		quit:         make(chan bool),
+		pendingState: make(map[string]bool),
+	}
+	for k := range s.state {
+		sub.pendingState[k] = true
+	}
+	if len(sub.pendingState) > 0 {
+		s.wakeSubscriber(sub)
+	}
+	s.subscribers[sub.out] = sub
+	go s.subscriberLoop(sub)
+	return sub.out
+}
+
+// The channel gets closed. Reading from it should continue until then.
+func (s *eventBusStruct) unsubscribe(c chan []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sub, ok := s.subscribers[c]
+	if !ok {
+		return
+	}
+	delete(s.subscribers, c)
+	close(sub.quit)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func testEventBusRead(t *testing.T, c chan []byte) eventBusMsg {
+	select {
+	case b := <-c:
+		var m eventBusMsg
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	return eventBusMsg{}
+}
+
+func TestEventBusSlowSubscriberGetsLatestState(t *testing.T) {
+	log.Init()
+	var b eventBusStruct
+	b.publishState(eventBusState{"ptt": false})
+
+	c := b.subscribe()
+	defer b.unsubscribe(c)
+
+	// The subscriber does not read while the state changes many times.
+	for i := 1; i <= 10*eventBusSubscriberQueueLength; i++ {
+		b.publishState(eventBusState{"freq": i, "ptt": i%2 == 1})
+	}
+	b.publishState(eventBusState{"ptt": false})
+
+	state := make(eventBusState)
+	for len(state) < 2 || state["ptt"] != false || state["freq"] != float64(10*eventBusSubscriberQueueLength) {
+		m := testEventBusRead(t, c)
+		if m.Type != "state" {
+			t.Fatalf("got %s message, want state", m.Type)
+		}
+		for k, v := range m.State {
+			state[k] = v
+		}
+	}
+}
+
+func TestEventBusDropsOldEvents(t *testing.T) {
+	log.Init()
+	var b eventBusStruct
+	c := b.subscribe()
+	defer b.unsubscribe(c)
+
+	// Waiting for the subscriber's loop to take the first event, so it blocks on sending it.
+	b.publishEvent("first", nil)
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < eventBusSubscriberQueueLength+1; i++ {
+		b.publishEvent("test", i)
+	}
+
+	if m := testEventBusRead(t, c); m.Event != "first" {
+		t.Fatalf("got %s event, want first", m.Event)
+	}
+	// The oldest queued event is dropped.
+	if m := testEventBusRead(t, c); m.Data != float64(1) {
+		t.Errorf("got event data %v, want 1", m.Data)
+	}
+}
+
+func TestEventBusUnsubscribeClosesChannel(t *testing.T) {
+	var b eventBusStruct
+	b.publishState(eventBusState{"freq": 14074000})
+	c := b.subscribe()
+	b.unsubscribe(c)
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-c:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("channel is not closed")
+		}
+	}
+}
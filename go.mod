@@ -4,11 +4,14 @@ go 1.14
 
 require (
 	github.com/akosmarton/papipes v0.0.0-20201027113853-3c63b4919c76
+	github.com/eclipse/paho.mqtt.golang v1.3.5
 	github.com/fatih/color v1.9.0
 	github.com/google/goterm v0.0.0-20200907032337-555d40f16ae2
+	github.com/gorilla/websocket v1.4.2
 	github.com/mattn/go-isatty v0.0.11
 	github.com/mesilliac/pulse-simple v0.0.0-20170506101341-75ac54e19fdf
 	github.com/pborman/getopt v1.1.0
 	go.uber.org/multierr v1.6.0 // indirect
 	go.uber.org/zap v1.16.0
+	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 )
@@ -2,192 +2,302 @@ package main
 
 import "fmt"
 
-func handleHotkey(k byte) {
+func handleHotkey(src civSource, k byte) {
 	switch k {
 	case 'l':
 		audio.togglePlaybackToDefaultSoundcard()
 	case ' ':
 		audio.toggleRecFromDefaultSoundcard()
 	case 't':
-		if err := civControl.toggleTune(); err != nil {
+		if err := civControl.toggleTune(src); err != nil {
 			log.Error("can't toggle tune: ", err)
 		}
 	case '+':
-		if err := civControl.incPwr(); err != nil {
+		if err := civControl.incPwr(src); err != nil {
 			log.Error("can't increase power: ", err)
 		}
 	case '-':
-		if err := civControl.decPwr(); err != nil {
+		if err := civControl.decPwr(src); err != nil {
 			log.Error("can't decrease power: ", err)
 		}
 	case '0':
-		if err := civControl.setPwr(0); err != nil {
+		if err := civControl.setPwr(src, 0); err != nil {
 			log.Error("can't set power: ", err)
 		}
 	case '1':
-		if err := civControl.setPwr(10); err != nil {
+		if err := civControl.setPwr(src, 10); err != nil {
 			log.Error("can't set power: ", err)
 		}
 	case '2':
-		if err := civControl.setPwr(20); err != nil {
+		if err := civControl.setPwr(src, 20); err != nil {
 			log.Error("can't set power: ", err)
 		}
 	case '3':
-		if err := civControl.setPwr(30); err != nil {
+		if err := civControl.setPwr(src, 30); err != nil {
 			log.Error("can't set power: ", err)
 		}
 	case '4':
-		if err := civControl.setPwr(40); err != nil {
+		if err := civControl.setPwr(src, 40); err != nil {
 			log.Error("can't set power: ", err)
 		}
 	case '5':
-		if err := civControl.setPwr(50); err != nil {
+		if err := civControl.setPwr(src, 50); err != nil {
 			log.Error("can't set power: ", err)
 		}
 	case '6':
-		if err := civControl.setPwr(60); err != nil {
+		if err := civControl.setPwr(src, 60); err != nil {
 			log.Error("can't set power: ", err)
 		}
 	case '7':
-		if err := civControl.setPwr(70); err != nil {
+		if err := civControl.setPwr(src, 70); err != nil {
 			log.Error("can't set power: ", err)
 		}
 	case '8':
-		if err := civControl.setPwr(80); err != nil {
+		if err := civControl.setPwr(src, 80); err != nil {
 			log.Error("can't set power: ", err)
 		}
 	case '9':
-		if err := civControl.setPwr(90); err != nil {
+		if err := civControl.setPwr(src, 90); err != nil {
 			log.Error("can't set power: ", err)
 		}
 	case ')':
-		if err := civControl.setPwr(100); err != nil {
+		if err := civControl.setPwr(src, 100); err != nil {
 			log.Error("can't set power: ", err)
 		}
 	case '!':
-		if err := civControl.setRFGain(10); err != nil {
+		if err := civControl.setRFGain(src, 10); err != nil {
 			log.Error("can't set rfgain: ", err)
 		}
 	case '@':
-		if err := civControl.setRFGain(20); err != nil {
+		if err := civControl.setRFGain(src, 20); err != nil {
 			log.Error("can't set rfgain: ", err)
 		}
 	case '#':
-		if err := civControl.setRFGain(30); err != nil {
+		if err := civControl.setRFGain(src, 30); err != nil {
 			log.Error("can't set rfgain: ", err)
 		}
 	case '$':
-		if err := civControl.setRFGain(40); err != nil {
+		if err := civControl.setRFGain(src, 40); err != nil {
 			log.Error("can't set rfgain: ", err)
 		}
 	case '%':
-		if err := civControl.setRFGain(50); err != nil {
+		if err := civControl.setRFGain(src, 50); err != nil {
 			log.Error("can't set rfgain: ", err)
 		}
 	case '^':
-		if err := civControl.setRFGain(60); err != nil {
+		if err := civControl.setRFGain(src, 60); err != nil {
 			log.Error("can't set rfgain: ", err)
 		}
 	case '&':
-		if err := civControl.setRFGain(70); err != nil {
+		if err := civControl.setRFGain(src, 70); err != nil {
 			log.Error("can't set rfgain: ", err)
 		}
 	case '*':
-		if err := civControl.setRFGain(80); err != nil {
+		if err := civControl.setRFGain(src, 80); err != nil {
 			log.Error("can't set rfgain: ", err)
 		}
 	case '(':
-		if err := civControl.setRFGain(90); err != nil {
+		if err := civControl.setRFGain(src, 90); err != nil {
 			log.Error("can't set rfgain: ", err)
 		}
 	case '\'':
-		if err := civControl.incRFGain(); err != nil {
+		if err := civControl.incRFGain(src); err != nil {
 			log.Error("can't increase rf gain: ", err)
 		}
 	case ';':
-		if err := civControl.decRFGain(); err != nil {
+		if err := civControl.decRFGain(src); err != nil {
 			log.Error("can't decrease rf gain: ", err)
 		}
 	case '"':
-		if err := civControl.incSQL(); err != nil {
+		if err := civControl.incSQL(src); err != nil {
 			log.Error("can't increase sql: ", err)
 		}
 	case ':':
-		if err := civControl.decSQL(); err != nil {
+		if err := civControl.decSQL(src); err != nil {
 			log.Error("can't decrease sql: ", err)
 		}
 	case '.':
-		if err := civControl.incNR(); err != nil {
+		if err := civControl.incNR(src); err != nil {
 			log.Error("can't increase nr: ", err)
 		}
 	case ',':
-		if err := civControl.decNR(); err != nil {
+		if err := civControl.decNR(src); err != nil {
 			log.Error("can't decrease nr: ", err)
 		}
 	case '/':
-		if err := civControl.toggleNR(); err != nil {
+		if err := civControl.toggleNR(src); err != nil {
 			log.Error("can't toggle nr: ", err)
 		}
 	case ']':
-		if err := civControl.incFreq(); err != nil {
+		if err := civControl.incFreq(src); err != nil {
 			log.Error("can't increase freq: ", err)
 		}
 	case '[':
-		if err := civControl.decFreq(); err != nil {
+		if err := civControl.decFreq(src); err != nil {
 			log.Error("can't decrease freq: ", err)
 		}
 	case '}':
-		if err := civControl.incTS(); err != nil {
+		if err := civControl.incTS(src); err != nil {
 			log.Error("can't increase ts: ", err)
 		}
 	case '{':
-		if err := civControl.decTS(); err != nil {
+		if err := civControl.decTS(src); err != nil {
 			log.Error("can't decrease ts: ", err)
 		}
 	case 'm':
-		if err := civControl.incOperatingMode(); err != nil {
+		if err := civControl.incOperatingMode(src); err != nil {
 			log.Error("can't change mode: ", err)
 		}
 	case 'n':
-		if err := civControl.decOperatingMode(); err != nil {
+		if err := civControl.decOperatingMode(src); err != nil {
 			log.Error("can't change mode: ", err)
 		}
 	case 'f':
-		if err := civControl.incFilter(); err != nil {
+		if err := civControl.incFilter(src); err != nil {
 			log.Error("can't change filter: ", err)
 		}
 	case 'd':
-		if err := civControl.decFilter(); err != nil {
+		if err := civControl.decFilter(src); err != nil {
 			log.Error("can't change filter: ", err)
 		}
 	case 'D':
-		if err := civControl.toggleDataMode(); err != nil {
+		if err := civControl.toggleDataMode(src); err != nil {
 			log.Error("can't change datamode: ", err)
 		}
 	case 'b':
-		if err := civControl.incBand(); err != nil {
+		if err := civControl.incBand(src); err != nil {
 			log.Error("can't change band: ", err)
 		}
 	case 'v':
-		if err := civControl.decBand(); err != nil {
+		if err := civControl.decBand(src); err != nil {
 			log.Error("can't change band: ", err)
 		}
 	case 'p':
-		if err := civControl.togglePreamp(); err != nil {
+		if err := civControl.togglePreamp(src); err != nil {
 			log.Error("can't change preamp: ", err)
 		}
 	case 'a':
-		if err := civControl.toggleAGC(); err != nil {
+		if err := civControl.toggleAGC(src); err != nil {
 			log.Error("can't change agc: ", err)
 		}
 	case 'o':
-		if err := civControl.toggleVFO(); err != nil {
+		if err := civControl.toggleVFO(src); err != nil {
 			log.Error("can't change vfo: ", err)
 		}
 	case 's':
-		if err := civControl.toggleSplit(); err != nil {
+		if err := civControl.toggleSplit(src); err != nil {
 			log.Error("can't change split: ", err)
 		}
+	case 'w':
+		if err := civControl.toggleScope(src); err != nil {
+			log.Error("can't toggle scope: ", err)
+		}
+	case '<':
+		if err := civControl.decScopeSpan(src); err != nil {
+			log.Error("can't decrease scope span: ", err)
+		}
+	case '>':
+		if err := civControl.incScopeSpan(src); err != nil {
+			log.Error("can't increase scope span: ", err)
+		}
+	case 'T':
+		if err := civControl.toggleATU(src); err != nil {
+			log.Error("can't toggle atu: ", err)
+		}
+	case 'A':
+		if err := civControl.cycleAntenna(src); err != nil {
+			log.Error("can't change antenna: ", err)
+		}
+	case 'r':
+		if err := civControl.cycleDuplex(src); err != nil {
+			log.Error("can't change duplex: ", err)
+		}
+	case 'R':
+		if err := civControl.cycleARP(src); err != nil {
+			log.Error("can't change auto repeater: ", err)
+		}
+	case 'e':
+		if err := civControl.cycleToneMode(src); err != nil {
+			log.Error("can't change tone mode: ", err)
+		}
+	case 'E':
+		if err := civControl.cycleOffset(src); err != nil {
+			log.Error("can't change offset: ", err)
+		}
+	case 'c':
+		if err := civControl.decTone(src); err != nil {
+			log.Error("can't decrease tone: ", err)
+		}
+	case 'C':
+		if err := civControl.incTone(src); err != nil {
+			log.Error("can't increase tone: ", err)
+		}
+	case 'i':
+		if err := civControl.toggleRIT(src); err != nil {
+			log.Error("can't toggle rit: ", err)
+		}
+	case 'x':
+		if err := civControl.toggleXIT(src); err != nil {
+			log.Error("can't toggle xit: ", err)
+		}
+	case 'k':
+		if err := civControl.incRITOffset(src); err != nil {
+			log.Error("can't increase rit offset: ", err)
+		}
+	case 'j':
+		if err := civControl.decRITOffset(src); err != nil {
+			log.Error("can't decrease rit offset: ", err)
+		}
+	case 'Z':
+		if err := civControl.setRITOffset(src, 0); err != nil {
+			log.Error("can't clear rit offset: ", err)
+		}
+	case 'K':
+		if err := civControl.incPBTIn(src); err != nil {
+			log.Error("can't increase pbt inner: ", err)
+		}
+	case 'J':
+		if err := civControl.decPBTIn(src); err != nil {
+			log.Error("can't decrease pbt inner: ", err)
+		}
+	case 'M':
+		if err := civControl.incPBTOut(src); err != nil {
+			log.Error("can't increase pbt outer: ", err)
+		}
+	case 'N':
+		if err := civControl.decPBTOut(src); err != nil {
+			log.Error("can't decrease pbt outer: ", err)
+		}
+	case 'z':
+		if err := civControl.cycleATT(src); err != nil {
+			log.Error("can't change attenuator: ", err)
+		}
+	case 'B':
+		if err := civControl.toggleNB(src); err != nil {
+			log.Error("can't toggle nb: ", err)
+		}
+	case 'h':
+		if err := civControl.toggleANF(src); err != nil {
+			log.Error("can't toggle auto notch: ", err)
+		}
+	case 'H':
+		if err := civControl.toggleMN(src); err != nil {
+			log.Error("can't toggle manual notch: ", err)
+		}
+	case 'g':
+		if err := civControl.toggleComp(src); err != nil {
+			log.Error("can't toggle compressor: ", err)
+		}
+	case 'G':
+		if err := civControl.toggleMon(src); err != nil {
+			log.Error("can't toggle monitor: ", err)
+		}
+	case 'V':
+		if err := civControl.toggleVOX(src); err != nil {
+			log.Error("can't toggle vox: ", err)
+		}
+	case 'S':
+		statusLog.toggleNetstatDetails()
 	case '\n':
 		if statusLog.isRealtime() {
 			statusLog.mutex.Lock()
@@ -18,7 +18,7 @@ func (s *keyboardStruct) loop() {
 	for {
 		n, err := os.Stdin.Read(b)
 		if n > 0 && err == nil {
-			handleHotkey(b[0])
+			handleHotkey(civSourceHotkey, b[0])
 		}
 	}
 }
@@ -0,0 +1,28 @@
+package main
+
+import "errors"
+
+// In listen-only mode we only request RX audio from the radio, and refuse everything which could key
+// the transmitter or retune the radio, so guests can monitor a shared radio without any risk.
+var errListenOnly = errors.New("not allowed in listen-only mode")
+
+// These commands change the radio's state even without data bytes.
+var civCmdsRefusedInListenOnlyMode = map[byte]bool{
+	0x07: true, // VFO mode.
+	0x08: true, // Memory mode.
+	0x09: true, // Memory write.
+	0x0a: true, // Memory to VFO.
+	0x0b: true, // Memory clear.
+	0x18: true, // Power on/off.
+}
+
+// Returns true if the frame sent by a raw client is not allowed in listen-only mode. Only reads are
+// allowed. The from address is set by the client, so it is not used for letting frames through.
+func isCIVFrameRefusedInListenOnlyMode(f *civFrame) bool {
+	return !f.isRead() || civCmdsRefusedInListenOnlyMode[f.cmd]
+}
+
+// Returns the NG reply for the given frame which we send back to the controller.
+func getCIVNGReply(f *civFrame) []byte {
+	return []byte{254, 254, f.from, civAddress, 0xfa, 253}
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestIsCIVFrameRefusedInListenOnlyMode(t *testing.T) {
+	prevCIVAddress := civAddress
+	civAddress = testCIVAddress
+	t.Cleanup(func() { civAddress = prevCIVAddress })
+
+	tests := []struct {
+		name string
+		d    []byte
+		want bool
+	}{
+		{"frequency read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x03, 0xfd}, false},
+		{"frequency set", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x05, 0x00, 0x00, 0x07, 0x14, 0x00, 0xfd}, true},
+		{"vfo frequency set", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x25, 0x00, 0x00, 0x00, 0x07, 0x14, 0x00, 0xfd}, true},
+		{"power set", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x14, 0x0a, 0x01, 0x28, 0xfd}, true},
+		{"rf gain set", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x14, 0x02, 0x01, 0x28, 0xfd}, true},
+		{"ptt on", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1c, 0x00, 0x01, 0xfd}, true},
+		{"tune", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1c, 0x01, 0x02, 0xfd}, true},
+		{"vox read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x16, 0x46, 0xfd}, false},
+		{"vox on", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x16, 0x46, 0x01, 0xfd}, true},
+		{"vox off", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x16, 0x46, 0x00, 0xfd}, true},
+		{"noise blanker on", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x16, 0x22, 0x01, 0xfd}, true},
+		{"memory mode", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x08, 0xfd}, true},
+		{"memory recall", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x08, 0x00, 0x01, 0xfd}, true},
+		{"band stacking register read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1a, 0x01, 0x05, 0x01, 0xfd}, false},
+		{"band stacking register set", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1a, 0x01, 0x05, 0x01,
+			0x00, 0x00, 0x07, 0x14, 0x00, 0x03, 0x01, 0x00, 0xfd}, true},
+		{"menu read", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x1a, 0x05, 0x01, 0x31, 0xfd}, false},
+		{"sub receiver frequency set", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x29, 0x01, 0x05,
+			0x00, 0x00, 0x07, 0x14, 0x00, 0xfd}, true},
+		{"power off", []byte{0xfe, 0xfe, 0x98, 0xe0, 0x18, 0x00, 0xfd}, true},
+		{"spoofed from address", []byte{0xfe, 0xfe, testCIVAddress, testCIVAddress, 0x05, 0x00, 0x00, 0x07, 0x14, 0x00, 0xfd}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ok := parseCIVFrame(tt.d)
+			if !ok {
+				t.Fatal("can't parse frame")
+			}
+			if got := isCIVFrameRefusedInListenOnlyMode(&f); got != tt.want {
+				t.Errorf("isCIVFrameRefusedInListenOnlyMode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
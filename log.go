@@ -1,32 +1,62 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 type logger struct {
 	logger            *zap.SugaredLogger
 	filenameTrimChars int
+	jsonFormat        bool
+
+	// Subsystems are named after the source files, for example seqbuf or rigctld.
+	defaultLevel    zapcore.Level
+	subsystemLevels map[string]zapcore.Level
+
+	file               *lumberjack.Logger
+	fileRotateStopChan chan bool
 }
 
 var log logger
 
-func (l *logger) GetCallerFileName(withLine bool) string {
-	_, filename, line, _ := runtime.Caller(2)
+func (l *logger) getCaller(skip int, withLine bool) (subsystem, caller string) {
+	_, filename, line, _ := runtime.Caller(skip + 1)
 	extension := filepath.Ext(filename)
+	subsystem = filename[l.filenameTrimChars : len(filename)-len(extension)]
 	if withLine {
-		return fmt.Sprint(filename[l.filenameTrimChars:len(filename)-len(extension)], "@", line)
+		return subsystem, fmt.Sprint(subsystem, "@", line)
 	}
-	return filename[l.filenameTrimChars : len(filename)-len(extension)]
+	return subsystem, subsystem
 }
 
-func (l *logger) Print(a ...interface{}) {
+func (l *logger) GetCallerFileName(withLine bool) string {
+	_, caller := l.getCaller(2, withLine)
+	return caller
+}
+
+func (l *logger) isEnabled(subsystem string, level zapcore.Level) bool {
+	if subsystemLevel, ok := l.subsystemLevels[subsystem]; ok {
+		return level >= subsystemLevel
+	}
+	return level >= l.defaultLevel
+}
+
+// The status bar is cleared before printing the log line, and it's redrawn after it.
+func (l *logger) write(level zapcore.Level, subsystem, caller, msg string, keysAndValues []interface{}) {
+	if !l.isEnabled(subsystem, level) {
+		return
+	}
+
 	if statusLog.isRealtime() {
 		statusLog.mutex.Lock()
 		statusLog.clearInternal()
@@ -35,47 +65,110 @@ func (l *logger) Print(a ...interface{}) {
 			statusLog.print()
 		}()
 	}
-	l.logger.Info(append([]interface{}{l.GetCallerFileName(false) + ": "}, a...)...)
+
+	if caller != "" {
+		if l.jsonFormat {
+			keysAndValues = append([]interface{}{"caller", caller}, keysAndValues...)
+		} else {
+			msg = caller + ": " + msg
+		}
+	}
+
+	switch level {
+	case zapcore.DebugLevel:
+		l.logger.Debugw(msg, keysAndValues...)
+	case zapcore.ErrorLevel:
+		l.logger.Errorw(msg, keysAndValues...)
+	default:
+		l.logger.Infow(msg, keysAndValues...)
+	}
+}
+
+func (l *logger) Print(a ...interface{}) {
+	subsystem, caller := l.getCaller(1, false)
+	l.write(zapcore.InfoLevel, subsystem, caller, fmt.Sprint(a...), nil)
+}
+
+// Logs msg with structured fields given as key/value pairs.
+func (l *logger) Printw(msg string, keysAndValues ...interface{}) {
+	subsystem, caller := l.getCaller(1, false)
+	l.write(zapcore.InfoLevel, subsystem, caller, msg, keysAndValues)
 }
 
 func (l *logger) PrintStatusLog(a ...interface{}) {
-	l.logger.Info(append([]interface{}{l.GetCallerFileName(false) + ": "}, a...)...)
+	subsystem, caller := l.getCaller(1, false)
+	if !l.isEnabled(subsystem, zapcore.InfoLevel) {
+		return
+	}
+	if l.jsonFormat {
+		l.logger.Infow(fmt.Sprint(a...), "caller", caller)
+	} else {
+		l.logger.Info(append([]interface{}{caller + ": "}, a...)...)
+	}
 }
 
 func (l *logger) Debug(a ...interface{}) {
-	if statusLog.isRealtime() {
-		statusLog.mutex.Lock()
-		statusLog.clearInternal()
-		defer func() {
-			statusLog.mutex.Unlock()
-			statusLog.print()
-		}()
-	}
-	l.logger.Debug(append([]interface{}{l.GetCallerFileName(true) + ": "}, a...)...)
+	subsystem, caller := l.getCaller(1, true)
+	l.write(zapcore.DebugLevel, subsystem, caller, fmt.Sprint(a...), nil)
+}
+
+// Logs msg with structured fields given as key/value pairs.
+func (l *logger) Debugw(msg string, keysAndValues ...interface{}) {
+	subsystem, caller := l.getCaller(1, true)
+	l.write(zapcore.DebugLevel, subsystem, caller, msg, keysAndValues)
 }
 
 func (l *logger) Error(a ...interface{}) {
-	if statusLog.isRealtime() {
-		statusLog.mutex.Lock()
-		statusLog.clearInternal()
-		defer func() {
-			statusLog.mutex.Unlock()
-			statusLog.print()
-		}()
-	}
-	l.logger.Error(append([]interface{}{l.GetCallerFileName(true) + ": "}, a...)...)
+	subsystem, caller := l.getCaller(1, true)
+	l.write(zapcore.ErrorLevel, subsystem, caller, fmt.Sprint(a...), nil)
+}
+
+// Logs msg with structured fields given as key/value pairs.
+func (l *logger) Errorw(msg string, keysAndValues ...interface{}) {
+	subsystem, caller := l.getCaller(1, true)
+	l.write(zapcore.ErrorLevel, subsystem, caller, msg, keysAndValues)
 }
 
+// Logs an error without adding the caller, the caller should be included in the args.
 func (l *logger) ErrorC(a ...interface{}) {
-	if statusLog.isRealtime() {
-		statusLog.mutex.Lock()
-		statusLog.clearInternal()
-		defer func() {
-			statusLog.mutex.Unlock()
-			statusLog.print()
-		}()
+	l.write(zapcore.ErrorLevel, "", "", fmt.Sprint(a...), nil)
+}
+
+// Parses levels in the format subsystem=level,subsystem=level, for example seqbuf=debug,rigctld=error
+func (l *logger) parseSubsystemLevels(s string) (map[string]zapcore.Level, error) {
+	levels := make(map[string]zapcore.Level)
+	if s == "" {
+		return levels, nil
+	}
+	for _, e := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(e), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.New("invalid subsystem log level: " + e)
+		}
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(parts[1])); err != nil {
+			return nil, errors.New("invalid subsystem log level: " + e)
+		}
+		levels[parts[0]] = level
+	}
+	return levels, nil
+}
+
+func (l *logger) fileRotateLoop() {
+	ticker := time.NewTicker(logFileRotateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.file.Rotate(); err != nil {
+				l.Error("can't rotate log file: ", err)
+			}
+		case <-l.fileRotateStopChan:
+			l.fileRotateStopChan <- true
+			return
+		}
 	}
-	l.logger.Error(a...)
 }
 
 func (l *logger) Init() {
@@ -83,21 +176,60 @@ func (l *logger) Init() {
 	pe := zap.NewProductionEncoderConfig()
 	pe.EncodeTime = zapcore.ISO8601TimeEncoder
 	// pe.LevelKey = ""
-	consoleEncoder := zapcore.NewConsoleEncoder(pe)
 
-	var level zapcore.Level
+	l.jsonFormat = logFormat == "json"
+	var encoder zapcore.Encoder
+	if l.jsonFormat {
+		encoder = zapcore.NewJSONEncoder(pe)
+	} else {
+		encoder = zapcore.NewConsoleEncoder(pe)
+	}
+
 	if verboseLog {
-		level = zap.DebugLevel
-	} else if quietLog {
-		level = zap.FatalLevel
+		l.defaultLevel = zap.DebugLevel
 	} else {
-		level = zap.InfoLevel
+		l.defaultLevel = zap.InfoLevel
 	}
 
-	core := zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), level)
-	l.logger = zap.New(core).Sugar()
+	var err error
+	l.subsystemLevels, err = l.parseSubsystemLevels(logLevels)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// Levels are filtered by us per subsystem, so the cores let everything through.
+	var cores []zapcore.Core
+	if !quietLog {
+		cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(os.Stdout), zap.DebugLevel))
+	}
+	if logFile != "" {
+		l.file = &lumberjack.Logger{
+			Filename:   logFile,
+			MaxSize:    int(logFileMaxSizeMB),
+			MaxBackups: int(logFileMaxBackups),
+		}
+		cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(l.file), zap.DebugLevel))
+
+		if logFileRotateInterval > 0 {
+			l.fileRotateStopChan = make(chan bool)
+			go l.fileRotateLoop()
+		}
+	}
+	l.logger = zap.New(zapcore.NewTee(cores...)).Sugar()
 
 	var callerFilename string
 	_, callerFilename, _, _ = runtime.Caller(1)
 	l.filenameTrimChars = len(filepath.Dir(callerFilename)) + 1
 }
+
+func (l *logger) Deinit() {
+	if l.fileRotateStopChan != nil {
+		l.fileRotateStopChan <- true
+		<-l.fileRotateStopChan
+	}
+	_ = l.logger.Sync()
+	if l.file != nil {
+		_ = l.file.Close()
+	}
+}
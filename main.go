@@ -4,16 +4,13 @@ import (
 	"os"
 	"os/signal"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 )
 
-const waitBetweenRetries = time.Second
-const retryCount = 5
-const waitOnRetryFailure = 65 * time.Second
-
-var gotErrChan = make(chan bool)
+var gotErrChan = make(chan error)
 var quitChan = make(chan bool)
 
 func getAboutStr() string {
@@ -27,22 +24,55 @@ func getAboutStr() string {
 	return "kappanhang " + v + " by Norbert Varga HA2NON and Akos Marton ES1AKOS https://github.com/nonoo/kappanhang"
 }
 
-func wait(d time.Duration, osSignal chan os.Signal) (shouldExit bool) {
-	for sec := d.Seconds(); sec > 0; sec-- {
-		log.Print("waiting ", sec, " seconds...")
-		select {
-		case <-time.After(time.Second):
-		case <-osSignal:
-			log.Print("sigterm received")
-			return true
-		case <-quitChan:
-			return true
+// Heartbeats for the systemd watchdog are sent from the control loops, so it fires if they get stuck.
+var watchdogChan <-chan time.Time
+
+// Returns false if the new config is invalid, and the old config is kept in this case.
+func reloadConfig() bool {
+	log.Print("sighup received, reloading config")
+	sdNotify.reloading()
+
+	if err := loadArgs(); err != nil {
+		log.Error("can't reload config: ", err)
+		// Systemd waits for READY=1 after RELOADING=1, even if the reload has failed.
+		sdNotify.ready("can't reload config: " + err.Error())
+		return false
+	}
+	return true
+}
+
+func wait(d time.Duration, osSignal, reloadSignal chan os.Signal) (shouldExit bool) {
+	for remaining := d; remaining > 0; remaining -= time.Second {
+		log.Print("waiting ", remaining.Round(100*time.Millisecond), "...")
+		step := time.Second
+		if remaining < step {
+			step = remaining
+		}
+		timeout := time.After(step)
+	waitLoop:
+		for {
+			select {
+			case <-timeout:
+				break waitLoop
+			case <-watchdogChan:
+				sdNotify.heartbeat()
+			case <-reloadSignal:
+				// The new config is used when the control stream is restarted after the wait.
+				reloadConfig()
+			case <-osSignal:
+				log.Print("sigterm received")
+				return true
+			case <-quitChan:
+				return true
+			}
 		}
 	}
 	return false
 }
 
-func runControlStream(osSignal chan os.Signal) (requireWait, shouldExit bool, exitCode int) {
+// Returns the error which caused the control stream to stop, it's nil if the stream was restarted because
+// of a config reload.
+func runControlStream(osSignal, reloadSignal chan os.Signal) (restartErr error, shouldExit bool, exitCode int) {
 	// Depleting gotErrChan.
 	var finished bool
 	for !finished {
@@ -58,41 +88,45 @@ func runControlStream(osSignal chan os.Signal) (requireWait, shouldExit bool, ex
 	if err := ctrl.init(); err != nil {
 		log.Error(err)
 		ctrl.deinit()
-		if strings.Contains(err.Error(), "invalid username/password") {
-			return false, true, 1
+		if getConnErrorKind(err) == connErrorInvalidCredentials {
+			return err, true, 1
 		}
-		return
+		return err, false, 0
 	}
 
-	select {
-	// Need to wait before reinit because the IC-705 will disconnect our audio stream eventually if we relogin
-	// in a too short interval without a deauth...
-	case requireWait = <-gotErrChan:
-		ctrl.deinit()
-		return
-	case <-osSignal:
-		log.Print("sigterm received")
-		ctrl.deinit()
-		return false, true, 0
-	case <-quitChan:
-		ctrl.deinit()
-		return false, true, 0
+	for {
+		select {
+		case restartErr = <-gotErrChan:
+			ctrl.deinit()
+			return
+		case <-watchdogChan:
+			sdNotify.heartbeat()
+		case <-reloadSignal:
+			if reloadConfig() {
+				// Restarting the control stream to apply the new config.
+				ctrl.deinit()
+				return nil, false, 0
+			}
+		case <-osSignal:
+			log.Print("sigterm received")
+			ctrl.deinit()
+			return nil, true, 0
+		case <-quitChan:
+			ctrl.deinit()
+			return nil, true, 0
+		}
 	}
 }
 
 func reportError(err error) {
 	if !strings.Contains(err.Error(), "use of closed network connection") {
 		log.ErrorC(log.GetCallerFileName(true), ": ", err)
-	}
-
-	requireWait := true
-	if strings.Contains(err.Error(), "got radio disconnected") {
-		requireWait = false
+		eventBus.publishEvent("error", map[string]string{"error": err.Error()})
 	}
 
 	// Non-blocking notify.
 	select {
-	case gotErrChan <- requireWait:
+	case gotErrChan <- err:
 	default:
 	}
 }
@@ -101,18 +135,28 @@ func main() {
 	parseArgs()
 	log.Init()
 	log.Print(getAboutStr())
+	selectRadioModel(civAddress)
+
+	if err := webSrv.init(); err != nil {
+		log.Error("can't start the http server: ", err)
+		log.Deinit()
+		os.Exit(1)
+	}
 
 	osSignal := make(chan os.Signal, 1)
 	signal.Notify(osSignal, os.Interrupt, syscall.SIGTERM)
+	reloadSignal := make(chan os.Signal, 1)
+	signal.Notify(reloadSignal, syscall.SIGHUP)
+	watchdogChan = sdNotify.getWatchdogChan()
+	sdNotify.startWatchdog()
 
-	var retries int
-	var requireWait bool
+	var restartErr error
 	var shouldExit bool
 	var exitCode int
 
 exit:
 	for {
-		requireWait, shouldExit, exitCode = runControlStream(osSignal)
+		restartErr, shouldExit, exitCode = runControlStream(osSignal, reloadSignal)
 
 		if shouldExit {
 			break
@@ -127,26 +171,36 @@ exit:
 		default:
 		}
 
-		if requireWait {
-			if retries < retryCount {
-				retries++
-				shouldExit = wait(waitBetweenRetries, osSignal)
-			} else {
-				retries = 0
-				shouldExit = wait(waitOnRetryFailure, osSignal)
+		// Need to wait before reinit because the IC-705 will disconnect our audio stream eventually if we relogin
+		// in a too short interval without a deauth...
+		delay := reconnectInitialDelay
+		reason := "reload"
+		if restartErr != nil {
+			var ok bool
+			delay, ok = reconnect.getDelay(restartErr)
+			if !ok {
+				log.Error("giving up after ", reconnectGiveUpAfter, " failed reconnect attempts")
+				exitCode = 1
+				break
 			}
-		} else {
-			retries = 0
-			shouldExit = wait(time.Second, osSignal)
+			reconnect.publish(true, delay)
+			_, attempt, r, _ := reconnect.get()
+			reason = r
+			log.Printw("reconnecting", "attempt", attempt, "reason", reason, "delay", delay.String())
+			sdNotify.status("reconnecting, attempt " + strconv.Itoa(attempt) + ": " + restartErr.Error())
 		}
+		shouldExit = wait(delay, osSignal, reloadSignal)
 
 		if shouldExit {
 			break
 		}
 		log.Print("restarting control stream...")
+		metrics.reportReconnect(reason)
 	}
 
 	rigctld.deinit()
+	webSrv.deinit()
+	mqttBridge.deinit()
 	serialTCPSrv.deinit()
 	runCmdRunner.stop()
 	serialCmdRunner.stop()
@@ -158,5 +212,7 @@ exit:
 	}
 
 	log.Print("exiting")
+	sdNotify.stopping()
+	log.Deinit()
 	os.Exit(exitCode)
 }
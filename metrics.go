@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Per-stream network counters are provided by netstat, these counters are also never reset.
+type metricsStruct struct {
+	mutex sync.Mutex
+
+	// Keys are the reconnect reasons.
+	reconnects   map[string]uint64
+	authTimeouts uint64
+
+	txActive    bool
+	txStartedAt time.Time
+	txDuration  time.Duration
+}
+
+var metrics metricsStruct
+
+func (m *metricsStruct) reportReconnect(reason string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.reconnects == nil {
+		m.reconnects = make(map[string]uint64)
+	}
+	m.reconnects[reason]++
+}
+
+func (m *metricsStruct) reportAuthTimeout() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.authTimeouts++
+}
+
+func (m *metricsStruct) reportTxActive(active bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if active == m.txActive {
+		return
+	}
+	m.txActive = active
+	if active {
+		m.txStartedAt = time.Now()
+	} else {
+		m.txDuration += time.Since(m.txStartedAt)
+	}
+}
+
+// Converts S meter values like S9+20 to S units (11 in this case).
+func (m *metricsStruct) getSUnits(sValue string) float64 {
+	if !strings.HasPrefix(sValue, "S") {
+		return 0
+	}
+	parts := strings.SplitN(sValue[1:], "+", 2)
+	s, _ := strconv.Atoi(parts[0])
+	res := float64(s)
+	if len(parts) > 1 {
+		db, _ := strconv.Atoi(parts[1])
+		res += float64(db) / 10
+	}
+	return res
+}
+
+func (m *metricsStruct) writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (m *metricsStruct) writeStreamValues(w io.Writer, names []string, stats map[string]netstatStreamStats,
+	name, typ, help string, get func(st netstatStreamStats) interface{}) {
+
+	m.writeHeader(w, name, typ, help)
+	for _, n := range names {
+		fmt.Fprintf(w, "%s{stream=%q} %v\n", name, n, get(stats[n]))
+	}
+}
+
+func (m *metricsStruct) writeValue(w io.Writer, name, typ, help string, v interface{}) {
+	m.writeHeader(w, name, typ, help)
+	fmt.Fprintf(w, "%s %v\n", name, v)
+}
+
+func (m *metricsStruct) boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Writes the metrics in the Prometheus text exposition format.
+func (m *metricsStruct) write(w io.Writer) {
+	civControl.state.mutex.Lock()
+	sUnits := m.getSUnits(civControl.state.sValue)
+	sDBm := civControl.state.sDBm
+	swr := civControl.state.swr
+	vd := civControl.state.vd
+	po := civControl.state.poWatts
+	alc := civControl.state.alc
+	comp := civControl.state.comp
+	id := civControl.state.id
+	freq := civControl.state.freq
+	txPwr := civControl.state.pwrPercent
+	civControl.state.mutex.Unlock()
+
+	names, stats := netstat.getStreams()
+	m.writeStreamValues(w, names, stats, "kappanhang_stream_tx_packets_total", "counter",
+		"Packets sent to the radio.",
+		func(st netstatStreamStats) interface{} { return st.toRadioPkts })
+	m.writeStreamValues(w, names, stats, "kappanhang_stream_tx_bytes_total", "counter",
+		"Bytes sent to the radio.",
+		func(st netstatStreamStats) interface{} { return st.toRadioBytes })
+	m.writeStreamValues(w, names, stats, "kappanhang_stream_rx_packets_total", "counter",
+		"Packets received from the radio.",
+		func(st netstatStreamStats) interface{} { return st.fromRadioPkts })
+	m.writeStreamValues(w, names, stats, "kappanhang_stream_rx_bytes_total", "counter",
+		"Bytes received from the radio.",
+		func(st netstatStreamStats) interface{} { return st.fromRadioBytes })
+	m.writeStreamValues(w, names, stats, "kappanhang_stream_retransmit_requests_sent_total", "counter",
+		"Retransmit requests sent to the radio.",
+		func(st netstatStreamStats) interface{} { return st.retransmitReqsSent })
+	m.writeStreamValues(w, names, stats, "kappanhang_stream_retransmit_requested_packets_total", "counter",
+		"Packets requested to be retransmitted by the radio.",
+		func(st netstatStreamStats) interface{} { return st.requestedRetransmits })
+	m.writeStreamValues(w, names, stats, "kappanhang_stream_retransmit_requests_received_total", "counter",
+		"Retransmit requests received from the radio.",
+		func(st netstatStreamStats) interface{} { return st.retransmitReqsRecv })
+	m.writeStreamValues(w, names, stats, "kappanhang_stream_retransmitted_packets_total", "counter",
+		"Packets retransmitted to the radio on its request.",
+		func(st netstatStreamStats) interface{} { return st.retransmittedPkts })
+	m.writeStreamValues(w, names, stats, "kappanhang_stream_lost_packets_total", "counter",
+		"Packets lost from the radio.",
+		func(st netstatStreamStats) interface{} { return st.lostPkts })
+	m.writeStreamValues(w, names, stats, "kappanhang_stream_out_of_order_packets_total", "counter",
+		"Packets received out of order from the radio.",
+		func(st netstatStreamStats) interface{} { return st.outOfOrderPkts })
+	m.writeStreamValues(w, names, stats, "kappanhang_stream_duplicate_packets_total", "counter",
+		"Duplicate packets received from the radio.",
+		func(st netstatStreamStats) interface{} { return st.duplicatePkts })
+	m.writeStreamValues(w, names, stats, "kappanhang_stream_jitter_seconds", "gauge",
+		"Interarrival jitter of the data packets received from the radio.",
+		func(st netstatStreamStats) interface{} { return st.jitter.Seconds() })
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.writeValue(w, "kappanhang_rtt_seconds", "gauge", "Round trip time of the control stream.",
+		controlStreamLatency.Seconds())
+	m.writeHeader(w, "kappanhang_reconnects_total", "counter", "Control stream restarts by reason.")
+	reasons := make([]string, 0, len(m.reconnects))
+	for r := range m.reconnects {
+		reasons = append(reasons, r)
+	}
+	sort.Strings(reasons)
+	for _, r := range reasons {
+		fmt.Fprintf(w, "kappanhang_reconnects_total{reason=%q} %d\n", r, m.reconnects[r])
+	}
+	_, attempt, _, _ := reconnect.get()
+	m.writeValue(w, "kappanhang_reconnect_attempt", "gauge",
+		"Consecutive failed reconnect attempts, 0 if connected.", attempt)
+	m.writeValue(w, "kappanhang_auth_timeouts_total", "counter", "Radio auth timeouts.", m.authTimeouts)
+
+	txDuration := m.txDuration
+	if m.txActive {
+		txDuration += time.Since(m.txStartedAt)
+	}
+	m.writeValue(w, "kappanhang_tx_active", "gauge", "1 if the radio is transmitting (PTT or tune).",
+		m.boolToInt(m.txActive))
+	m.writeValue(w, "kappanhang_tx_seconds_total", "counter", "Time spent transmitting.", txDuration.Seconds())
+
+	m.writeValue(w, "kappanhang_smeter_s_units", "gauge", "S meter value in S units, S9+10dB is 10.", sUnits)
+	m.writeValue(w, "kappanhang_smeter_dbm", "gauge", "S meter value in dBm.", sDBm)
+	m.writeValue(w, "kappanhang_swr", "gauge", "SWR reported during TX.", swr)
+	m.writeValue(w, "kappanhang_vd_volts", "gauge", "Drain voltage of the final amplifier.", vd)
+	m.writeValue(w, "kappanhang_po_watts", "gauge", "Output power reported during TX.", po)
+	m.writeValue(w, "kappanhang_alc_ratio", "gauge", "ALC meter reported during TX, 1 is the top of the ALC zone.", alc)
+	m.writeValue(w, "kappanhang_comp_db", "gauge", "Speech compression level reported during TX.", comp)
+	m.writeValue(w, "kappanhang_id_amperes", "gauge", "Drain current of the final amplifier reported during TX.", id)
+	m.writeValue(w, "kappanhang_frequency_hertz", "gauge", "Operating frequency.", freq)
+	m.writeValue(w, "kappanhang_tx_power_percent", "gauge", "TX power setting.", txPwr)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const mqttBridgeQoS = 0
+const mqttBridgeDisconnectWait = 250 // Milliseconds.
+
+// Some state keys are published on more descriptive topics.
+var mqttBridgeTopicNames = map[string]string{
+	"s": "smeter",
+}
+
+type mqttBridgeStruct struct {
+	client      mqtt.Client
+	topicPrefix string
+	eventChan   chan []byte
+
+	loopFinishedChan chan bool
+}
+
+var mqttBridge mqttBridgeStruct
+
+func (s *mqttBridgeStruct) publish(topic string, payload string, retained bool) {
+	t := s.client.Publish(s.topicPrefix+"/"+topic, mqttBridgeQoS, retained, payload)
+	go func() {
+		if t.WaitTimeout(time.Second) && t.Error() != nil {
+			log.Error("can't publish to ", topic, ": ", t.Error())
+		}
+	}()
+}
+
+func (s *mqttBridgeStruct) handleEventBusMsg(b []byte) {
+	var m eventBusMsg
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		log.Error(err)
+		return
+	}
+
+	switch m.Type {
+	case "state":
+		for k, v := range m.State {
+			if n, ok := mqttBridgeTopicNames[k]; ok {
+				k = n
+			}
+			s.publish(k, fmt.Sprint(v), true)
+		}
+	case "event":
+		var payload []byte
+		if m.Data != nil {
+			payload, _ = json.Marshal(m.Data)
+		}
+		s.publish("event/"+m.Event, string(payload), false)
+	}
+}
+
+func (s *mqttBridgeStruct) handleSet(client mqtt.Client, msg mqtt.Message) {
+	name := strings.TrimPrefix(msg.Topic(), s.topicPrefix+"/set/")
+	v := strings.TrimSpace(string(msg.Payload()))
+
+	log.Debug("mqtt set ", name, " to ", v)
+	if err := setRadioParam(civSourceAPI, name, v); err != nil {
+		log.Error("can't set ", name, " from mqtt: ", err)
+	}
+}
+
+func (s *mqttBridgeStruct) handleConnect(client mqtt.Client) {
+	log.Print("connected to mqtt broker")
+
+	s.publish("status", "online", true)
+
+	t := client.Subscribe(s.topicPrefix+"/set/+", mqttBridgeQoS, s.handleSet)
+	go func() {
+		if t.WaitTimeout(5*time.Second) && t.Error() != nil {
+			log.Error("can't subscribe to mqtt set topics: ", t.Error())
+		}
+	}()
+}
+
+func (s *mqttBridgeStruct) loop() {
+	for b := range s.eventChan {
+		s.handleEventBusMsg(b)
+	}
+	s.loopFinishedChan <- true
+}
+
+// We only init the MQTT bridge once, with the first device name we acquire.
+func (s *mqttBridgeStruct) initIfNeeded(devName string) {
+	if s.client != nil || mqttBroker == "" {
+		return
+	}
+
+	s.topicPrefix = mqttTopicPrefix + "/" + strings.NewReplacer(" ", "_", "/", "_", "+", "_", "#", "_").Replace(devName)
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(mqttBroker)
+	opts.SetClientID("kappanhang-" + devName)
+	opts.SetUsername(mqttUsername)
+	opts.SetPassword(mqttPassword)
+	opts.SetAutoReconnect(true)
+	opts.SetConnectRetry(true)
+	opts.SetWill(s.topicPrefix+"/status", "offline", mqttBridgeQoS, true)
+	opts.SetOnConnectHandler(s.handleConnect)
+	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
+		log.Error("lost connection to mqtt broker: ", err)
+	})
+
+	log.Print("connecting to mqtt broker ", mqttBroker, ", topic prefix: ", s.topicPrefix)
+
+	s.client = mqtt.NewClient(opts)
+	// With connect retry enabled, the client keeps trying to connect in the background.
+	s.client.Connect()
+
+	s.eventChan = eventBus.subscribe()
+	s.loopFinishedChan = make(chan bool)
+	go s.loop()
+}
+
+func (s *mqttBridgeStruct) deinit() {
+	if s.client == nil {
+		return
+	}
+
+	eventBus.unsubscribe(s.eventChan)
+	<-s.loopFinishedChan
+
+	if s.client.IsConnected() {
+		s.client.Publish(s.topicPrefix+"/status", mqttBridgeQoS, true, "offline").WaitTimeout(time.Second)
+	}
+	s.client.Disconnect(mqttBridgeDisconnectWait)
+}
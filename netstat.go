@@ -2,92 +2,247 @@ package main
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
 
+// The lost and retransmit counts on the status bar are reset if there were no new reports in this interval.
+const netstatReportWindow = time.Minute
+
+// These counters are monotonic, they are never reset.
+type netstatStreamCounters struct {
+	toRadioBytes   uint64
+	toRadioPkts    uint64
+	fromRadioBytes uint64
+	fromRadioPkts  uint64
+
+	lostPkts uint64
+	// Retransmit requests sent by us, and the number of packets requested in them.
+	retransmitReqsSent   uint64
+	requestedRetransmits uint64
+	// Retransmit requests sent by the radio, and the number of packets requested in them.
+	retransmitReqsRecv uint64
+	retransmittedPkts  uint64
+
+	outOfOrderPkts uint64
+	duplicatePkts  uint64
+}
+
+type netstatStreamStats struct {
+	netstatStreamCounters
+
+	// Smoothed interarrival jitter of the data packets, see RFC 3550.
+	jitter time.Duration
+
+	// Calculated on each get() call.
+	toRadioBytesPerSec   int
+	fromRadioBytesPerSec int
+}
+
+type netstatStream struct {
+	stats netstatStreamStats
+
+	lastArrivalAt     time.Time
+	lastInterarrival  time.Duration
+	countersAtLastGet netstatStreamCounters
+}
+
 type netstatStruct struct {
-	toRadioBytes   int
-	toRadioPkts    int
-	fromRadioBytes int
-	fromRadioPkts  int
-	lastGet        time.Time
+	streams map[string]*netstatStream
+	lastGet time.Time
 
 	lostPkts             int
 	lastLostReport       time.Time
 	retransmits          int
 	lastRetransmitReport time.Time
+
+	// The result of the last get() call.
+	last struct {
+		toRadioBytesPerSec   int
+		fromRadioBytesPerSec int
+		lost                 int
+		retransmits          int
+	}
 }
 
 var netstat netstatStruct
 var netstatMutex sync.Mutex
 
+func (b *netstatStruct) getStream(name string) *netstatStream {
+	if b.streams == nil {
+		b.streams = make(map[string]*netstatStream)
+	}
+	st, ok := b.streams[name]
+	if !ok {
+		st = &netstatStream{}
+		b.streams[name] = st
+	}
+	return st
+}
+
+// Per-stream counters are kept, so they stay monotonic between reconnects.
 func (b *netstatStruct) reset() {
 	netstatMutex.Lock()
 	defer netstatMutex.Unlock()
 
-	netstat = netstatStruct{}
+	for _, st := range b.streams {
+		st.lastArrivalAt = time.Time{}
+		st.lastInterarrival = 0
+		st.stats.jitter = 0
+		st.stats.toRadioBytesPerSec = 0
+		st.stats.fromRadioBytesPerSec = 0
+		st.countersAtLastGet = st.stats.netstatStreamCounters
+	}
+	b.lastGet = time.Now()
+	b.lostPkts = 0
+	b.retransmits = 0
+	b.last.toRadioBytesPerSec = 0
+	b.last.fromRadioBytesPerSec = 0
+	b.last.lost = 0
+	b.last.retransmits = 0
 }
 
 // Call this function when a packet is sent or received.
-func (b *netstatStruct) add(toRadioBytes, fromRadioBytes int) {
+func (b *netstatStruct) add(stream string, toRadioBytes, fromRadioBytes int) {
 	netstatMutex.Lock()
 	defer netstatMutex.Unlock()
 
-	b.toRadioBytes += toRadioBytes
+	c := &b.getStream(stream).stats
+	c.toRadioBytes += uint64(toRadioBytes)
 	if toRadioBytes > 0 {
-		b.toRadioPkts++
+		c.toRadioPkts++
 	}
-	b.fromRadioBytes += fromRadioBytes
+	c.fromRadioBytes += uint64(fromRadioBytes)
 	if fromRadioBytes > 0 {
-		b.fromRadioPkts++
+		c.fromRadioPkts++
+	}
+}
+
+// Call this function when a data packet arrives, it's used for the jitter calculation.
+func (b *netstatStruct) reportArrival(stream string) {
+	netstatMutex.Lock()
+	defer netstatMutex.Unlock()
+
+	st := b.getStream(stream)
+	now := time.Now()
+	if !st.lastArrivalAt.IsZero() {
+		interarrival := now.Sub(st.lastArrivalAt)
+		if st.lastInterarrival > 0 {
+			d := interarrival - st.lastInterarrival
+			if d < 0 {
+				d = -d
+			}
+			st.stats.jitter += (d - st.stats.jitter) / 16
+		}
+		st.lastInterarrival = interarrival
 	}
+	st.lastArrivalAt = now
 }
 
-func (b *netstatStruct) reportLoss(pkts int) {
+func (b *netstatStruct) reportLoss(stream string, pkts int) {
 	netstatMutex.Lock()
 	defer netstatMutex.Unlock()
 
+	b.getStream(stream).stats.lostPkts += uint64(pkts)
 	b.lastLostReport = time.Now()
 	b.lostPkts += pkts
 }
 
-func (b *netstatStruct) reportRetransmit(pkts int) {
+// Call this when we request packets to be retransmitted by the radio.
+func (b *netstatStruct) reportRetransmitRequestSent(stream string, pkts int) {
+	netstatMutex.Lock()
+	defer netstatMutex.Unlock()
+
+	c := &b.getStream(stream).stats
+	c.retransmitReqsSent++
+	c.requestedRetransmits += uint64(pkts)
+	b.lastRetransmitReport = time.Now()
+	b.retransmits += pkts
+}
+
+// Call this when the radio requests packets to be retransmitted by us.
+func (b *netstatStruct) reportRetransmitRequestReceived(stream string, pkts int) {
 	netstatMutex.Lock()
 	defer netstatMutex.Unlock()
 
+	c := &b.getStream(stream).stats
+	c.retransmitReqsRecv++
+	c.retransmittedPkts += uint64(pkts)
 	b.lastRetransmitReport = time.Now()
 	b.retransmits += pkts
 }
 
+func (b *netstatStruct) reportOutOfOrder(stream string) {
+	netstatMutex.Lock()
+	defer netstatMutex.Unlock()
+
+	b.getStream(stream).stats.outOfOrderPkts++
+}
+
+func (b *netstatStruct) reportDuplicate(stream string) {
+	netstatMutex.Lock()
+	defer netstatMutex.Unlock()
+
+	b.getStream(stream).stats.duplicatePkts++
+}
+
+// Returns the summed stats of all streams since the last call. Lost and retransmit counts are only reset
+// if there were no new reports in the last minute.
 func (b *netstatStruct) get() (toRadioBytesPerSec, fromRadioBytesPerSec int, lost int, retransmits int) {
 	netstatMutex.Lock()
 	defer netstatMutex.Unlock()
 
 	secs := time.Since(b.lastGet).Seconds()
-	toRadioBytesPerSec = int(float64(b.toRadioBytes) / secs)
-	fromRadioBytesPerSec = int(float64(b.fromRadioBytes) / secs)
+	for _, st := range b.streams {
+		c := &st.stats.netstatStreamCounters
+		st.stats.toRadioBytesPerSec = int(float64(c.toRadioBytes-st.countersAtLastGet.toRadioBytes) / secs)
+		st.stats.fromRadioBytesPerSec = int(float64(c.fromRadioBytes-st.countersAtLastGet.fromRadioBytes) / secs)
+		st.countersAtLastGet = *c
 
-	b.toRadioBytes = 0
-	b.toRadioPkts = 0
-	b.fromRadioBytes = 0
-	b.fromRadioPkts = 0
+		toRadioBytesPerSec += st.stats.toRadioBytesPerSec
+		fromRadioBytesPerSec += st.stats.fromRadioBytesPerSec
+	}
 	b.lastGet = time.Now()
 
-	secs = time.Since(b.lastLostReport).Seconds()
 	lost = b.lostPkts
-	if secs >= 60 {
+	if time.Since(b.lastLostReport) >= netstatReportWindow {
 		b.lostPkts = 0
 		b.lastLostReport = time.Now()
 	}
 
-	secs = time.Since(b.lastRetransmitReport).Seconds()
 	retransmits = b.retransmits
-	if secs >= 60 {
+	if time.Since(b.lastRetransmitReport) >= netstatReportWindow {
 		b.retransmits = 0
 		b.lastRetransmitReport = time.Now()
 	}
 
+	b.last.toRadioBytesPerSec = toRadioBytesPerSec
+	b.last.fromRadioBytesPerSec = fromRadioBytesPerSec
+	b.last.lost = lost
+	b.last.retransmits = retransmits
+	return
+}
+
+// Returns the result of the last get() call without resetting the counters.
+func (b *netstatStruct) getLast() (toRadioBytesPerSec, fromRadioBytesPerSec int, lost int, retransmits int) {
+	netstatMutex.Lock()
+	defer netstatMutex.Unlock()
+
+	return b.last.toRadioBytesPerSec, b.last.fromRadioBytesPerSec, b.last.lost, b.last.retransmits
+}
+
+// Returns the stream names in alphabetical order, and a copy of the stats of each stream.
+func (b *netstatStruct) getStreams() (names []string, stats map[string]netstatStreamStats) {
+	netstatMutex.Lock()
+	defer netstatMutex.Unlock()
+
+	stats = make(map[string]netstatStreamStats)
+	for n, st := range b.streams {
+		names = append(names, n)
+		stats[n] = st.stats
+	}
+	sort.Strings(names)
 	return
 }
 
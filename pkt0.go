@@ -26,12 +26,12 @@ type pkt0Type struct {
 }
 
 func (p *pkt0Type) retransmitRange(s *streamCommon, start, end uint16) error {
-	log.Debug(s.name+"/got retransmit request for #", start, "-", end)
+	log.Debugw("got retransmit request", "stream", s.name, "from", start, "to", end)
+	netstat.reportRetransmitRequestReceived(s.name, int(end-start)+1)
 	for {
-		netstat.reportRetransmit(1)
 		d := p.txSeqBuf.get(seqNum(start))
 		if d != nil {
-			log.Debug(s.name+"/retransmitting #", start)
+			log.Debugw("retransmitting", "stream", s.name, "seq", start)
 			if err := s.send(d); err != nil {
 				return err
 			}
@@ -39,7 +39,7 @@ func (p *pkt0Type) retransmitRange(s *streamCommon, start, end uint16) error {
 				return err
 			}
 		} else {
-			log.Debug(s.name+"/can't retransmit #", start, " - not found ")
+			log.Debugw("can't retransmit, not found", "stream", s.name, "seq", start)
 
 			// Sending an idle with the requested seqnum.
 			if err := p.sendIdle(s, false, start); err != nil {
@@ -66,10 +66,10 @@ func (p *pkt0Type) handle(s *streamCommon, r []byte) error {
 	if bytes.Equal(r[:6], []byte{0x10, 0x00, 0x00, 0x00, 0x01, 0x00}) {
 		seq := binary.LittleEndian.Uint16(r[6:8])
 		d := p.txSeqBuf.get(seqNum(seq))
-		log.Debug(s.name+"/got retransmit request for #", seq)
+		log.Debugw("got retransmit request", "stream", s.name, "seq", seq)
+		netstat.reportRetransmitRequestReceived(s.name, 1)
 		if d != nil {
-			log.Debug(s.name+"/retransmitting #", seq)
-			netstat.reportRetransmit(1)
+			log.Debugw("retransmitting", "stream", s.name, "seq", seq)
 			if err := s.send(d); err != nil {
 				return err
 			}
@@ -77,7 +77,7 @@ func (p *pkt0Type) handle(s *streamCommon, r []byte) error {
 				return err
 			}
 		} else {
-			log.Debug(s.name+"/can't retransmit #", seq, " - not found")
+			log.Debugw("can't retransmit, not found", "stream", s.name, "seq", seq)
 
 			// Sending an idle with the requested seqnum.
 			if err := p.sendIdle(s, false, seq); err != nil {
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/binary"
-	"errors"
 	"time"
 )
 
@@ -123,7 +122,7 @@ func (p *pkt7Type) loop(s *streamCommon) {
 		if p.timeoutTimer != nil {
 			select {
 			case <-p.timeoutTimer.C:
-				reportError(errors.New(s.name + "/ping timeout"))
+				reportError(newConnError(connErrorPingTimeout, s.name+"/ping timeout"))
 
 			case <-p.sendTicker.C:
 				if err := p.send(s); err != nil {
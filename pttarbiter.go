@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Audio which can be sent to the radio while transmitting.
+type audioTxRoute int
+
+const (
+	audioTxRouteVirtualSoundcard audioTxRoute = iota
+	audioTxRouteDefaultSoundcard
+	audioTxRouteWeb
+)
+
+var audioTxRouteNames = []string{"virtual soundcard", "default soundcard", "web"}
+
+func (r audioTxRoute) String() string {
+	if int(r) < len(audioTxRouteNames) {
+		return audioTxRouteNames[r]
+	}
+	return "unknown"
+}
+
+// These sources can own the PTT. If the radio reports PTT on without an owner (VOX, the radio's own PTT
+// button or a raw CI-V client), then the owner is civSourceRadio, which can be taken over by any source.
+var pttOwnerSources = []civSource{civSourceHotkey, civSourceAPI, civSourceRigctld, civSourceTCP, civSourcePTY}
+
+// Parses a comma separated list of PTT owners, the first one has the highest priority.
+func parsePTTPriority(str string) (res []civSource, err error) {
+	for _, s := range strings.Split(str, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		found := false
+		for _, src := range pttOwnerSources {
+			if src.String() == s {
+				res = append(res, src)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("invalid PTT owner " + s)
+		}
+	}
+	return res, nil
+}
+
+var errPTTHeld = errors.New("ptt is held")
+
+// Decides which source can key the radio if several sources want to transmit at the same time.
+type pttArbiterStruct struct {
+	mutex sync.Mutex
+
+	owner    civSource
+	hasOwner bool
+
+	// A raw CI-V client which has sent a PTT on frame. It only becomes the owner when the radio reports
+	// PTT on, so a refused or lost frame does not leave it owning the PTT.
+	pendingOwner       civSource
+	pendingOwnerSentAt time.Time
+}
+
+var pttArbiter pttArbiterStruct
+
+// Higher value means higher priority. Sources not in the priority list have the lowest priority.
+func (p *pttArbiterStruct) getPriority(src civSource) int {
+	for i := range pttPriority {
+		if pttPriority[i] == src {
+			return len(pttPriority) - i
+		}
+	}
+	return 0
+}
+
+// Returns the audio route which is sent to the radio when the given source owns the PTT.
+func (p *pttArbiterStruct) getAudioTxRoute(src civSource) audioTxRoute {
+	switch src {
+	case civSourceHotkey:
+		return audioTxRouteDefaultSoundcard
+	case civSourceAPI:
+		return audioTxRouteWeb
+	}
+	return audioTxRouteVirtualSoundcard
+}
+
+func (p *pttArbiterStruct) setOwner(owner civSource, hasOwner bool) {
+	p.owner = owner
+	p.hasOwner = hasOwner
+
+	ownerStr := ""
+	if hasOwner {
+		ownerStr = owner.String()
+	}
+	statusLog.reportPTTOwner(ownerStr)
+}
+
+// Should be called with the mutex locked. Returns nil if src can take the PTT.
+func (p *pttArbiterStruct) acquire(src civSource) error {
+	if !p.hasOwner || p.owner == src || p.owner == civSourceRadio {
+		p.setOwner(src, true)
+		return nil
+	}
+
+	if pttPreemption && p.getPriority(src) > p.getPriority(p.owner) {
+		log.Printw("ptt preempted", "owner", src.String(), "previousOwner", p.owner.String())
+		eventBus.publishEvent("pttPreempted", map[string]string{"owner": src.String(), "previousOwner": p.owner.String()})
+		p.setOwner(src, true)
+		return nil
+	}
+
+	log.Printw("ptt request rejected", "source", src.String(), "owner", p.owner.String())
+	return fmt.Errorf("%w by %s", errPTTHeld, p.owner.String())
+}
+
+// Keys or unkeys the radio on behalf of the given owner. The mutex is not held while waiting for the
+// radio's reply, as the decoder calls reportPTT() meanwhile.
+func (p *pttArbiterStruct) requestPTT(owner civSource, enable bool) error {
+	p.mutex.Lock()
+	if enable {
+		prevOwner, prevHasOwner := p.owner, p.hasOwner
+		if err := p.acquire(owner); err != nil {
+			p.mutex.Unlock()
+			return err
+		}
+		p.mutex.Unlock()
+
+		if err := civControl.sendPTT(owner, true); err != nil {
+			p.mutex.Lock()
+			if p.hasOwner && p.owner == owner {
+				p.setOwner(prevOwner, prevHasOwner)
+			}
+			p.mutex.Unlock()
+			return err
+		}
+		return nil
+	}
+
+	if p.hasOwner && p.owner != owner && p.owner != civSourceRadio {
+		// Someone else is transmitting, for example the operator has preempted rigctld.
+		log.Debugw("ignoring ptt release", "source", owner.String(), "owner", p.owner.String())
+		p.mutex.Unlock()
+		return nil
+	}
+	p.mutex.Unlock()
+
+	if err := civControl.sendPTT(owner, false); err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	if p.hasOwner && (p.owner == owner || p.owner == civSourceRadio) {
+		p.setOwner(civSourceInternal, false)
+	}
+	p.mutex.Unlock()
+	return nil
+}
+
+// Checks PTT frames coming from raw CI-V clients. The from address is set by the client, so it is not
+// used for skipping the check.
+func (p *pttArbiterStruct) checkCIVFrame(f *civFrame, source civSource) error {
+	if f.cmd != 0x1c || !f.hasSubCmd || f.subCmd != 0x00 || len(f.data) == 0 {
+		return nil
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if f.data[0] == 1 {
+		if !p.hasOwner {
+			p.pendingOwner = source
+			p.pendingOwnerSentAt = time.Now()
+			return nil
+		}
+		return p.acquire(source)
+	}
+	if p.hasOwner && p.owner != source && p.owner != civSourceRadio {
+		return fmt.Errorf("%w by %s", errPTTHeld, p.owner.String())
+	}
+	return nil
+}
+
+// Called when the radio reports the PTT state.
+func (p *pttArbiterStruct) reportPTT(ptt bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if ptt {
+		if !p.hasOwner {
+			if !p.pendingOwnerSentAt.IsZero() && time.Since(p.pendingOwnerSentAt) < civSentFrameTimeout {
+				p.setOwner(p.pendingOwner, true)
+			} else {
+				p.setOwner(civSourceRadio, true)
+			}
+		}
+	} else if p.hasOwner {
+		p.setOwner(civSourceInternal, false)
+	}
+	p.pendingOwnerSentAt = time.Time{}
+}
+
+// Called when the radio has refused a frame with NG, with civControl.state.mutex locked.
+func (p *pttArbiterStruct) reportCIVFrameRefused(f *civFrame) {
+	if f.cmd != 0x1c || !f.hasSubCmd || f.subCmd != 0x00 || len(f.data) == 0 || f.data[0] != 1 {
+		return
+	}
+
+	p.mutex.Lock()
+	p.pendingOwnerSentAt = time.Time{}
+	p.mutex.Unlock()
+
+	// The PTT state is updated from the echo of the PTT on frame, so it has to be read back from the radio.
+	// If the radio is not transmitting, then the PTT owner is released.
+	_ = civControl.getTransmitStatus()
+}
+
+// Returns false if audio from the given route should not be sent to the radio, as someone else owns the PTT.
+// If nobody owns the PTT, then all audio is sent, so the radio's VOX can work.
+func (p *pttArbiterStruct) isAudioTxRouteActive(r audioTxRoute) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.hasOwner || p.owner == civSourceRadio {
+		return true
+	}
+	return p.getAudioTxRoute(p.owner) == r
+}
+
+func (p *pttArbiterStruct) getOwner() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.hasOwner {
+		return ""
+	}
+	return p.owner.String()
+}
@@ -0,0 +1,88 @@
+package main
+
+import "testing"
+
+func testCIVPTTFrame(t *testing.T, on bool) civFrame {
+	var b byte
+	if on {
+		b = 1
+	}
+	f, ok := parseCIVFrame([]byte{0xfe, 0xfe, testCIVAddress, 0xe0, 0x1c, 0x00, b, 0xfd})
+	if !ok {
+		t.Fatal("can't parse frame")
+	}
+	return f
+}
+
+func TestPTTArbiterRawClientOwnership(t *testing.T) {
+	log.Init()
+	var p pttArbiterStruct
+	pttOn := testCIVPTTFrame(t, true)
+
+	if err := p.checkCIVFrame(&pttOn, civSourceTCP); err != nil {
+		t.Fatal(err)
+	}
+	if p.hasOwner {
+		t.Fatal("client owns the PTT before the radio has reported PTT on")
+	}
+	p.reportPTT(true)
+	if !p.hasOwner || p.owner != civSourceTCP {
+		t.Fatalf("owner = %v %v, want %v", p.hasOwner, p.owner, civSourceTCP)
+	}
+
+	if err := p.checkCIVFrame(&pttOn, civSourcePTY); err == nil {
+		t.Error("PTT on is accepted from another client")
+	}
+	pttOff := testCIVPTTFrame(t, false)
+	if err := p.checkCIVFrame(&pttOff, civSourcePTY); err == nil {
+		t.Error("PTT off is accepted from another client")
+	}
+
+	p.reportPTT(false)
+	if p.hasOwner {
+		t.Error("PTT is still owned after the radio has reported PTT off")
+	}
+}
+
+func TestPTTArbiterRefusedRawClientPTT(t *testing.T) {
+	log.Init()
+	var p pttArbiterStruct
+	pttOn := testCIVPTTFrame(t, true)
+
+	if err := p.checkCIVFrame(&pttOn, civSourceTCP); err != nil {
+		t.Fatal(err)
+	}
+	p.reportCIVFrameRefused(&pttOn)
+
+	// For example the operator keys the radio with its own PTT button.
+	p.reportPTT(true)
+	if !p.hasOwner || p.owner != civSourceRadio {
+		t.Fatalf("owner = %v %v, want %v", p.hasOwner, p.owner, civSourceRadio)
+	}
+	if err := p.acquire(civSourceRigctld); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPTTArbiterSpoofedFromAddress(t *testing.T) {
+	log.Init()
+	prevCIVAddress := civAddress
+	civAddress = testCIVAddress
+	t.Cleanup(func() { civAddress = prevCIVAddress })
+
+	var p pttArbiterStruct
+	pttOn := testCIVPTTFrame(t, true)
+	if err := p.checkCIVFrame(&pttOn, civSourceTCP); err != nil {
+		t.Fatal(err)
+	}
+	p.reportPTT(true)
+
+	// The other client sets the from address to the radio's address.
+	spoofedPTTOn, ok := parseCIVFrame([]byte{0xfe, 0xfe, testCIVAddress, testCIVAddress, 0x1c, 0x00, 0x01, 0xfd})
+	if !ok {
+		t.Fatal("can't parse frame")
+	}
+	if err := p.checkCIVFrame(&spoofedPTTOn, civSourcePTY); err == nil {
+		t.Error("PTT on with spoofed from address is accepted from another client")
+	}
+}
@@ -0,0 +1,548 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type radioNetstatStream struct {
+	UpBytesPerSec        int     `json:"upBytesPerSec"`
+	DownBytesPerSec      int     `json:"downBytesPerSec"`
+	UpPkts               uint64  `json:"upPkts"`
+	DownPkts             uint64  `json:"downPkts"`
+	Lost                 uint64  `json:"lost"`
+	RequestedRetransmits uint64  `json:"requestedRetransmits"`
+	RetransmittedPkts    uint64  `json:"retransmittedPkts"`
+	OutOfOrder           uint64  `json:"outOfOrder"`
+	Duplicates           uint64  `json:"duplicates"`
+	JitterMs             float64 `json:"jitterMs"`
+}
+
+type radioNetstat struct {
+	UpBytesPerSec   int                           `json:"upBytesPerSec"`
+	DownBytesPerSec int                           `json:"downBytesPerSec"`
+	Lost            int                           `json:"lost"`
+	Retransmits     int                           `json:"retransmits"`
+	RTTMs           int64                         `json:"rttMs"`
+	Streams         map[string]radioNetstatStream `json:"streams"`
+}
+
+type radioState struct {
+	Freq        uint         `json:"freq"`
+	SubFreq     uint         `json:"subFreq"`
+	Mode        string       `json:"mode"`
+	DataMode    bool         `json:"dataMode"`
+	Filter      string       `json:"filter"`
+	SubMode     string       `json:"subMode"`
+	SubDataMode bool         `json:"subDataMode"`
+	SubFilter   string       `json:"subFilter"`
+	VFO         string       `json:"vfo"`
+	Split       string       `json:"split"`
+	PTT         bool         `json:"ptt"`
+	PTTOwner    string       `json:"pttOwner"`
+	Tune        bool         `json:"tune"`
+	S           string       `json:"s"`
+	SDBm        float64      `json:"sDBm"`
+	OVF         bool         `json:"ovf"`
+	SWR         float64      `json:"swr"`
+	Vd          float64      `json:"vd"`
+	Po          float64      `json:"po"`
+	ALC         float64      `json:"alc"`
+	Comp        float64      `json:"comp"`
+	Id          float64      `json:"id"`
+	Pwr         int          `json:"pwr"`
+	RFGain      int          `json:"rfGain"`
+	SQL         int          `json:"sql"`
+	NR          int          `json:"nr"`
+	NREnabled   bool         `json:"nrEnabled"`
+	Preamp      int          `json:"preamp"`
+	AGC         string       `json:"agc"`
+	ATU         bool         `json:"atu"`
+	Antenna     int          `json:"antenna"`
+	Offset      uint         `json:"offset"`
+	ToneMode    string       `json:"toneMode"`
+	Tone        float64      `json:"tone"`
+	TSQLTone    float64      `json:"tsqlTone"`
+	DCSCode     int          `json:"dcsCode"`
+	ARP         string       `json:"arp"`
+	RIT         int          `json:"rit"`
+	RITEnabled  bool         `json:"ritEnabled"`
+	XITEnabled  bool         `json:"xitEnabled"`
+	PBTIn       int          `json:"pbtIn"`
+	PBTOut      int          `json:"pbtOut"`
+	ATT         int          `json:"att"`
+	NB          int          `json:"nb"`
+	NBEnabled   bool         `json:"nbEnabled"`
+	ANFEnabled  bool         `json:"anfEnabled"`
+	MNEnabled   bool         `json:"mnEnabled"`
+	CompLevel   int          `json:"compLevel"`
+	CompEnabled bool         `json:"compEnabled"`
+	MonGain     int          `json:"monGain"`
+	MonEnabled  bool         `json:"monEnabled"`
+	VOXGain     int          `json:"voxGain"`
+	VOXEnabled  bool         `json:"voxEnabled"`
+	TS          uint         `json:"ts"`
+	Netstat     radioNetstat `json:"netstat"`
+
+	Reconnecting     bool   `json:"reconnecting"`
+	ReconnectAttempt int    `json:"reconnectAttempt"`
+	ReconnectReason  string `json:"reconnectReason"`
+	LastError        string `json:"lastError"`
+
+	ListenOnly    bool `json:"listenOnly"`
+	CIVTransceive bool `json:"civTransceive"`
+}
+
+var splitModeNames = []string{"off", "on", "dup-", "dup+"}
+var agcNames = []string{"", "F", "M", "S"}
+
+func getOperatingModeName(idx int) string {
+	if idx < 0 || idx >= len(activeRadioModel.modes) {
+		return ""
+	}
+	return activeRadioModel.modes[idx].name
+}
+
+func getFilterName(idx int) string {
+	if idx < 0 || idx >= len(civFilters) {
+		return ""
+	}
+	return civFilters[idx].name
+}
+
+func getRadioState() (rs radioState) {
+	civControl.state.mutex.Lock()
+	rs.Freq = civControl.state.freq
+	rs.SubFreq = civControl.state.subFreq
+	rs.Mode = getOperatingModeName(civControl.state.operatingModeIdx)
+	rs.DataMode = civControl.state.dataMode
+	rs.Filter = getFilterName(civControl.state.filterIdx)
+	rs.SubMode = getOperatingModeName(civControl.state.subOperatingModeIdx)
+	rs.SubDataMode = civControl.state.subDataMode
+	rs.SubFilter = getFilterName(civControl.state.subFilterIdx)
+	rs.VFO = activeRadioModel.getVFOName(civControl.state.vfoBActive)
+	rs.Split = splitModeNames[civControl.state.splitMode]
+	rs.PTT = civControl.state.ptt
+	rs.Tune = civControl.state.tune
+	rs.S = civControl.state.sValue
+	rs.SDBm = civControl.state.sDBm
+	rs.OVF = civControl.state.ovf
+	rs.SWR = civControl.state.swr
+	rs.Vd = civControl.state.vd
+	rs.Po = civControl.state.poWatts
+	rs.ALC = civControl.state.alc
+	rs.Comp = civControl.state.comp
+	rs.Id = civControl.state.id
+	rs.Pwr = civControl.state.pwrPercent
+	rs.RFGain = civControl.state.rfGainPercent
+	rs.SQL = civControl.state.sqlPercent
+	rs.NR = civControl.state.nrPercent
+	rs.NREnabled = civControl.state.nrEnabled
+	rs.Preamp = civControl.state.preamp
+	if civControl.state.agc < len(agcNames) {
+		rs.AGC = agcNames[civControl.state.agc]
+	}
+	rs.TS = civControl.state.ts
+	rs.ATU = civControl.state.atuEnabled
+	rs.Antenna = civControl.state.antenna + 1
+	rs.Offset = civControl.state.offset
+	rs.ToneMode = civControl.getToneModeName()
+	rs.Tone = float64(civControl.state.tone) / 10
+	rs.TSQLTone = float64(civControl.state.tsqlTone) / 10
+	rs.DCSCode = civControl.state.dcsCode
+	rs.ARP = civControl.getARPName()
+	rs.RIT = civControl.state.ritOffset
+	rs.RITEnabled = civControl.state.ritEnabled
+	rs.XITEnabled = civControl.state.xitEnabled
+	rs.PBTIn = civControl.state.pbtIn
+	rs.PBTOut = civControl.state.pbtOut
+	rs.ATT = civControl.state.att
+	rs.NB = civControl.state.nbPercent
+	rs.NBEnabled = civControl.state.nbEnabled
+	rs.ANFEnabled = civControl.state.anfEnabled
+	rs.MNEnabled = civControl.state.mnEnabled
+	rs.CompLevel = civControl.state.compPercent
+	rs.CompEnabled = civControl.state.compEnabled
+	rs.MonGain = civControl.state.monPercent
+	rs.MonEnabled = civControl.state.monEnabled
+	rs.VOXGain = civControl.state.voxPercent
+	rs.VOXEnabled = civControl.state.voxEnabled
+	rs.CIVTransceive = civControl.state.transceiveActive
+	civControl.state.mutex.Unlock()
+
+	rs.Netstat.UpBytesPerSec, rs.Netstat.DownBytesPerSec, rs.Netstat.Lost, rs.Netstat.Retransmits = netstat.getLast()
+	rs.Netstat.RTTMs = controlStreamLatency.Milliseconds()
+	rs.Netstat.Streams = make(map[string]radioNetstatStream)
+	_, stats := netstat.getStreams()
+	for n, st := range stats {
+		rs.Netstat.Streams[n] = radioNetstatStream{
+			UpBytesPerSec:        st.toRadioBytesPerSec,
+			DownBytesPerSec:      st.fromRadioBytesPerSec,
+			UpPkts:               st.toRadioPkts,
+			DownPkts:             st.fromRadioPkts,
+			Lost:                 st.lostPkts,
+			RequestedRetransmits: st.requestedRetransmits,
+			RetransmittedPkts:    st.retransmittedPkts,
+			OutOfOrder:           st.outOfOrderPkts,
+			Duplicates:           st.duplicatePkts,
+			JitterMs:             float64(st.jitter.Microseconds()) / 1000,
+		}
+	}
+
+	rs.Reconnecting, rs.ReconnectAttempt, rs.ReconnectReason, rs.LastError = reconnect.get()
+	rs.ListenOnly = listenOnly
+	rs.PTTOwner = pttArbiter.getOwner()
+	return
+}
+
+func parseRadioParamBool(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "1", "true", "on":
+		return true, nil
+	case "0", "false", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid bool value %s", v)
+}
+
+func parseRadioParamPercent(v string) (int, error) {
+	p, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, err
+	}
+	if p < 0 || p > 100 {
+		return 0, fmt.Errorf("percent value %d out of range", p)
+	}
+	return p, nil
+}
+
+func parseRadioParamFreq(v string) (uint, error) {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, err
+	}
+	if f <= 0 {
+		return 0, fmt.Errorf("invalid frequency %s", v)
+	}
+	return uint(f), nil
+}
+
+func getOperatingModeCode(name string) (byte, error) {
+	for _, m := range activeRadioModel.modes {
+		if strings.EqualFold(m.name, name) {
+			return m.code, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown mode %s", name)
+}
+
+func getFilterCode(name string) (byte, error) {
+	for _, f := range civFilters {
+		if strings.EqualFold(f.name, name) {
+			return f.code, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown filter %s", name)
+}
+
+// Setters callable by name, used by the remote control interfaces. Values are in their string form.
+var radioParamSetters = map[string]func(src civSource, v string) error{
+	"freq": func(src civSource, v string) error {
+		f, err := parseRadioParamFreq(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setMainVFOFreq(src, f)
+	},
+	"subfreq": func(src civSource, v string) error {
+		f, err := parseRadioParamFreq(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setSubVFOFreq(src, f)
+	},
+	"mode": func(src civSource, v string) error {
+		modeCode, err := getOperatingModeCode(v)
+		if err != nil {
+			return err
+		}
+		filterCode, err := getFilterCode(getFilterName(civControl.state.filterIdx))
+		if err != nil {
+			filterCode = civFilters[0].code
+		}
+		return civControl.setOperatingModeAndFilter(src, modeCode, filterCode)
+	},
+	"filter": func(src civSource, v string) error {
+		filterCode, err := getFilterCode(v)
+		if err != nil {
+			return err
+		}
+		modeCode, err := getOperatingModeCode(getOperatingModeName(civControl.state.operatingModeIdx))
+		if err != nil {
+			return err
+		}
+		return civControl.setOperatingModeAndFilter(src, modeCode, filterCode)
+	},
+	"datamode": func(src civSource, v string) error {
+		b, err := parseRadioParamBool(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setDataMode(src, b)
+	},
+	"ptt": func(src civSource, v string) error {
+		b, err := parseRadioParamBool(v)
+		if err != nil {
+			return err
+		}
+		if b && setDataModeOnTx {
+			if err := civControl.setDataMode(src, true); err != nil {
+				return err
+			}
+		}
+		return pttArbiter.requestPTT(civSourceAPI, b)
+	},
+	"tune": func(src civSource, v string) error {
+		b, err := parseRadioParamBool(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setTune(src, b)
+	},
+	"pwr": func(src civSource, v string) error {
+		p, err := parseRadioParamPercent(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setPwr(src, p)
+	},
+	"rfgain": func(src civSource, v string) error {
+		p, err := parseRadioParamPercent(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setRFGain(src, p)
+	},
+	"sql": func(src civSource, v string) error {
+		p, err := parseRadioParamPercent(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setSQL(src, p)
+	},
+	"nr": func(src civSource, v string) error {
+		p, err := parseRadioParamPercent(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setNR(src, p)
+	},
+	"nrenabled": func(src civSource, v string) error {
+		b, err := parseRadioParamBool(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setNREnabled(src, b)
+	},
+	"ts": func(src civSource, v string) error {
+		ts, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			return err
+		}
+		for i := range civTuningSteps {
+			if civTuningSteps[i] == uint(ts) {
+				return civControl.setTS(src, byte(i))
+			}
+		}
+		return fmt.Errorf("unsupported tuning step %s", v)
+	},
+	"vfo": func(src civSource, v string) error {
+		nr, ok := activeRadioModel.parseVFOName(v)
+		if !ok {
+			return fmt.Errorf("unknown vfo %s", v)
+		}
+		return civControl.setVFO(src, nr)
+	},
+	"split": func(src civSource, v string) error {
+		for i := range splitModeNames {
+			if strings.EqualFold(splitModeNames[i], v) {
+				return civControl.setSplit(src, splitMode(i))
+			}
+		}
+		return fmt.Errorf("unknown split mode %s", v)
+	},
+	"preamp": func(src civSource, v string) error {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		if p < 0 || p > 2 {
+			return fmt.Errorf("invalid preamp value %d", p)
+		}
+		return civControl.setPreamp(src, byte(p))
+	},
+	"agc": func(src civSource, v string) error {
+		for i := 1; i < len(agcNames); i++ {
+			if strings.EqualFold(agcNames[i], v) || v == fmt.Sprint(i) {
+				return civControl.setAGC(src, byte(i))
+			}
+		}
+		return fmt.Errorf("unknown agc value %s", v)
+	},
+	"atu": func(src civSource, v string) error {
+		b, err := parseRadioParamBool(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setATU(src, b)
+	},
+	"antenna": func(src civSource, v string) error {
+		nr, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setAntenna(src, nr-1)
+	},
+	"offset": func(src civSource, v string) error {
+		f, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			return err
+		}
+		return civControl.setOffset(src, uint(f))
+	},
+	"toneMode": func(src civSource, v string) error {
+		for i := range civToneModeNames {
+			if strings.EqualFold(civToneModeNames[i], v) {
+				return civControl.setToneMode(src, i)
+			}
+		}
+		return fmt.Errorf("unknown tone mode %s", v)
+	},
+	"tone": func(src civSource, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		return civControl.setTone(src, int(math.Round(f*10)))
+	},
+	"tsqlTone": func(src civSource, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		return civControl.setTSQLTone(src, int(math.Round(f*10)))
+	},
+	"dcsCode": func(src civSource, v string) error {
+		c, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setDCSCode(src, c)
+	},
+	"arp": func(src civSource, v string) error {
+		for i := range civARPNames {
+			if strings.EqualFold(civARPNames[i], v) {
+				return civControl.setARP(src, i)
+			}
+		}
+		return fmt.Errorf("unknown auto repeater value %s", v)
+	},
+	"rit": func(src civSource, v string) error {
+		o, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setRITOffset(src, o)
+	},
+	"ritEnabled": func(src civSource, v string) error {
+		b, err := parseRadioParamBool(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setRITEnabled(src, b)
+	},
+	"xitEnabled": func(src civSource, v string) error {
+		b, err := parseRadioParamBool(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setXITEnabled(src, b)
+	},
+	"pbtIn": func(src civSource, v string) error {
+		hz, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setPBTIn(src, hz)
+	},
+	"pbtOut": func(src civSource, v string) error {
+		hz, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setPBTOut(src, hz)
+	},
+	"att": func(src civSource, v string) error {
+		db, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		return civControl.setATT(src, db)
+	},
+	"nb":          radioParamPercentSetter(civControl.setNBLevel),
+	"nbEnabled":   radioParamBoolSetter(civControl.setNBEnabled),
+	"anfEnabled":  radioParamBoolSetter(civControl.setANFEnabled),
+	"mnEnabled":   radioParamBoolSetter(civControl.setMNEnabled),
+	"compLevel":   radioParamPercentSetter(civControl.setCompLevel),
+	"compEnabled": radioParamBoolSetter(civControl.setCompEnabled),
+	"monGain":     radioParamPercentSetter(civControl.setMonGain),
+	"monEnabled":  radioParamBoolSetter(civControl.setMonEnabled),
+	"voxGain":     radioParamPercentSetter(civControl.setVOXGain),
+	"voxEnabled":  radioParamBoolSetter(civControl.setVOXEnabled),
+}
+
+func radioParamBoolSetter(setter func(src civSource, enable bool) error) func(src civSource, v string) error {
+	return func(src civSource, v string) error {
+		b, err := parseRadioParamBool(v)
+		if err != nil {
+			return err
+		}
+		return setter(src, b)
+	}
+}
+
+func radioParamPercentSetter(setter func(src civSource, percent int) error) func(src civSource, v string) error {
+	return func(src civSource, v string) error {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		return setter(src, p)
+	}
+}
+
+var errUnknownRadioParam = errors.New("unknown parameter")
+
+// Parameter names are case insensitive, so both freq and subFreq, or toneMode and tonemode can be used.
+func getRadioParamSetter(name string) (setter func(src civSource, v string) error, ok bool) {
+	if setter, ok = radioParamSetters[name]; ok {
+		return
+	}
+	for n, setter := range radioParamSetters {
+		if strings.EqualFold(n, name) {
+			return setter, true
+		}
+	}
+	return nil, false
+}
+
+func setRadioParam(src civSource, name, value string) error {
+	setter, ok := getRadioParamSetter(name)
+	if !ok {
+		return errUnknownRadioParam
+	}
+	return setter(src, value)
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// A point of a meter's calibration table, raw is the meter reading (0-255) sent by the radio.
+type meterCalibrationPoint struct {
+	raw   int
+	value float64
+}
+
+// Converts raw meter readings to real values with linear interpolation between the points.
+type meterCalibration []meterCalibrationPoint
+
+func (c meterCalibration) get(raw int) float64 {
+	if len(c) == 0 {
+		return 0
+	}
+	if raw <= c[0].raw {
+		return c[0].value
+	}
+	for i := 1; i < len(c); i++ {
+		if raw <= c[i].raw {
+			p0, p1 := c[i-1], c[i]
+			return p0.value + (p1.value-p0.value)*float64(raw-p0.raw)/float64(p1.raw-p0.raw)
+		}
+	}
+	return c[len(c)-1].value
+}
+
+// Meter readings are sent as 2 BCD encoded bytes (0000-0255).
+func decodeMeterRaw(d []byte) int {
+	if len(d) < 2 {
+		return 0
+	}
+	return int(d[0]&0x0f)*100 + int(d[1]>>4)*10 + int(d[1]&0x0f)
+}
+
+// A frequency range in the rigctld dump_state reply.
+type radioModelFreqRange struct {
+	freqFrom uint
+	freqTo   uint
+}
+
+// Describes the capabilities of a supported transceiver.
+type radioModel struct {
+	name string
+	// Names which are matched with the device name reported by the radio on connect.
+	deviceNames []string
+	civAddress  byte
+	hamlibModel int
+
+	modes []civOperatingMode
+	bands []civBand
+
+	// Dual receiver radios have main and sub receivers instead of VFO A and B.
+	mainSub  bool
+	vfoNames [2]string
+
+	// The number of selectable antenna connectors, 1 means there is no antenna selection.
+	antennas int
+
+	// RF power at 0% and 100%.
+	minPwrWatts float64
+	maxPwrWatts float64
+
+	// The S meter is calibrated in dB relative to S9, S9 is s9DBm.
+	sMeter meterCalibration
+	s9DBm  float64
+	// The power meter is calibrated in percent of maxPwrWatts.
+	pwrMeter  meterCalibration
+	swrMeter  meterCalibration
+	alcMeter  meterCalibration // 0-1, 1 is the top of the ALC zone.
+	compMeter meterCalibration // dB
+	vdMeter   meterCalibration
+	idMeter   meterCalibration // A
+
+	// Data for the rigctld dump_state reply.
+	rxRanges    []radioModelFreqRange
+	txRanges    []radioModelFreqRange
+	rxModes     uint64 // Hamlib mode bits.
+	txModes     uint64
+	vfos        uint64 // Hamlib VFO bits.
+	preamps     []int
+	attenuators []int
+}
+
+var civModesIC705 = []civOperatingMode{
+	{name: "LSB", code: 0x00},
+	{name: "USB", code: 0x01},
+	{name: "AM", code: 0x02},
+	{name: "CW", code: 0x03},
+	{name: "RTTY", code: 0x04},
+	{name: "FM", code: 0x05},
+	{name: "WFM", code: 0x06},
+	{name: "CW-R", code: 0x07},
+	{name: "RTTY-R", code: 0x08},
+	{name: "DV", code: 0x17},
+}
+
+var civModesIC9700 = []civOperatingMode{
+	{name: "LSB", code: 0x00},
+	{name: "USB", code: 0x01},
+	{name: "AM", code: 0x02},
+	{name: "CW", code: 0x03},
+	{name: "RTTY", code: 0x04},
+	{name: "FM", code: 0x05},
+	{name: "CW-R", code: 0x07},
+	{name: "RTTY-R", code: 0x08},
+	{name: "DV", code: 0x17},
+	{name: "DD", code: 0x22},
+}
+
+var civModesHF = []civOperatingMode{
+	{name: "LSB", code: 0x00},
+	{name: "USB", code: 0x01},
+	{name: "AM", code: 0x02},
+	{name: "CW", code: 0x03},
+	{name: "RTTY", code: 0x04},
+	{name: "FM", code: 0x05},
+	{name: "CW-R", code: 0x07},
+	{name: "RTTY-R", code: 0x08},
+	{name: "PSK", code: 0x12},
+	{name: "PSK-R", code: 0x13},
+}
+
+// The last band is always GENE, which is used for frequencies outside the other bands.
+func newCIVBandsHF() []civBand {
+	return []civBand{
+		{freqFrom: 1800000, freqTo: 1999999},   // 1.9
+		{freqFrom: 3400000, freqTo: 4099999},   // 3.5
+		{freqFrom: 6900000, freqTo: 7499999},   // 7
+		{freqFrom: 9900000, freqTo: 10499999},  // 10
+		{freqFrom: 13900000, freqTo: 14499999}, // 14
+		{freqFrom: 17900000, freqTo: 18499999}, // 18
+		{freqFrom: 20900000, freqTo: 21499999}, // 21
+		{freqFrom: 24400000, freqTo: 25099999}, // 24
+		{freqFrom: 28000000, freqTo: 29999999}, // 28
+		{freqFrom: 50000000, freqTo: 54000000}, // 50
+		{freqFrom: 0, freqTo: 0},               // GENE
+	}
+}
+
+var sMeterCalibrationIcom = meterCalibration{{0, -54}, {120, 0}, {241, 60}}
+var swrMeterCalibrationIcom = meterCalibration{{0, 1}, {48, 1.5}, {80, 2}, {120, 3}, {240, 6}}
+var pwrMeterCalibrationIcom = meterCalibration{{0, 0}, {143, 50}, {213, 100}}
+var alcMeterCalibrationIcom = meterCalibration{{0, 0}, {120, 1}}
+var compMeterCalibrationIcom = meterCalibration{{0, 0}, {130, 15}, {241, 30}}
+var idMeterCalibrationIC705 = meterCalibration{{0, 0}, {97, 1}, {146, 2}, {241, 4}}
+var idMeterCalibrationIC9700 = meterCalibration{{0, 0}, {121, 10}, {241, 20}}
+var idMeterCalibrationBase = meterCalibration{{0, 0}, {97, 10}, {146, 15}, {241, 25}}
+var vdMeterCalibrationPortable = meterCalibration{{0, 0}, {13, 10}, {241, 16}}
+var vdMeterCalibrationBase = meterCalibration{{0, 0}, {151, 44}, {180, 48}, {211, 52}}
+
+var dumpStateRangesHF = []radioModelFreqRange{
+	{1800000, 1999999},
+	{3500000, 3999999},
+	{5255000, 5405000},
+	{7000000, 7300000},
+	{10100000, 10150000},
+	{14000000, 14350000},
+	{18068000, 18168000},
+	{21000000, 21450000},
+	{24890000, 24990000},
+	{28000000, 29700000},
+	{50000000, 54000000},
+}
+
+var radioModels = []radioModel{
+	{
+		name:        "IC-705",
+		deviceNames: []string{"IC-705"},
+		civAddress:  0xa4,
+		hamlibModel: 3085,
+		modes:       civModesIC705,
+		bands: append(newCIVBandsHF()[:10],
+			civBand{freqFrom: 74800000, freqTo: 107999999},  // WFM
+			civBand{freqFrom: 108000000, freqTo: 136999999}, // AIR
+			civBand{freqFrom: 144000000, freqTo: 148000000}, // 144
+			civBand{freqFrom: 420000000, freqTo: 450000000}, // 430
+			civBand{freqFrom: 0, freqTo: 0},                 // GENE
+		),
+		vfoNames:    [2]string{"A", "B"},
+		antennas:    1,
+		minPwrWatts: 0.1,
+		maxPwrWatts: 10,
+		sMeter:      sMeterCalibrationIcom,
+		s9DBm:       -73,
+		pwrMeter:    pwrMeterCalibrationIcom,
+		alcMeter:    alcMeterCalibrationIcom,
+		compMeter:   compMeterCalibrationIcom,
+		idMeter:     idMeterCalibrationIC705,
+		swrMeter:    swrMeterCalibrationIcom,
+		vdMeter:     vdMeterCalibrationPortable,
+		rxRanges:    []radioModelFreqRange{{30000, 199999999}, {400000000, 470000000}},
+		txRanges: append(append([]radioModelFreqRange{}, dumpStateRangesHF...),
+			radioModelFreqRange{144000000, 148000000}, radioModelFreqRange{430000000, 450000000}),
+		rxModes:     0x1401dbf,
+		txModes:     0x10001bf,
+		vfos:        0x10000003,
+		preamps:     []int{1, 2},
+		attenuators: []int{20},
+	},
+	{
+		name:        "IC-9700",
+		deviceNames: []string{"IC-9700"},
+		civAddress:  0xa2,
+		hamlibModel: 3081,
+		modes:       civModesIC9700,
+		bands: []civBand{
+			{freqFrom: 144000000, freqTo: 148000000},   // 144
+			{freqFrom: 430000000, freqTo: 450000000},   // 430
+			{freqFrom: 1240000000, freqTo: 1300000000}, // 1200
+			{freqFrom: 0, freqTo: 0},                   // GENE
+		},
+		mainSub:     true,
+		vfoNames:    [2]string{"MAIN", "SUB"},
+		antennas:    1,
+		minPwrWatts: 0.5,
+		maxPwrWatts: 100,
+		sMeter:      sMeterCalibrationIcom,
+		s9DBm:       -93,
+		pwrMeter:    pwrMeterCalibrationIcom,
+		alcMeter:    alcMeterCalibrationIcom,
+		compMeter:   compMeterCalibrationIcom,
+		idMeter:     idMeterCalibrationIC9700,
+		swrMeter:    swrMeterCalibrationIcom,
+		vdMeter:     vdMeterCalibrationPortable,
+		rxRanges:    []radioModelFreqRange{{144000000, 148000000}, {430000000, 450000000}, {1240000000, 1300000000}},
+		txRanges:    []radioModelFreqRange{{144000000, 148000000}, {430000000, 450000000}, {1240000000, 1300000000}},
+		rxModes:     0x101401dbf,
+		txModes:     0x1010001bf,
+		vfos:        0x16000003,
+		preamps:     []int{1},
+		attenuators: []int{10},
+	},
+	{
+		name:        "IC-7610",
+		deviceNames: []string{"IC-7610"},
+		civAddress:  0x98,
+		hamlibModel: 3078,
+		modes:       civModesHF,
+		bands:       newCIVBandsHF(),
+		mainSub:     true,
+		vfoNames:    [2]string{"MAIN", "SUB"},
+		antennas:    2,
+		minPwrWatts: 2,
+		maxPwrWatts: 100,
+		sMeter:      sMeterCalibrationIcom,
+		s9DBm:       -73,
+		pwrMeter:    pwrMeterCalibrationIcom,
+		alcMeter:    alcMeterCalibrationIcom,
+		compMeter:   compMeterCalibrationIcom,
+		idMeter:     idMeterCalibrationBase,
+		swrMeter:    swrMeterCalibrationIcom,
+		vdMeter:     vdMeterCalibrationBase,
+		rxRanges:    []radioModelFreqRange{{30000, 60000000}},
+		txRanges:    dumpStateRangesHF,
+		rxModes:     0xc0401dbf,
+		txModes:     0xc00001bf,
+		vfos:        0x16000003,
+		preamps:     []int{1, 2},
+		attenuators: []int{6, 12, 18},
+	},
+	{
+		name:        "IC-785x",
+		deviceNames: []string{"IC-7850", "IC-7851"},
+		civAddress:  0x8e,
+		hamlibModel: 3075,
+		modes:       civModesHF,
+		bands:       newCIVBandsHF(),
+		mainSub:     true,
+		vfoNames:    [2]string{"MAIN", "SUB"},
+		antennas:    4,
+		minPwrWatts: 5,
+		maxPwrWatts: 200,
+		sMeter:      sMeterCalibrationIcom,
+		s9DBm:       -73,
+		pwrMeter:    pwrMeterCalibrationIcom,
+		alcMeter:    alcMeterCalibrationIcom,
+		compMeter:   compMeterCalibrationIcom,
+		idMeter:     idMeterCalibrationBase,
+		swrMeter:    swrMeterCalibrationIcom,
+		vdMeter:     vdMeterCalibrationBase,
+		rxRanges:    []radioModelFreqRange{{30000, 60000000}},
+		txRanges:    dumpStateRangesHF,
+		rxModes:     0xc0401dbf,
+		txModes:     0xc00001bf,
+		vfos:        0x16000003,
+		preamps:     []int{1, 2},
+		attenuators: []int{3, 6, 9, 12, 15, 18, 21},
+	},
+}
+
+// The model of the connected radio, selected by the CI-V address on startup, and by the device name
+// reported by the radio on connect.
+var activeRadioModel = &radioModels[0]
+
+func getRadioModelByCIVAddress(addr byte) *radioModel {
+	for i := range radioModels {
+		if radioModels[i].civAddress == addr {
+			return &radioModels[i]
+		}
+	}
+	return nil
+}
+
+func getRadioModelByDeviceName(devName string) *radioModel {
+	devName = strings.ToUpper(devName)
+	for i := range radioModels {
+		for _, n := range radioModels[i].deviceNames {
+			if strings.HasPrefix(devName, n) {
+				return &radioModels[i]
+			}
+		}
+	}
+	return nil
+}
+
+func selectRadioModel(addr byte) {
+	m := getRadioModelByCIVAddress(addr)
+	if m == nil {
+		log.Printw("unknown CI-V address, using defaults", "civAddress", fmt.Sprintf("0x%02x", addr),
+			"model", radioModels[0].name)
+		m = &radioModels[0]
+	}
+	activeRadioModel = m
+	log.Print("radio model: ", m.name)
+}
+
+// Called on connect with the device name reported by the radio. The model is selected by the device name,
+// if it's unknown, then the model selected by the CI-V address is kept. The CI-V address is also changed
+// to the model's default address, if it was not set explicitly with --civ-address.
+func selectRadioModelByDeviceName(devName string) {
+	m := getRadioModelByDeviceName(devName)
+	if m == nil {
+		log.Print("unknown device name ", devName, ", keeping radio model ", activeRadioModel.name)
+		return
+	}
+	if m != activeRadioModel {
+		activeRadioModel = m
+		log.Print("radio model: ", m.name)
+	}
+	if civAddress == m.civAddress {
+		return
+	}
+	if civAddressSet {
+		log.Print("using CI-V address ", fmt.Sprintf("0x%02x", civAddress), " instead of the ", m.name,
+			" default ", fmt.Sprintf("0x%02x", m.civAddress))
+		return
+	}
+	civAddress = m.civAddress
+	log.Print("CI-V address: ", fmt.Sprintf("0x%02x", civAddress))
+}
+
+func (m *radioModel) getPwrWatts(percent int) float64 {
+	return m.minPwrWatts + (m.maxPwrWatts-m.minPwrWatts)*float64(percent)/100
+}
+
+func (m *radioModel) getVFOName(vfoB bool) string {
+	if vfoB {
+		return m.vfoNames[1]
+	}
+	return m.vfoNames[0]
+}
+
+// Returns the VFO number (0 or 1) for VFO names used by the API and hamlib.
+func (m *radioModel) parseVFOName(name string) (nr byte, ok bool) {
+	switch strings.ToUpper(name) {
+	case "A", "VFOA", "MAIN":
+		return 0, true
+	case "B", "VFOB", "SUB":
+		return 1, true
+	}
+	return 0, false
+}
+
+// Returns the CI-V data byte of the select VFO command.
+func (m *radioModel) getVFOSelectCode(nr byte) byte {
+	if m.mainSub {
+		return 0xd0 + nr
+	}
+	return nr
+}
+
+func formatWatts(w float64) string {
+	if w < 10 {
+		return fmt.Sprintf("%.1fW", w)
+	}
+	return fmt.Sprintf("%.0fW", w)
+}
+
+// Returns the S meter value as displayed on the radio (S0-S9, S9+10-S9+60).
+func getSMeterStr(dbOverS9 float64) string {
+	if dbOverS9 <= 0 {
+		v := int(math.Round(9 + dbOverS9/6))
+		if v < 0 {
+			v = 0
+		}
+		return fmt.Sprint("S", v)
+	}
+	v := int(math.Round(dbOverS9/10)) * 10
+	if v == 0 {
+		return "S9"
+	}
+	if v > 60 {
+		v = 60
+	}
+	return fmt.Sprint("S9+", v)
+}
+
+func formatDumpStateInts(l []int) string {
+	var res []string
+	for _, v := range l {
+		res = append(res, fmt.Sprint(v))
+	}
+	if len(res) == 0 {
+		return "0"
+	}
+	return strings.Join(res, " ")
+}
+
+// Returns the rigctld dump_state reply.
+func (m *radioModel) getDumpState() string {
+	var b strings.Builder
+	fmt.Fprint(&b, "1\n", m.hamlibModel, "\n", "0\n")
+	for _, r := range m.rxRanges {
+		fmt.Fprintf(&b, "%d.000000 %d.000000 0x%x -1 -1 0x%x 0x1\n", r.freqFrom, r.freqTo, m.rxModes, m.vfos)
+	}
+	b.WriteString("0 0 0 0 0 0 0\n")
+	for _, r := range m.txRanges {
+		fmt.Fprintf(&b, "%d.000000 %d.000000 0x%x %d %d 0x%x 0x1\n", r.freqFrom, r.freqTo, m.txModes,
+			int(m.minPwrWatts*1000), int(m.maxPwrWatts*1000), m.vfos)
+	}
+	b.WriteString("0 0 0 0 0 0 0\n")
+	for _, ts := range civTuningSteps[1:] {
+		fmt.Fprintf(&b, "0x%x %d\n", m.rxModes, ts)
+	}
+	b.WriteString("0 0\n" +
+		"0xc0c 3600\n" +
+		"0xc0c 2400\n" +
+		"0xc0c 1800\n" +
+		"0x192 500\n" +
+		"0x192 250\n" +
+		"0x82 1200\n" +
+		"0x110 2400\n" +
+		"0x400001 6000\n" +
+		"0x400001 3000\n" +
+		"0x400001 9000\n" +
+		"0x1020 10000\n" +
+		"0x1020 7000\n" +
+		"0x1020 15000\n" +
+		"0 0\n" +
+		"9999\n" +
+		"9999\n" +
+		"0\n" +
+		"0\n")
+	fmt.Fprint(&b, formatDumpStateInts(m.preamps), "\n", formatDumpStateInts(m.attenuators), "\n")
+	b.WriteString("0xc90133fe\n" +
+		"0xc90133fe\n" +
+		"0x7f74677f3f\n" +
+		"0x7000677f3f\n" +
+		"0x35\n" +
+		"0x35\n" +
+		"vfo_ops=0x81f\n" +
+		"ptt_type=0x1\n" +
+		"targetable_vfo=0x0\n" +
+		"done\n")
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestSelectRadioModelByDeviceName(t *testing.T) {
+	log.Init()
+	prevCIVAddress, prevCIVAddressSet, prevActiveRadioModel := civAddress, civAddressSet, activeRadioModel
+	t.Cleanup(func() {
+		civAddress, civAddressSet, activeRadioModel = prevCIVAddress, prevCIVAddressSet, prevActiveRadioModel
+	})
+
+	tests := []struct {
+		devName       string
+		civAddressSet bool
+		wantModel     string
+		wantAddress   byte
+	}{
+		{"IC-9700", false, "IC-9700", 0xa2},
+		{"IC-7851", false, "IC-785x", 0x8e},
+		{"IC-9700", true, "IC-9700", 0xa4},
+		{"IC-705", false, "IC-705", 0xa4},
+		{"unknown", false, "IC-705", 0xa4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.devName, func(t *testing.T) {
+			civAddress = 0xa4
+			civAddressSet = tt.civAddressSet
+			activeRadioModel = getRadioModelByCIVAddress(civAddress)
+
+			selectRadioModelByDeviceName(tt.devName)
+			if activeRadioModel.name != tt.wantModel {
+				t.Errorf("model = %s, want %s", activeRadioModel.name, tt.wantModel)
+			}
+			if civAddress != tt.wantAddress {
+				t.Errorf("CI-V address = 0x%02x, want 0x%02x", civAddress, tt.wantAddress)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type connErrorKind int
+
+const (
+	connErrorOther connErrorKind = iota
+	connErrorInvalidCredentials
+	connErrorAuthFailed
+	connErrorRadioDisconnected
+	connErrorExpectTimeout
+	connErrorPingTimeout
+	connErrorAudioTimeout
+	connErrorRequestTimeout
+	connErrorRadioBusy
+)
+
+var connErrorKindNames = []string{"other", "invalidCredentials", "authFailed", "radioDisconnected",
+	"expectTimeout", "pingTimeout", "audioTimeout", "requestTimeout", "radioBusy"}
+
+func (k connErrorKind) String() string {
+	if int(k) < len(connErrorKindNames) {
+		return connErrorKindNames[k]
+	}
+	return "unknown"
+}
+
+// Errors which cause a reconnect are returned with their kind, so the reconnect policy doesn't have
+// to match the error text.
+type connError struct {
+	kind connErrorKind
+	msg  string
+}
+
+func (e *connError) Error() string {
+	return e.msg
+}
+
+func newConnError(kind connErrorKind, msg string) error {
+	return &connError{kind: kind, msg: msg}
+}
+
+func getConnErrorKind(err error) connErrorKind {
+	var ce *connError
+	if errors.As(err, &ce) {
+		return ce.kind
+	}
+	return connErrorOther
+}
+
+type reconnectStruct struct {
+	mutex sync.Mutex
+
+	// Consecutive failed attempts since the last successful connection.
+	attempt int
+	reason  connErrorKind
+	lastErr string
+}
+
+var reconnect reconnectStruct
+
+// Returns the delay before the next reconnect attempt, and false if we should give up.
+func (s *reconnectStruct) getDelay(err error) (time.Duration, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.attempt++
+	s.reason = getConnErrorKind(err)
+	s.lastErr = ""
+	if err != nil {
+		s.lastErr = err.Error()
+	}
+
+	if reconnectGiveUpAfter > 0 && s.attempt > int(reconnectGiveUpAfter) {
+		return 0, false
+	}
+
+	// The radio closed the session itself, so we can reconnect without backing off.
+	if s.reason == connErrorRadioDisconnected {
+		return reconnectInitialDelay, true
+	}
+
+	d := reconnectInitialDelay
+	for i := 1; i < s.attempt && d < reconnectMaxDelay; i++ {
+		d *= 2
+	}
+	if d > reconnectMaxDelay {
+		d = reconnectMaxDelay
+	}
+	if reconnectJitterPercent > 0 {
+		jitter := time.Duration(float64(d) * float64(reconnectJitterPercent) / 100 * (rand.Float64()*2 - 1))
+		d += jitter
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+func (s *reconnectStruct) publish(reconnecting bool, delay time.Duration) {
+	s.mutex.Lock()
+	attempt := s.attempt
+	reason := s.reason.String()
+	lastErr := s.lastErr
+	s.mutex.Unlock()
+
+	eventBus.publishState(eventBusState{
+		"reconnecting":     reconnecting,
+		"reconnectAttempt": attempt,
+		"reconnectReason":  reason,
+		"lastError":        lastErr,
+	})
+	if reconnecting {
+		eventBus.publishEvent("reconnect", map[string]interface{}{
+			"attempt": attempt,
+			"reason":  reason,
+			"error":   lastErr,
+			"delayMs": delay.Milliseconds(),
+		})
+	}
+}
+
+// Called when the serial and audio streams are opened.
+func (s *reconnectStruct) reportConnected() {
+	s.mutex.Lock()
+	s.attempt = 0
+	s.mutex.Unlock()
+
+	s.publish(false, 0)
+}
+
+func (s *reconnectStruct) get() (reconnecting bool, attempt int, reason, lastErr string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.attempt > 0, s.attempt, s.reason.String(), s.lastErr
+}
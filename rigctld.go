@@ -2,8 +2,10 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"strconv"
 	"strings"
@@ -12,6 +14,9 @@ import (
 const (
 	rigctldNoError        = iota
 	rigctldInvalidParam   = -1
+	rigctldTimeout        = -5
+	rigctldIOError        = -6
+	rigctldRejected       = -9
 	rigctldUnsupportedCmd = -11
 )
 
@@ -56,6 +61,170 @@ func (s *rigctldStruct) sendReplyCode(code int) error {
 	return err
 }
 
+// Sends the hamlib error code matching the given error.
+func (s *rigctldStruct) sendErrorReplyCode(err error) error {
+	code := rigctldInvalidParam
+	switch {
+	case errors.Is(err, errCIVNG), errors.Is(err, errTxBlocked), errors.Is(err, errPTTHeld),
+		errors.Is(err, errListenOnly):
+		code = rigctldRejected
+	case errors.Is(err, errCIVTimeout):
+		code = rigctldTimeout
+	case errors.Is(err, errCIVQueueClosed):
+		code = rigctldIOError
+	}
+	return s.sendReplyCode(code)
+}
+
+// Returns the value of the given hamlib level.
+func (s *rigctldStruct) getLevel(name string) (string, error) {
+	civControl.state.mutex.Lock()
+	defer civControl.state.mutex.Unlock()
+
+	switch name {
+	case "STRENGTH":
+		return fmt.Sprint(int(math.Round(civControl.state.sDB))), nil
+	case "RFPOWER":
+		return fmt.Sprintf("%f", float64(civControl.state.pwrPercent)/100), nil
+	case "RFPOWER_METER":
+		return fmt.Sprintf("%f", civControl.state.poWatts/activeRadioModel.maxPwrWatts), nil
+	case "RFPOWER_METER_WATTS":
+		return fmt.Sprintf("%f", civControl.state.poWatts), nil
+	case "SWR":
+		return fmt.Sprintf("%f", civControl.state.swr), nil
+	case "ALC":
+		return fmt.Sprintf("%f", civControl.state.alc), nil
+	case "COMP_METER":
+		return fmt.Sprintf("%f", civControl.state.comp), nil
+	case "ID_METER":
+		return fmt.Sprintf("%f", civControl.state.id), nil
+	case "VD_METER":
+		return fmt.Sprintf("%f", civControl.state.vd), nil
+	case "PBT_IN":
+		return fmt.Sprintf("%f", float64(civControl.state.pbtIn)), nil
+	case "PBT_OUT":
+		return fmt.Sprintf("%f", float64(civControl.state.pbtOut)), nil
+	case "ATT":
+		return fmt.Sprint(civControl.state.att), nil
+	case "NB":
+		return fmt.Sprintf("%f", float64(civControl.state.nbPercent)/100), nil
+	case "COMP":
+		return fmt.Sprintf("%f", float64(civControl.state.compPercent)/100), nil
+	case "MONITOR_GAIN":
+		return fmt.Sprintf("%f", float64(civControl.state.monPercent)/100), nil
+	case "VOXGAIN":
+		return fmt.Sprintf("%f", float64(civControl.state.voxPercent)/100), nil
+	}
+	return "", fmt.Errorf("unsupported level %s", name)
+}
+
+func (s *rigctldStruct) setLevel(name, value string) error {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case "PBT_IN":
+		return civControl.setPBTIn(civSourceRigctld, int(math.Round(v)))
+	case "PBT_OUT":
+		return civControl.setPBTOut(civSourceRigctld, int(math.Round(v)))
+	case "ATT":
+		return civControl.setATT(civSourceRigctld, int(math.Round(v)))
+	case "NB":
+		return civControl.setNBLevel(civSourceRigctld, int(math.Round(v*100)))
+	case "COMP":
+		return civControl.setCompLevel(civSourceRigctld, int(math.Round(v*100)))
+	case "MONITOR_GAIN":
+		return civControl.setMonGain(civSourceRigctld, int(math.Round(v*100)))
+	case "VOXGAIN":
+		return civControl.setVOXGain(civSourceRigctld, int(math.Round(v*100)))
+	}
+	return fmt.Errorf("unsupported level %s", name)
+}
+
+// Returns the state of the given hamlib function.
+func (s *rigctldStruct) getFunc(name string) (bool, error) {
+	civControl.state.mutex.Lock()
+	defer civControl.state.mutex.Unlock()
+
+	switch name {
+	case "TUNER":
+		return civControl.state.atuEnabled, nil
+	case "TONE":
+		return civControl.state.toneMode == civToneModeTone, nil
+	case "TSQL":
+		return civControl.state.toneMode == civToneModeTSQL, nil
+	case "RIT":
+		return civControl.state.ritEnabled, nil
+	case "XIT":
+		return civControl.state.xitEnabled, nil
+	case "NB":
+		return civControl.state.nbEnabled, nil
+	case "ANF":
+		return civControl.state.anfEnabled, nil
+	case "MN":
+		return civControl.state.mnEnabled, nil
+	case "COMP":
+		return civControl.state.compEnabled, nil
+	case "MON":
+		return civControl.state.monEnabled, nil
+	case "VOX":
+		return civControl.state.voxEnabled, nil
+	}
+	return false, fmt.Errorf("unsupported func %s", name)
+}
+
+func (s *rigctldStruct) setFunc(name string, enable bool) error {
+	switch name {
+	case "TUNER":
+		return civControl.setATU(civSourceRigctld, enable)
+	case "TONE", "TSQL":
+		mode := civToneModeOff
+		if enable && name == "TONE" {
+			mode = civToneModeTone
+		} else if enable {
+			mode = civToneModeTSQL
+		}
+		return civControl.setToneMode(civSourceRigctld, mode)
+	case "RIT":
+		return civControl.setRITEnabled(civSourceRigctld, enable)
+	case "XIT":
+		return civControl.setXITEnabled(civSourceRigctld, enable)
+	case "NB":
+		return civControl.setNBEnabled(civSourceRigctld, enable)
+	case "ANF":
+		return civControl.setANFEnabled(civSourceRigctld, enable)
+	case "MN":
+		return civControl.setMNEnabled(civSourceRigctld, enable)
+	case "COMP":
+		return civControl.setCompEnabled(civSourceRigctld, enable)
+	case "MON":
+		return civControl.setMonEnabled(civSourceRigctld, enable)
+	case "VOX":
+		return civControl.setVOXEnabled(civSourceRigctld, enable)
+	}
+	return fmt.Errorf("unsupported func %s", name)
+}
+
+// Parses the integer argument of a set command, calls the setter and sends the reply code.
+func (s *rigctldStruct) setInt(cmdSplit []string, setter func(v int) error) error {
+	if len(cmdSplit) < 2 {
+		err := errors.New("missing value")
+		_ = s.sendErrorReplyCode(err)
+		return err
+	}
+	v, err := strconv.Atoi(cmdSplit[1])
+	if err == nil {
+		err = setter(v)
+	}
+	if err != nil {
+		_ = s.sendErrorReplyCode(err)
+		return err
+	}
+	return s.sendReplyCode(rigctldNoError)
+}
+
 func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 	cmdSplit := strings.Fields(cmd)
 
@@ -63,70 +232,7 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 	case cmd == "\\chk_vfo":
 		err = s.send("0\n")
 	case cmd == "\\dump_state":
-		err = s.send("1\n" +
-			"3085\n" +
-			"0\n" +
-			"30000.000000 199999999.000000 0x1401dbf -1 -1 0x10000003 0x1\n" +
-			"400000000.000000 470000000.000000 0x1401dbf -1 -1 0x10000003 0x1\n" +
-			"0 0 0 0 0 0 0\n" +
-			"1800000.000000 1999999.000000 0x10001bf 100 10000 0x10000003 0x1\n" +
-			"3500000.000000 3999999.000000 0x10001bf 100 10000 0x10000003 0x1\n" +
-			"5255000.000000 5405000.000000 0x10001bf 100 10000 0x10000003 0x1\n" +
-			"7000000.000000 7300000.000000 0x10001bf 100 10000 0x10000003 0x1\n" +
-			"10100000.000000 10150000.000000 0x10001bf 100 10000 0x10000003 0x1\n" +
-			"14000000.000000 14350000.000000 0x10001bf 100 10000 0x10000003 0x1\n" +
-			"18068000.000000 18168000.000000 0x10001bf 100 10000 0x10000003 0x1\n" +
-			"21000000.000000 21450000.000000 0x10001bf 100 10000 0x10000003 0x1\n" +
-			"24890000.000000 24990000.000000 0x10001bf 100 10000 0x10000003 0x1\n" +
-			"28000000.000000 29700000.000000 0x10001bf 100 10000 0x10000003 0x1\n" +
-			"50000000.000000 54000000.000000 0x10001bf 100 10000 0x10000003 0x1\n" +
-			"144000000.000000 148000000.000000 0x10001bf 100 10000 0x10000003 0x1\n" +
-			"430000000.000000 450000000.000000 0x10001bf 100 10000 0x10000003 0x1\n" +
-			"0 0 0 0 0 0 0\n" +
-			"0x401dbf 100\n" +
-			"0x401dbf 500\n" +
-			"0x401dbf 1000\n" +
-			"0x401dbf 5000\n" +
-			"0x401dbf 6250\n" +
-			"0x401dbf 8330\n" +
-			"0x401dbf 9000\n" +
-			"0x401dbf 10000\n" +
-			"0x401dbf 12500\n" +
-			"0x401dbf 20000\n" +
-			"0x401dbf 25000\n" +
-			"0x401dbf 50000\n" +
-			"0x401dbf 100000\n" +
-			"0 0\n" +
-			"0xc0c 3600\n" +
-			"0xc0c 2400\n" +
-			"0xc0c 1800\n" +
-			"0x192 500\n" +
-			"0x192 250\n" +
-			"0x82 1200\n" +
-			"0x110 2400\n" +
-			"0x400001 6000\n" +
-			"0x400001 3000\n" +
-			"0x400001 9000\n" +
-			"0x1020 10000\n" +
-			"0x1020 7000\n" +
-			"0x1020 15000\n" +
-			"0 0\n" +
-			"9999\n" +
-			"9999\n" +
-			"0\n" +
-			"0\n" +
-			"1 2\n" +
-			"20\n" +
-			"0xc90133fe\n" +
-			"0xc90133fe\n" +
-			"0x7f74677f3f\n" +
-			"0x7000677f3f\n" +
-			"0x35\n" +
-			"0x35\n" +
-			"vfo_ops=0x81f\n" +
-			"ptt_type=0x1\n" +
-			"targetable_vfo=0x0\n" +
-			"done\n")
+		err = s.send(activeRadioModel.getDumpState())
 	case cmd == "q":
 		err = s.sendReplyCode(rigctldNoError)
 		close = true
@@ -139,12 +245,12 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		var f float64
 		f, err = strconv.ParseFloat(cmdSplit[1], 0)
 		if err != nil {
-			_ = s.sendReplyCode(rigctldInvalidParam)
+			_ = s.sendErrorReplyCode(err)
 			return
 		}
-		err = civControl.setMainVFOFreq(uint(f))
+		err = civControl.setMainVFOFreq(civSourceRigctld, uint(f))
 		if err != nil {
-			_ = s.sendReplyCode(rigctldInvalidParam)
+			_ = s.sendErrorReplyCode(err)
 			return
 		}
 		err = s.sendReplyCode(rigctldNoError)
@@ -156,7 +262,7 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		if civControl.state.dataMode {
 			mode = "PKT"
 		}
-		mode += civOperatingModes[civControl.state.operatingModeIdx].name
+		mode += activeRadioModel.modes[civControl.state.operatingModeIdx].name
 
 		// This can be queried with a CIV command for accurate values by the way.
 		width := "3000"
@@ -176,7 +282,7 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		}
 		var modeCode byte
 		var modeFound bool
-		for _, m := range civOperatingModes {
+		for _, m := range activeRadioModel.modes {
 			if m.name == mode {
 				modeCode = m.code
 				modeFound = true
@@ -185,13 +291,13 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		}
 		if !modeFound {
 			err = fmt.Errorf("unknown mode %s", mode)
-			_ = s.sendReplyCode(rigctldInvalidParam)
+			_ = s.sendErrorReplyCode(err)
 			return
 		}
 		var width int
 		width, err = strconv.Atoi(cmdSplit[2])
 		if err != nil {
-			_ = s.sendReplyCode(rigctldInvalidParam)
+			_ = s.sendErrorReplyCode(err)
 			return
 		}
 		var filterCode byte
@@ -200,13 +306,13 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		} else if width <= 2400 {
 			filterCode = 1
 		}
-		err = civControl.setOperatingModeAndFilter(modeCode, filterCode)
+		err = civControl.setOperatingModeAndFilter(civSourceRigctld, modeCode, filterCode)
 		if err != nil {
-			_ = s.sendReplyCode(rigctldInvalidParam)
+			_ = s.sendErrorReplyCode(err)
 		} else {
-			err = civControl.setDataMode(dataMode)
+			err = civControl.setDataMode(civSourceRigctld, dataMode)
 			if err != nil {
-				_ = s.sendReplyCode(rigctldInvalidParam)
+				_ = s.sendErrorReplyCode(err)
 				return
 			}
 			_ = s.sendReplyCode(rigctldNoError)
@@ -223,28 +329,25 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 	case cmdSplit[0] == "T", cmdSplit[0] == "\\set_ptt":
 		if cmdSplit[1] != "0" {
 			if setDataModeOnTx {
-				if err := civControl.setDataMode(true); err != nil {
+				if err := civControl.setDataMode(civSourceRigctld, true); err != nil {
 					log.Error("can't enable data mode: ", err)
 				}
 			}
 
-			err = civControl.setPTT(true)
+			err = pttArbiter.requestPTT(civSourceRigctld, true)
 		} else {
-			err = civControl.setPTT(false)
+			err = pttArbiter.requestPTT(civSourceRigctld, false)
 		}
 		if err != nil {
-			_ = s.sendReplyCode(rigctldInvalidParam)
+			_ = s.sendErrorReplyCode(err)
 		} else {
 			_ = s.sendReplyCode(rigctldNoError)
 		}
 	case cmdSplit[0] == "V", cmdSplit[0] == "\\set_vfo":
-		if cmdSplit[1] == "VFOB" {
-			err = civControl.setVFO(1)
-		} else {
-			err = civControl.setVFO(0)
-		}
+		nr, _ := activeRadioModel.parseVFOName(cmdSplit[1])
+		err = civControl.setVFO(civSourceRigctld, nr)
 		if err != nil {
-			_ = s.sendReplyCode(rigctldInvalidParam)
+			_ = s.sendErrorReplyCode(err)
 		} else {
 			_ = s.sendReplyCode(rigctldNoError)
 		}
@@ -258,23 +361,29 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		}
 		err = s.send(res, "\n")
 		if err != nil {
-			_ = s.sendReplyCode(rigctldInvalidParam)
+			_ = s.sendErrorReplyCode(err)
 			return
 		}
+		// The TX VFO is the other one.
+		res = "VFOB"
 		if civControl.state.vfoBActive {
 			res = "VFOA"
-		} else {
-			res = "VFOB"
+		}
+		if activeRadioModel.mainSub {
+			res = "Sub"
+			if civControl.state.vfoBActive {
+				res = "Main"
+			}
 		}
 		err = s.send(res, "\n")
 	case cmdSplit[0] == "S", cmdSplit[0] == "\\set_split_vfo":
 		if cmdSplit[1] == "1" {
-			err = civControl.setSplit(splitModeOn)
+			err = civControl.setSplit(civSourceRigctld, splitModeOn)
 		} else {
-			err = civControl.setSplit(splitModeOff)
+			err = civControl.setSplit(civSourceRigctld, splitModeOff)
 		}
 		if err != nil {
-			_ = s.sendReplyCode(rigctldInvalidParam)
+			_ = s.sendErrorReplyCode(err)
 		} else {
 			_ = s.sendReplyCode(rigctldNoError)
 		}
@@ -287,12 +396,12 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		var f float64
 		f, err = strconv.ParseFloat(cmdSplit[1], 0)
 		if err != nil {
-			_ = s.sendReplyCode(rigctldInvalidParam)
+			_ = s.sendErrorReplyCode(err)
 			return
 		}
-		err = civControl.setSubVFOFreq(uint(f))
+		err = civControl.setSubVFOFreq(civSourceRigctld, uint(f))
 		if err != nil {
-			_ = s.sendReplyCode(rigctldInvalidParam)
+			_ = s.sendErrorReplyCode(err)
 			return
 		}
 		err = s.sendReplyCode(rigctldNoError)
@@ -304,7 +413,7 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		if civControl.state.subDataMode {
 			mode = "PKT"
 		}
-		mode += civOperatingModes[civControl.state.subOperatingModeIdx].name
+		mode += activeRadioModel.modes[civControl.state.subOperatingModeIdx].name
 
 		// This can be queried with a CIV command for accurate values by the way.
 		width := "3000"
@@ -324,7 +433,7 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		}
 		var modeCode byte
 		var modeFound bool
-		for _, m := range civOperatingModes {
+		for _, m := range activeRadioModel.modes {
 			if m.name == mode {
 				modeCode = m.code
 				modeFound = true
@@ -333,13 +442,13 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		}
 		if !modeFound {
 			err = fmt.Errorf("unknown mode %s", mode)
-			_ = s.sendReplyCode(rigctldInvalidParam)
+			_ = s.sendErrorReplyCode(err)
 			return
 		}
 		var width int
 		width, err = strconv.Atoi(cmdSplit[2])
 		if err != nil {
-			_ = s.sendReplyCode(rigctldInvalidParam)
+			_ = s.sendErrorReplyCode(err)
 			return
 		}
 		var filterCode byte
@@ -348,12 +457,180 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		} else if width <= 2400 {
 			filterCode = 1
 		}
-		err = civControl.setSubVFOMode(modeCode, dataMode, filterCode)
+		err = civControl.setSubVFOMode(civSourceRigctld, modeCode, dataMode, filterCode)
+		if err != nil {
+			_ = s.sendErrorReplyCode(err)
+		} else {
+			_ = s.sendReplyCode(rigctldNoError)
+		}
+	case cmdSplit[0] == "l", cmdSplit[0] == "\\get_level":
+		if len(cmdSplit) < 2 {
+			err = errors.New("missing level name")
+			_ = s.sendErrorReplyCode(err)
+			return
+		}
+		var v string
+		v, err = s.getLevel(cmdSplit[1])
+		if err != nil {
+			_ = s.sendErrorReplyCode(err)
+			return
+		}
+		err = s.send(v, "\n")
+	case cmdSplit[0] == "L", cmdSplit[0] == "\\set_level":
+		if len(cmdSplit) < 3 {
+			err = errors.New("missing level name or value")
+			_ = s.sendErrorReplyCode(err)
+			return
+		}
+		err = s.setLevel(cmdSplit[1], cmdSplit[2])
+		if err != nil {
+			_ = s.sendErrorReplyCode(err)
+		} else {
+			_ = s.sendReplyCode(rigctldNoError)
+		}
+	case cmdSplit[0] == "u", cmdSplit[0] == "\\get_func":
+		if len(cmdSplit) < 2 {
+			err = errors.New("missing func name")
+			_ = s.sendErrorReplyCode(err)
+			return
+		}
+		var v bool
+		v, err = s.getFunc(cmdSplit[1])
+		if err != nil {
+			_ = s.sendErrorReplyCode(err)
+			return
+		}
+		res := "0"
+		if v {
+			res = "1"
+		}
+		err = s.send(res, "\n")
+	case cmdSplit[0] == "U", cmdSplit[0] == "\\set_func":
+		if len(cmdSplit) < 3 {
+			err = errors.New("missing func name or value")
+			_ = s.sendErrorReplyCode(err)
+			return
+		}
+		err = s.setFunc(cmdSplit[1], cmdSplit[2] != "0")
 		if err != nil {
-			_ = s.sendReplyCode(rigctldInvalidParam)
+			_ = s.sendErrorReplyCode(err)
 		} else {
 			_ = s.sendReplyCode(rigctldNoError)
 		}
+	case cmdSplit[0] == "y", cmdSplit[0] == "\\get_ant":
+		civControl.state.mutex.Lock()
+		defer civControl.state.mutex.Unlock()
+
+		ant := fmt.Sprint("ANT", civControl.state.antenna+1)
+		err = s.send(ant, "\n0\n", ant, "\n", ant, "\n")
+	case cmdSplit[0] == "Y", cmdSplit[0] == "\\set_ant":
+		if len(cmdSplit) < 2 {
+			err = errors.New("missing antenna")
+			_ = s.sendErrorReplyCode(err)
+			return
+		}
+		var nr int
+		nr, err = strconv.Atoi(strings.TrimPrefix(strings.ToUpper(cmdSplit[1]), "ANT"))
+		if err != nil {
+			_ = s.sendErrorReplyCode(err)
+			return
+		}
+		err = civControl.setAntenna(civSourceRigctld, nr-1)
+		if err != nil {
+			_ = s.sendErrorReplyCode(err)
+		} else {
+			_ = s.sendReplyCode(rigctldNoError)
+		}
+	case cmd == "c", cmd == "\\get_ctcss_tone":
+		civControl.state.mutex.Lock()
+		defer civControl.state.mutex.Unlock()
+
+		err = s.send(civControl.state.tone, "\n")
+	case cmdSplit[0] == "C", cmdSplit[0] == "\\set_ctcss_tone":
+		err = s.setInt(cmdSplit, func(v int) error {
+			return civControl.setTone(civSourceRigctld, v)
+		})
+	case cmd == "\\get_ctcss_sql":
+		civControl.state.mutex.Lock()
+		defer civControl.state.mutex.Unlock()
+
+		err = s.send(civControl.state.tsqlTone, "\n")
+	case cmdSplit[0] == "\\set_ctcss_sql":
+		err = s.setInt(cmdSplit, func(v int) error {
+			return civControl.setTSQLTone(civSourceRigctld, v)
+		})
+	case cmd == "d", cmd == "\\get_dcs_code":
+		civControl.state.mutex.Lock()
+		defer civControl.state.mutex.Unlock()
+
+		err = s.send(civControl.state.dcsCode, "\n")
+	case cmdSplit[0] == "D", cmdSplit[0] == "\\set_dcs_code":
+		err = s.setInt(cmdSplit, func(v int) error {
+			return civControl.setDCSCode(civSourceRigctld, v)
+		})
+	case cmd == "o", cmd == "\\get_rptr_offs":
+		civControl.state.mutex.Lock()
+		defer civControl.state.mutex.Unlock()
+
+		err = s.send(civControl.state.offset, "\n")
+	case cmdSplit[0] == "O", cmdSplit[0] == "\\set_rptr_offs":
+		err = s.setInt(cmdSplit, func(v int) error {
+			if v < 0 {
+				return fmt.Errorf("invalid repeater offset %d", v)
+			}
+			return civControl.setOffset(civSourceRigctld, uint(v))
+		})
+	case cmd == "r", cmd == "\\get_rptr_shift":
+		civControl.state.mutex.Lock()
+		defer civControl.state.mutex.Unlock()
+
+		res := "None"
+		switch civControl.state.splitMode {
+		case splitModeDUPMinus:
+			res = "-"
+		case splitModeDUPPlus:
+			res = "+"
+		}
+		err = s.send(res, "\n")
+	case cmdSplit[0] == "R", cmdSplit[0] == "\\set_rptr_shift":
+		if len(cmdSplit) < 2 {
+			err = errors.New("missing repeater shift")
+			_ = s.sendErrorReplyCode(err)
+			return
+		}
+		var mode splitMode
+		switch cmdSplit[1] {
+		case "-":
+			mode = splitModeDUPMinus
+		case "+":
+			mode = splitModeDUPPlus
+		}
+		err = civControl.setDuplex(civSourceRigctld, mode)
+		if err != nil {
+			_ = s.sendErrorReplyCode(err)
+		} else {
+			_ = s.sendReplyCode(rigctldNoError)
+		}
+	case cmd == "j", cmd == "\\get_rit", cmd == "z", cmd == "\\get_xit":
+		civControl.state.mutex.Lock()
+		defer civControl.state.mutex.Unlock()
+
+		// RIT and XIT share the same offset.
+		err = s.send(civControl.state.ritOffset, "\n")
+	case cmdSplit[0] == "J", cmdSplit[0] == "\\set_rit":
+		err = s.setInt(cmdSplit, func(v int) error {
+			if err := civControl.setRITOffset(civSourceRigctld, v); err != nil {
+				return err
+			}
+			return civControl.setRITEnabled(civSourceRigctld, v != 0)
+		})
+	case cmdSplit[0] == "Z", cmdSplit[0] == "\\set_xit":
+		err = s.setInt(cmdSplit, func(v int) error {
+			if err := civControl.setRITOffset(civSourceRigctld, v); err != nil {
+				return err
+			}
+			return civControl.setXITEnabled(civSourceRigctld, v != 0)
+		})
 	case cmd == "v": // Ignore this command.
 		_ = s.sendReplyCode(rigctldUnsupportedCmd)
 		return
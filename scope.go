@@ -0,0 +1,185 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const scopeMaxAmplitude = 0xa0
+const scopePanadapterWidth = 64
+
+// The span values are half widths, as the radio expects them in center mode.
+var scopeSpans = []uint{2500, 5000, 10000, 25000, 50000, 100000, 250000, 500000}
+
+// Fixed edge frequency ranges of the IC-705, see CI-V command 0x27 0x1e.
+var scopeFixedEdgeRanges = []struct {
+	code     byte
+	freqFrom uint
+	freqTo   uint
+}{
+	{code: 0x01, freqFrom: 30000, freqTo: 1599999},
+	{code: 0x02, freqFrom: 1600000, freqTo: 1999999},
+	{code: 0x03, freqFrom: 2000000, freqTo: 5999999},
+	{code: 0x04, freqFrom: 6000000, freqTo: 7999999},
+	{code: 0x05, freqFrom: 8000000, freqTo: 10999999},
+	{code: 0x06, freqFrom: 11000000, freqTo: 14999999},
+	{code: 0x07, freqFrom: 15000000, freqTo: 19999999},
+	{code: 0x08, freqFrom: 20000000, freqTo: 21999999},
+	{code: 0x09, freqFrom: 22000000, freqTo: 25999999},
+	{code: 0x10, freqFrom: 26000000, freqTo: 29999999},
+	{code: 0x11, freqFrom: 30000000, freqTo: 44999999},
+	{code: 0x12, freqFrom: 45000000, freqTo: 59999999},
+	{code: 0x13, freqFrom: 60000000, freqTo: 74799999},
+	{code: 0x14, freqFrom: 74800000, freqTo: 107999999},
+	{code: 0x15, freqFrom: 108000000, freqTo: 136999999},
+	{code: 0x16, freqFrom: 137000000, freqTo: 199999999},
+	{code: 0x17, freqFrom: 400000000, freqTo: 470000000},
+}
+
+type scopeFrame struct {
+	Time       time.Time `json:"time"`
+	Mode       string    `json:"mode"`
+	StartFreq  uint      `json:"startFreq"`
+	EndFreq    uint      `json:"endFreq"`
+	OutOfRange bool      `json:"outOfRange"`
+	Data       []int     `json:"data"`
+}
+
+type scopeStruct struct {
+	mutex sync.Mutex
+
+	// Waveform data can arrive in multiple CI-V frames (sequences), we collect them here.
+	assembling struct {
+		started    bool
+		mode       byte
+		freq       uint
+		spanOrEnd  uint
+		outOfRange bool
+		data       []byte
+	}
+
+	last *scopeFrame
+}
+
+var scope scopeStruct
+
+func (s *scopeStruct) getModeName(code byte) string {
+	switch code {
+	case 0x01:
+		return "fixed"
+	case 0x02:
+		return "scroll-c"
+	case 0x03:
+		return "scroll-f"
+	default:
+		return "center"
+	}
+}
+
+func (s *scopeStruct) getFixedEdgeRangeCode(f uint) (code byte, found bool) {
+	for _, r := range scopeFixedEdgeRanges {
+		if f >= r.freqFrom && f <= r.freqTo {
+			return r.code, true
+		}
+	}
+	return 0, false
+}
+
+func (s *scopeStruct) decodeBCDByte(b byte) int {
+	return int(b>>4)*10 + int(b&0x0f)
+}
+
+// Expects the payload of a 0x27 0x00 waveform data frame, starting from the main/sub byte.
+func (s *scopeStruct) add(d []byte) {
+	if len(d) < 3 {
+		return
+	}
+
+	seq := s.decodeBCDByte(d[1])
+	seqMax := s.decodeBCDByte(d[2])
+	d = d[3:]
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if seq == 1 {
+		if len(d) < 12 {
+			s.assembling.started = false
+			return
+		}
+		s.assembling.started = true
+		s.assembling.mode = d[0]
+		s.assembling.freq = civControl.decodeFreqData(d[1:6])
+		s.assembling.spanOrEnd = civControl.decodeFreqData(d[6:11])
+		s.assembling.outOfRange = d[11] != 0
+		s.assembling.data = s.assembling.data[:0]
+		// Over the network the whole waveform fits into the first sequence.
+		s.assembling.data = append(s.assembling.data, d[12:]...)
+	} else if s.assembling.started {
+		s.assembling.data = append(s.assembling.data, d...)
+	}
+
+	if !s.assembling.started || seq != seqMax {
+		return
+	}
+	s.assembling.started = false
+
+	f := &scopeFrame{
+		Time:       time.Now(),
+		Mode:       s.getModeName(s.assembling.mode),
+		OutOfRange: s.assembling.outOfRange,
+		Data:       make([]int, len(s.assembling.data)),
+	}
+	if s.assembling.mode == 0x00 || s.assembling.mode == 0x02 { // Center or scroll-C mode?
+		f.StartFreq = s.assembling.freq - s.assembling.spanOrEnd
+		f.EndFreq = s.assembling.freq + s.assembling.spanOrEnd
+	} else {
+		f.StartFreq = s.assembling.freq
+		f.EndFreq = s.assembling.spanOrEnd
+	}
+	for i, v := range s.assembling.data {
+		f.Data[i] = int(v)
+	}
+	s.last = f
+
+	statusLog.reportScope(f)
+	webSrv.broadcastScope(f)
+}
+
+func (s *scopeStruct) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.assembling.started = false
+	s.last = nil
+}
+
+// Returns the waveform rendered to a single line of block characters.
+func (s *scopeStruct) renderPanadapter(f *scopeFrame, width int) string {
+	if f == nil || len(f.Data) == 0 {
+		return ""
+	}
+
+	if width > len(f.Data) {
+		width = len(f.Data)
+	}
+
+	levels := []rune(" ▁▂▃▄▅▆▇█")
+	var b strings.Builder
+	for i := 0; i < width; i++ {
+		from := i * len(f.Data) / width
+		to := (i + 1) * len(f.Data) / width
+		var max int
+		for _, v := range f.Data[from:to] {
+			if v > max {
+				max = v
+			}
+		}
+		if max > scopeMaxAmplitude {
+			max = scopeMaxAmplitude
+		}
+		b.WriteRune(levels[max*(len(levels)-1)/scopeMaxAmplitude])
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Implements the systemd service notification protocol, see sd_notify(3).
+// All functions are no-ops if we're not started by systemd with a notify socket.
+type sdNotifyStruct struct {
+	lastHeartbeat int64 // Unix time in nanoseconds, accessed atomically.
+}
+
+var sdNotify sdNotifyStruct
+
+func (s *sdNotifyStruct) send(state string) {
+	socketAddr := os.Getenv("NOTIFY_SOCKET")
+	if socketAddr == "" {
+		return
+	}
+
+	// Addresses starting with @ are in the abstract namespace, this is handled by the net package.
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketAddr, Net: "unixgram"})
+	if err != nil {
+		log.Error("can't connect to the systemd notify socket: ", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		log.Error("can't send to the systemd notify socket: ", err)
+	}
+}
+
+func (s *sdNotifyStruct) ready(status string) {
+	s.send("READY=1\nSTATUS=" + status)
+}
+
+func (s *sdNotifyStruct) status(status string) {
+	// Status lines can't contain newlines.
+	s.send("STATUS=" + strings.ReplaceAll(status, "\n", " "))
+}
+
+func (s *sdNotifyStruct) reloading() {
+	s.send("RELOADING=1")
+}
+
+func (s *sdNotifyStruct) stopping() {
+	s.send("STOPPING=1")
+}
+
+func (s *sdNotifyStruct) watchdog() {
+	s.send("WATCHDOG=1")
+}
+
+// Returns the interval in which the watchdog should be pinged, or 0 if the watchdog is not enabled.
+func (s *sdNotifyStruct) getWatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseUint(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec == 0 {
+		return 0
+	}
+	// Pinging twice as often as required, as recommended by sd_watchdog_enabled(3).
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// Should be called regularly by the main loop and the stream loops to show that they are not stuck.
+func (s *sdNotifyStruct) heartbeat() {
+	atomic.StoreInt64(&s.lastHeartbeat, time.Now().UnixNano())
+}
+
+// Pings the watchdog in the background as long as heartbeats are received, so a long blocking
+// call in the main loop (like connecting to the radio) does not make systemd kill us, as long as
+// the stream loops are running.
+func (s *sdNotifyStruct) startWatchdog() {
+	interval := s.getWatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	s.heartbeat()
+	go func() {
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			// The watchdog timeout is twice the ping interval.
+			if time.Since(time.Unix(0, atomic.LoadInt64(&s.lastHeartbeat))) < 2*interval {
+				s.watchdog()
+			}
+		}
+	}()
+}
+
+// Returns a channel which ticks when a heartbeat should be sent. It returns nil if the watchdog
+// is not enabled, which blocks forever when used in a select.
+func (s *sdNotifyStruct) getWatchdogChan() <-chan time.Time {
+	interval := s.getWatchdogInterval()
+	if interval == 0 {
+		return nil
+	}
+	return time.NewTicker(interval).C
+}
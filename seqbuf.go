@@ -46,6 +46,8 @@ type seqBufEntry struct {
 type requestRetransmitCallbackType func(r seqNumRange) error
 
 type seqBuf struct {
+	// The name of the stream, used for the network statistics.
+	name                      string
 	length                    time.Duration
 	maxSeqNum                 seqNum
 	maxSeqNumDiff             seqNum
@@ -191,12 +193,19 @@ func (s *seqBuf) add(seq seqNum, data []byte) error {
 		return errors.New("seq out of range")
 	}
 
+	netstat.reportArrival(s.name)
+
 	if len(s.entries) == 0 {
+		// Arrived after a later packet has already been returned?
+		if s.alreadyReturnedFirstSeq && s.compareSeq(seq, s.lastReturnedSeq) != larger {
+			netstat.reportOutOfOrder(s.name)
+		}
 		s.addToFront(seq, data)
 		return nil
 	}
 
 	if s.entries[0].seq == seq { // Dropping duplicate seq.
+		netstat.reportDuplicate(s.name)
 		return nil
 	}
 
@@ -210,11 +219,13 @@ func (s *seqBuf) add(seq seqNum, data []byte) error {
 	for i := 1; i < len(s.entries); i++ {
 		// This seqnum is already in the queue? Ignoring it.
 		if s.entries[i].seq == seq {
+			netstat.reportDuplicate(s.name)
 			return nil
 		}
 
 		if s.compareSeq(seq, s.entries[i].seq) == larger {
 			// log.Debug("left for ", s.entries[i].seq)
+			netstat.reportOutOfOrder(s.name)
 			s.insert(seq, data, i)
 			return nil
 		}
@@ -222,6 +233,7 @@ func (s *seqBuf) add(seq seqNum, data []byte) error {
 	}
 
 	// No place found for the item?
+	netstat.reportOutOfOrder(s.name)
 	s.addToBack(seq, data)
 	return nil
 }
@@ -394,8 +406,9 @@ func (s *seqBuf) watcher() {
 
 // Setting a max. seqnum diff is optional. If it's 0 then the diff will be half of the maxSeqNum range.
 // Available entries coming out from the seqbuf will be sent to entryChan.
-func (s *seqBuf) init(length time.Duration, maxSeqNum, maxSeqNumDiff seqNum, entryChan chan seqBufEntry,
+func (s *seqBuf) init(name string, length time.Duration, maxSeqNum, maxSeqNumDiff seqNum, entryChan chan seqBufEntry,
 	requestRetransmitCallback requestRetransmitCallbackType) {
+	s.name = name
 	s.length = length
 	s.maxSeqNum = maxSeqNum
 	s.maxSeqNumDiff = maxSeqNumDiff
@@ -67,7 +67,7 @@ func (s *serialStream) handleRxSeqBufEntry(e seqBufEntry) {
 	if s.receivedSerialData {
 		// Out of order packets can happen if we receive a retransmitted packet, but too late.
 		if s.rxSeqBuf.compareSeq(e.seq, seqNum(s.lastReceivedSeq)) != larger {
-			log.Debug("got out of order pkt seq #", e.seq)
+			log.Debugw("got out of order pkt", "stream", "serial", "seq", e.seq)
 			return
 		}
 
@@ -79,8 +79,9 @@ func (s *serialStream) handleRxSeqBufEntry(e seqBufEntry) {
 			} else {
 				missingPkts = int(gotSeq) + 65536 - int(expectedSeq)
 			}
-			netstat.reportLoss(missingPkts)
-			log.Error("lost ", missingPkts, " packets")
+			netstat.reportLoss("serial", missingPkts)
+			log.Errorw("lost packets", "stream", "serial", "count", missingPkts)
+			eventBus.publishEvent("loss", map[string]interface{}{"stream": "serial", "pkts": missingPkts})
 		}
 	}
 	s.lastReceivedSeq = gotSeq
@@ -92,7 +93,9 @@ func (s *serialStream) handleRxSeqBufEntry(e seqBufEntry) {
 
 	e.data = e.data[21:]
 
-	if !civControl.decode(e.data) {
+	forward := civControl.decode(e.data)
+	civTrace.log(false, civSourceRadio, e.data, !forward)
+	if !forward {
 		return
 	}
 
@@ -117,7 +120,7 @@ func (s *serialStream) handleRead(r []byte) error {
 	return nil
 }
 
-func (s *serialStream) gotDataForRadio(r []byte) {
+func (s *serialStream) gotDataForRadio(r []byte, source civSource) {
 	for len(r) > 0 && !s.readFromSerialPort.frameStarted {
 		if s.readFromSerialPort.buf.Len() > 1 {
 			s.readFromSerialPort.buf.Reset()
@@ -151,8 +154,14 @@ func (s *serialStream) gotDataForRadio(r []byte) {
 	for _, b := range r {
 		s.readFromSerialPort.buf.WriteByte(b)
 		if b == 0xfc || b == 0xfd || s.readFromSerialPort.buf.Len() == maxSerialFrameLength {
-			if err := s.send(s.readFromSerialPort.buf.Bytes()); err != nil {
-				reportError(err)
+			if s.refuseFrame(s.readFromSerialPort.buf.Bytes(), source) {
+				civTrace.log(true, source, s.readFromSerialPort.buf.Bytes(), true)
+			} else {
+				civTrace.log(true, source, s.readFromSerialPort.buf.Bytes(), false)
+				// Sent through the queue, so the radio's reply can be forwarded to the client.
+				if err := civQueue.sendClientFrame(s.readFromSerialPort.buf.Bytes(), source); err != nil {
+					reportError(err)
+				}
 			}
 			if !s.readFromSerialPort.frameTimeout.Stop() {
 				<-s.readFromSerialPort.frameTimeout.C
@@ -164,12 +173,48 @@ func (s *serialStream) gotDataForRadio(r []byte) {
 	}
 }
 
+// Returns true if the frame has been refused, in this case an NG reply is sent back to the source.
+func (s *serialStream) refuseFrame(d []byte, source civSource) bool {
+	f, ok := parseCIVFrame(d)
+	if !ok {
+		return false
+	}
+
+	err := civFirewall.check(&f, source)
+	if err == nil && listenOnly && isCIVFrameRefusedInListenOnlyMode(&f) {
+		err = errListenOnly
+	}
+	if err == nil {
+		err = txPolicy.checkCIVFrame(&f, source)
+	}
+	if err == nil {
+		err = pttArbiter.checkCIVFrame(&f, source)
+	}
+	if err == nil {
+		return false
+	}
+
+	log.Printw("refused CI-V frame from "+source.String(), "desc", civTrace.describe(&f), "err", err.Error())
+	reply := getCIVNGReply(&f)
+	switch source {
+	case civSourcePTY:
+		if serialPort.write != nil {
+			serialPort.write <- reply
+		}
+	case civSourceTCP:
+		if serialTCPSrv.isClientConnected() {
+			serialTCPSrv.toClient <- reply
+		}
+	}
+	return true
+}
+
 func (s *serialStream) loop() {
 	if enableSerialDevice {
 		for {
 			select {
 			case r := <-serialPort.read:
-				s.gotDataForRadio(r)
+				s.gotDataForRadio(r, civSourcePTY)
 
 			case r := <-s.common.readChan:
 				if err := s.handleRead(r); err != nil {
@@ -178,7 +223,7 @@ func (s *serialStream) loop() {
 			case e := <-s.rxSeqBufEntryChan:
 				s.handleRxSeqBufEntry(e)
 			case r := <-serialTCPSrv.fromClient:
-				s.gotDataForRadio(r)
+				s.gotDataForRadio(r, civSourceTCP)
 			case <-s.readFromSerialPort.frameTimeout.C:
 				s.readFromSerialPort.buf.Reset()
 				s.readFromSerialPort.frameStarted = false
@@ -197,7 +242,7 @@ func (s *serialStream) loop() {
 			case e := <-s.rxSeqBufEntryChan:
 				s.handleRxSeqBufEntry(e)
 			case r := <-serialTCPSrv.fromClient:
-				s.gotDataForRadio(r)
+				s.gotDataForRadio(r, civSourceTCP)
 			case <-s.readFromSerialPort.frameTimeout.C:
 				s.readFromSerialPort.buf.Reset()
 				s.readFromSerialPort.frameStarted = false
@@ -238,7 +283,7 @@ func (s *serialStream) init(devName string) error {
 	log.Print("stream started")
 
 	s.rxSeqBufEntryChan = make(chan seqBufEntry)
-	s.rxSeqBuf.init(serialRxSeqBufLength, 0xffff, 0, s.rxSeqBufEntryChan, s.common.requestRetransmit)
+	s.rxSeqBuf.init("serial", serialRxSeqBufLength, 0xffff, 0, s.rxSeqBufEntryChan, s.common.requestRetransmit)
 
 	s.deinitNeededChan = make(chan bool)
 	s.deinitFinishedChan = make(chan bool)
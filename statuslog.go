@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,13 +11,36 @@ import (
 	"github.com/mattn/go-isatty"
 )
 
+type statusLogFunc struct {
+	name     string // The event bus state key.
+	label    string
+	levelKey string // The event bus state key of the function's level, empty if it has no level.
+}
+
+// Radio functions displayed on the status bar if they are enabled, in display order.
+var statusLogFuncs = []statusLogFunc{
+	{name: "nbEnabled", label: "NB", levelKey: "nb"},
+	{name: "anfEnabled", label: "ANF"},
+	{name: "mnEnabled", label: "MN"},
+	{name: "compEnabled", label: "COMP", levelKey: "compLevel"},
+	{name: "monEnabled", label: "MON", levelKey: "monGain"},
+	{name: "voxEnabled", label: "VOX", levelKey: "voxGain"},
+}
+
 type statusLogData struct {
-	line1 string
-	line2 string
-	line3 string
+	line1     string
+	line2     string
+	line3     string
+	scopeLine string
+	// Per-stream network statistics, only filled if the detailed view is enabled.
+	netstatLines []string
+
+	// The number of lines printed by the last realtime print.
+	printedLines int
 
 	ptt          bool
 	tune         bool
+	pttOwner     string
 	frequency    uint
 	subFrequency uint
 	mode         string
@@ -27,6 +51,8 @@ type statusLogData struct {
 	subFilter    string
 	preamp       string
 	agc          string
+	atu          bool
+	antenna      string
 	vd           string
 	txPower      string
 	rfGain       string
@@ -34,11 +60,25 @@ type statusLogData struct {
 	nr           string
 	nrEnabled    bool
 	s            string
+	sDBm         string
 	ovf          bool
 	swr          string
+	po           string
+	alc          string
+	comp         string
+	id           string
 	ts           string
 	split        string
 	splitMode    splitMode
+	offset       uint
+	tone         string
+	arp          string
+	rit          string
+	pbt          string
+	att          string
+	funcEnabled  map[string]bool
+	funcLevel    map[string]int
+	scope        *scopeFrame
 
 	startTime time.Time
 	rttStr    string
@@ -74,11 +114,15 @@ type statusLogStruct struct {
 	}
 
 	data *statusLogData
+
+	showNetstatDetails bool
 }
 
 var statusLog statusLogStruct
 
 func (s *statusLogStruct) reportRTTLatency(l time.Duration) {
+	eventBus.publishState(eventBusState{"rttMs": l.Milliseconds()})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -99,6 +143,8 @@ func (s *statusLogStruct) updateAudioStateStr() {
 }
 
 func (s *statusLogStruct) reportAudioMon(enabled bool) {
+	eventBus.publishState(eventBusState{"audioMon": enabled})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -110,6 +156,8 @@ func (s *statusLogStruct) reportAudioMon(enabled bool) {
 }
 
 func (s *statusLogStruct) reportAudioRec(enabled bool) {
+	eventBus.publishState(eventBusState{"audioRec": enabled})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -121,6 +169,8 @@ func (s *statusLogStruct) reportAudioRec(enabled bool) {
 }
 
 func (s *statusLogStruct) reportFrequency(f uint) {
+	eventBus.publishState(eventBusState{"freq": f})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -131,6 +181,8 @@ func (s *statusLogStruct) reportFrequency(f uint) {
 }
 
 func (s *statusLogStruct) reportSubFrequency(f uint) {
+	eventBus.publishState(eventBusState{"subFreq": f})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -141,6 +193,8 @@ func (s *statusLogStruct) reportSubFrequency(f uint) {
 }
 
 func (s *statusLogStruct) reportMode(mode string, dataMode bool, filter string) {
+	eventBus.publishState(eventBusState{"mode": mode, "dataMode": dataMode, "filter": filter})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -157,6 +211,8 @@ func (s *statusLogStruct) reportMode(mode string, dataMode bool, filter string)
 }
 
 func (s *statusLogStruct) reportSubMode(mode string, dataMode bool, filter string) {
+	eventBus.publishState(eventBusState{"subMode": mode, "subDataMode": dataMode, "subFilter": filter})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -173,6 +229,8 @@ func (s *statusLogStruct) reportSubMode(mode string, dataMode bool, filter strin
 }
 
 func (s *statusLogStruct) reportPreamp(preamp int) {
+	eventBus.publishState(eventBusState{"preamp": preamp})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -182,7 +240,33 @@ func (s *statusLogStruct) reportPreamp(preamp int) {
 	s.data.preamp = fmt.Sprint("PAMP", preamp)
 }
 
+func (s *statusLogStruct) reportATU(enabled bool) {
+	eventBus.publishState(eventBusState{"atu": enabled})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.atu = enabled
+}
+
+func (s *statusLogStruct) reportAntenna(nr int) {
+	eventBus.publishState(eventBusState{"antenna": nr + 1})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.antenna = fmt.Sprint("ANT", nr+1)
+}
+
 func (s *statusLogStruct) reportAGC(agc string) {
+	eventBus.publishState(eventBusState{"agc": agc})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -193,6 +277,8 @@ func (s *statusLogStruct) reportAGC(agc string) {
 }
 
 func (s *statusLogStruct) reportNREnabled(enabled bool) {
+	eventBus.publishState(eventBusState{"nrEnabled": enabled})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -203,6 +289,8 @@ func (s *statusLogStruct) reportNREnabled(enabled bool) {
 }
 
 func (s *statusLogStruct) reportVd(voltage float64) {
+	eventBus.publishState(eventBusState{"vd": voltage})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -212,7 +300,9 @@ func (s *statusLogStruct) reportVd(voltage float64) {
 	s.data.vd = fmt.Sprintf("%.1fV", voltage)
 }
 
-func (s *statusLogStruct) reportS(sValue string) {
+func (s *statusLogStruct) reportS(sValue string, dbm float64) {
+	eventBus.publishState(eventBusState{"s": sValue, "sDBm": dbm})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -220,9 +310,12 @@ func (s *statusLogStruct) reportS(sValue string) {
 		return
 	}
 	s.data.s = sValue
+	s.data.sDBm = fmt.Sprintf("%.0fdBm", dbm)
 }
 
 func (s *statusLogStruct) reportOVF(ovf bool) {
+	eventBus.publishState(eventBusState{"ovf": ovf})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -233,6 +326,8 @@ func (s *statusLogStruct) reportOVF(ovf bool) {
 }
 
 func (s *statusLogStruct) reportSWR(swr float64) {
+	eventBus.publishState(eventBusState{"swr": swr})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -242,7 +337,57 @@ func (s *statusLogStruct) reportSWR(swr float64) {
 	s.data.swr = fmt.Sprintf("%.1f", swr)
 }
 
+func (s *statusLogStruct) reportPo(watts float64) {
+	eventBus.publishState(eventBusState{"po": watts})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.po = formatWatts(watts)
+}
+
+func (s *statusLogStruct) reportALC(alc float64) {
+	eventBus.publishState(eventBusState{"alc": alc})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.alc = fmt.Sprintf("%.0f%%", alc*100)
+}
+
+func (s *statusLogStruct) reportComp(db float64) {
+	eventBus.publishState(eventBusState{"comp": db})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.comp = fmt.Sprintf("%.0fdB", db)
+}
+
+func (s *statusLogStruct) reportId(current float64) {
+	eventBus.publishState(eventBusState{"id": current})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.id = fmt.Sprintf("%.1fA", current)
+}
+
 func (s *statusLogStruct) reportTS(ts uint) {
+	eventBus.publishState(eventBusState{"ts": ts})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -264,6 +409,8 @@ func (s *statusLogStruct) reportTS(ts uint) {
 }
 
 func (s *statusLogStruct) reportPTT(ptt, tune bool) {
+	eventBus.publishState(eventBusState{"ptt": ptt, "tune": tune})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -274,17 +421,33 @@ func (s *statusLogStruct) reportPTT(ptt, tune bool) {
 	s.data.ptt = ptt
 }
 
+func (s *statusLogStruct) reportPTTOwner(owner string) {
+	eventBus.publishState(eventBusState{"pttOwner": owner})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.pttOwner = owner
+}
+
 func (s *statusLogStruct) reportTxPower(percent int) {
+	eventBus.publishState(eventBusState{"pwr": percent})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	if s.data == nil {
 		return
 	}
-	s.data.txPower = fmt.Sprint(percent, "%")
+	s.data.txPower = fmt.Sprint(percent, "% ", formatWatts(activeRadioModel.getPwrWatts(percent)))
 }
 
 func (s *statusLogStruct) reportRFGain(percent int) {
+	eventBus.publishState(eventBusState{"rfGain": percent})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -295,6 +458,8 @@ func (s *statusLogStruct) reportRFGain(percent int) {
 }
 
 func (s *statusLogStruct) reportSQL(percent int) {
+	eventBus.publishState(eventBusState{"sql": percent})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -305,6 +470,8 @@ func (s *statusLogStruct) reportSQL(percent int) {
 }
 
 func (s *statusLogStruct) reportNR(percent int) {
+	eventBus.publishState(eventBusState{"nr": percent})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -315,6 +482,8 @@ func (s *statusLogStruct) reportNR(percent int) {
 }
 
 func (s *statusLogStruct) reportSplit(mode splitMode, split string) {
+	eventBus.publishState(eventBusState{"split": splitModeNames[mode]})
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -329,6 +498,148 @@ func (s *statusLogStruct) reportSplit(mode splitMode, split string) {
 	}
 }
 
+func (s *statusLogStruct) reportOffset(offset uint) {
+	eventBus.publishState(eventBusState{"offset": offset})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.offset = offset
+}
+
+// Tones are in 0.1 Hz, value is the tone or code used by the current tone mode.
+func (s *statusLogStruct) reportTone(mode, value string, tone, tsqlTone, dcsCode int) {
+	eventBus.publishState(eventBusState{"toneMode": mode, "tone": float64(tone) / 10,
+		"tsqlTone": float64(tsqlTone) / 10, "dcsCode": dcsCode})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	if value == "" {
+		s.data.tone = ""
+	} else {
+		s.data.tone = mode + " " + value
+	}
+}
+
+func (s *statusLogStruct) reportARP(arp string) {
+	eventBus.publishState(eventBusState{"arp": arp})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	if arp == "OFF" {
+		s.data.arp = ""
+	} else {
+		s.data.arp = "ARP " + arp
+	}
+}
+
+func (s *statusLogStruct) reportRIT(offset int, ritEnabled, xitEnabled bool) {
+	eventBus.publishState(eventBusState{"rit": offset, "ritEnabled": ritEnabled, "xitEnabled": xitEnabled})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	switch {
+	case ritEnabled && xitEnabled:
+		s.data.rit = fmt.Sprintf("RIT/XIT%+d", offset)
+	case ritEnabled:
+		s.data.rit = fmt.Sprintf("RIT%+d", offset)
+	case xitEnabled:
+		s.data.rit = fmt.Sprintf("XIT%+d", offset)
+	default:
+		s.data.rit = ""
+	}
+}
+
+func (s *statusLogStruct) reportPBT(in, out int) {
+	eventBus.publishState(eventBusState{"pbtIn": in, "pbtOut": out})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	if in == 0 && out == 0 {
+		s.data.pbt = ""
+	} else {
+		s.data.pbt = fmt.Sprintf("PBT%+d/%+d", in, out)
+	}
+}
+
+func (s *statusLogStruct) reportATT(db int) {
+	eventBus.publishState(eventBusState{"att": db})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	if db == 0 {
+		s.data.att = ""
+	} else {
+		s.data.att = fmt.Sprint("ATT", db)
+	}
+}
+
+// Reports the state of one of the functions in statusLogFuncs.
+func (s *statusLogStruct) reportFunc(name string, enabled bool) {
+	eventBus.publishState(eventBusState{name: enabled})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.funcEnabled[name] = enabled
+}
+
+// Reports the level of one of the functions in statusLogFuncs in percent.
+func (s *statusLogStruct) reportFuncLevel(levelKey string, percent int) {
+	eventBus.publishState(eventBusState{levelKey: percent})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.funcLevel[levelKey] = percent
+}
+
+func (s *statusLogStruct) reportScope(f *scopeFrame) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.scope = f
+}
+
+func (s *statusLogStruct) toggleNetstatDetails() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.showNetstatDetails = !s.showNetstatDetails
+}
+
 func (s *statusLogStruct) clearInternal() {
 	fmt.Printf("%c[2K", 27)
 }
@@ -338,14 +649,40 @@ func (s *statusLogStruct) print() {
 	defer s.mutex.Unlock()
 
 	if s.isRealtimeInternal() {
-		s.clearInternal()
-		fmt.Println(s.data.line1)
-		s.clearInternal()
-		fmt.Println(s.data.line2)
-		s.clearInternal()
-		fmt.Printf(s.data.line3+"%c[1A%c[1A", 27, 27)
+		lines := []string{s.data.line1, s.data.line2, s.data.line3}
+		lines = append(lines, s.data.netstatLines...)
+		if s.data.scopeLine != "" {
+			lines = append([]string{s.data.scopeLine}, lines...)
+		}
+		// Clearing lines left over from a previous print with more lines.
+		for i := len(lines); i < s.data.printedLines; i++ {
+			lines = append(lines, "")
+		}
+		for i := range lines {
+			s.clearInternal()
+			if i < len(lines)-1 {
+				fmt.Println(lines[i])
+			} else {
+				fmt.Print(lines[i])
+			}
+		}
+		for i := 1; i < len(lines); i++ {
+			fmt.Printf("%c[1A", 27)
+		}
+		s.data.printedLines = len(lines)
+	} else if daemonMode {
+		// Logging a single line, and also reporting it as the service status.
+		l := fmt.Sprint(s.data.line2, " | ", s.data.line1, " | ", strings.TrimSuffix(s.data.line3, "\r"))
+		log.PrintStatusLog(l)
+		sdNotify.status(l)
+		for _, l := range s.data.netstatLines {
+			log.PrintStatusLog(l)
+		}
 	} else {
 		log.PrintStatusLog(s.data.line3)
+		for _, l := range s.data.netstatLines {
+			log.PrintStatusLog(l)
+		}
 	}
 }
 
@@ -379,14 +716,30 @@ func (s *statusLogStruct) update() {
 	if s.data.filter != "" {
 		filterStr = " " + s.data.filter
 	}
+	var pbtStr string
+	if s.data.pbt != "" {
+		pbtStr = " " + s.data.pbt
+	}
 	var preampStr string
 	if s.data.preamp != "" {
 		preampStr = " " + s.data.preamp
 	}
+	var attStr string
+	if s.data.att != "" {
+		attStr = " " + s.data.att
+	}
 	var agcStr string
 	if s.data.agc != "" {
 		agcStr = " " + s.data.agc
 	}
+	var atuStr string
+	if s.data.atu {
+		atuStr = " ATU"
+	}
+	var antennaStr string
+	if s.data.antenna != "" {
+		antennaStr = " " + s.data.antenna
+	}
 	var nrStr string
 	if s.data.nr != "" {
 		nrStr = " NR"
@@ -404,13 +757,30 @@ func (s *statusLogStruct) update() {
 	if s.data.sql != "" {
 		sqlStr = " sql " + s.data.sql
 	}
-	s.data.line1 = fmt.Sprint(s.data.audioStateStr, filterStr, preampStr, agcStr, nrStr, rfGainStr, sqlStr)
+	var listenOnlyStr string
+	if listenOnly {
+		listenOnlyStr = " RX only"
+	}
+	var funcsStr string
+	for _, f := range statusLogFuncs {
+		if !s.data.funcEnabled[f.name] {
+			continue
+		}
+		funcsStr += " " + f.label
+		if l, ok := s.data.funcLevel[f.levelKey]; ok && f.levelKey != "" {
+			funcsStr += fmt.Sprint(l, "%")
+		}
+	}
+	s.data.line1 = fmt.Sprint(activeRadioModel.name, " ", s.data.audioStateStr, filterStr, pbtStr, preampStr, attStr, agcStr, atuStr, antennaStr, nrStr, funcsStr, rfGainStr, sqlStr, listenOnlyStr)
 
 	var stateStr string
 	if s.data.tune {
 		stateStr = s.preGenerated.stateStr.tune
 	} else if s.data.ptt {
 		stateStr = s.preGenerated.stateStr.tx
+		if s.data.pttOwner != "" {
+			stateStr += " " + s.data.pttOwner
+		}
 	} else {
 		var ovfStr string
 		if s.data.ovf {
@@ -422,6 +792,9 @@ func (s *statusLogStruct) update() {
 			stateStr = s.preGenerated.rxColor.Sprint(" " + s.padRight(s.data.s, 5) + " ")
 		}
 		stateStr += ovfStr
+		if s.data.sDBm != "" {
+			stateStr += " " + s.data.sDBm
+		}
 	}
 	var tsStr string
 	if s.data.ts != "" {
@@ -445,14 +818,42 @@ func (s *statusLogStruct) update() {
 		if s.data.splitMode == splitModeOn {
 			splitStr += fmt.Sprintf("/%.6f/%s%s/%s", float64(s.data.subFrequency)/1000000,
 				s.data.subMode, s.data.subDataMode, s.data.subFilter)
+		} else if s.data.splitMode != splitModeOff && s.data.offset > 0 {
+			splitStr += fmt.Sprintf("/%.3f", float64(s.data.offset)/1000000)
 		}
 	}
+	var ritStr string
+	if s.data.rit != "" {
+		ritStr = " " + s.data.rit
+	}
+	var toneStr string
+	if s.data.tone != "" {
+		toneStr = " " + s.data.tone
+	}
+	var arpStr string
+	if s.data.arp != "" {
+		arpStr = " " + s.data.arp
+	}
 	var swrStr string
-	if (s.data.tune || s.data.ptt) && s.data.swr != "" {
-		swrStr = " SWR" + s.data.swr
+	if s.data.tune || s.data.ptt {
+		if s.data.po != "" {
+			swrStr += " " + s.data.po
+		}
+		if s.data.swr != "" {
+			swrStr += " SWR" + s.data.swr
+		}
+		if s.data.alc != "" {
+			swrStr += " ALC" + s.data.alc
+		}
+		if s.data.comp != "" {
+			swrStr += " COMP" + s.data.comp
+		}
+		if s.data.id != "" {
+			swrStr += " Id" + s.data.id
+		}
 	}
 	s.data.line2 = fmt.Sprint(stateStr, " ", fmt.Sprintf("%.6f", float64(s.data.frequency)/1000000),
-		tsStr, modeStr, splitStr, vdStr, txPowerStr, swrStr)
+		tsStr, modeStr, splitStr, ritStr, toneStr, arpStr, vdStr, txPowerStr, swrStr)
 
 	up, down, lost, retransmits := netstat.get()
 	lostStr := "0"
@@ -464,16 +865,42 @@ func (s *statusLogStruct) update() {
 		retransmitsStr = s.preGenerated.retransmitsColor.Sprint(" ", retransmits, " ")
 	}
 
+	s.data.scopeLine = ""
+	if s.data.scope != nil && s.isRealtimeInternal() {
+		s.data.scopeLine = fmt.Sprintf("%.3f %s %.3f", float64(s.data.scope.StartFreq)/1000000,
+			scope.renderPanadapter(s.data.scope, scopePanadapterWidth), float64(s.data.scope.EndFreq)/1000000)
+	}
+
 	s.data.line3 = fmt.Sprint("up ", s.padLeft(fmt.Sprint(time.Since(s.data.startTime).Round(time.Second)), 6),
 		" rtt ", s.padLeft(s.data.rttStr, 3), "ms up ",
 		s.padLeft(netstat.formatByteCount(up), 8), "/s down ",
 		s.padLeft(netstat.formatByteCount(down), 8), "/s retx ", retransmitsStr, "/1m lost ", lostStr, "/1m\r")
 
+	s.data.netstatLines = nil
+	if s.showNetstatDetails {
+		names, stats := netstat.getStreams()
+		for _, n := range names {
+			st := stats[n]
+			s.data.netstatLines = append(s.data.netstatLines, fmt.Sprint(s.padRight(n, 7), " up ",
+				s.padLeft(netstat.formatByteCount(st.toRadioBytesPerSec), 8), "/s down ",
+				s.padLeft(netstat.formatByteCount(st.fromRadioBytesPerSec), 8), "/s retx ",
+				st.requestedRetransmits, "/", st.retransmittedPkts, " lost ", st.lostPkts,
+				" ooo ", st.outOfOrderPkts, " dup ", st.duplicatePkts,
+				" jitter ", fmt.Sprintf("%.1fms", float64(st.jitter.Microseconds())/1000)))
+		}
+	}
+
 	if s.isRealtimeInternal() {
 		t := time.Now().Format("2006-01-02T15:04:05.000Z0700")
 		s.data.line1 = fmt.Sprint(t, " ", s.data.line1)
 		s.data.line2 = fmt.Sprint(t, " ", s.data.line2)
 		s.data.line3 = fmt.Sprint(t, " ", s.data.line3)
+		for i := range s.data.netstatLines {
+			s.data.netstatLines[i] = fmt.Sprint(t, " ", s.data.netstatLines[i])
+		}
+		if s.data.scopeLine != "" {
+			s.data.scopeLine = fmt.Sprint(t, " ", s.data.scopeLine)
+		}
 	}
 }
 
@@ -508,6 +935,16 @@ func (s *statusLogStruct) isActive() bool {
 	return s.ticker != nil
 }
 
+func (s *statusLogStruct) getInterval() time.Duration {
+	if daemonMode {
+		return daemonStatusInterval
+	}
+	if !s.isRealtimeInternal() && statusLogInterval < time.Second {
+		return time.Second
+	}
+	return statusLogInterval
+}
+
 func (s *statusLogStruct) startPeriodicPrint() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -519,11 +956,13 @@ func (s *statusLogStruct) startPeriodicPrint() {
 		startTime:     time.Now(),
 		rttStr:        "?",
 		audioStateStr: s.preGenerated.audioStateStr.off,
+		funcEnabled:   make(map[string]bool),
+		funcLevel:     make(map[string]int),
 	}
 
 	s.stopChan = make(chan bool)
 	s.stopFinishedChan = make(chan bool)
-	s.ticker = time.NewTicker(statusLogInterval)
+	s.ticker = time.NewTicker(s.getInterval())
 	go s.loop()
 }
 
@@ -538,12 +977,10 @@ func (s *statusLogStruct) stopPeriodicPrint() {
 	<-s.stopFinishedChan
 
 	if s.isRealtimeInternal() {
-		s.clearInternal()
-		fmt.Println()
-		s.clearInternal()
-		fmt.Println()
-		s.clearInternal()
-		fmt.Println()
+		for i := 0; i < s.data.printedLines; i++ {
+			s.clearInternal()
+			fmt.Println()
+		}
 	}
 }
 
@@ -552,9 +989,9 @@ func (s *statusLogStruct) initIfNeeded() {
 		return
 	}
 
-	if quietLog || (!isatty.IsTerminal(os.Stdout.Fd()) && statusLogInterval < time.Second) {
-		statusLogInterval = time.Second
-	} else {
+	if daemonMode {
+		color.NoColor = true
+	} else if !quietLog && (isatty.IsTerminal(os.Stdout.Fd()) || statusLogInterval >= time.Second) {
 		keyboard.init()
 	}
 
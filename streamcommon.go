@@ -6,12 +6,49 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 )
 
 const expectTimeoutDuration = time.Second
 const maxRetransmitRequestPacketCount = 10
 
+// Retransmit requests are reported in one event per stream in this interval, if at least this many
+// packets were requested.
+const retransmitEventInterval = time.Second
+const retransmitEventMinPkts = 5
+
+type retransmitBurst struct {
+	mutex sync.Mutex
+	timer *time.Timer
+	reqs  int
+	pkts  int
+}
+
+func (b *retransmitBurst) report(stream string, pkts int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.reqs++
+	b.pkts += pkts
+	if b.timer == nil {
+		b.timer = time.AfterFunc(retransmitEventInterval, func() { b.flush(stream) })
+	}
+}
+
+func (b *retransmitBurst) flush(stream string) {
+	b.mutex.Lock()
+	reqs, pkts := b.reqs, b.pkts
+	b.reqs = 0
+	b.pkts = 0
+	b.timer = nil
+	b.mutex.Unlock()
+
+	if pkts >= retransmitEventMinPkts {
+		eventBus.publishEvent("retransmit", map[string]interface{}{"stream": stream, "requests": reqs, "pkts": pkts})
+	}
+}
+
 type streamCommon struct {
 	name                    string
 	conn                    *net.UDPConn
@@ -24,13 +61,15 @@ type streamCommon struct {
 
 	pkt0 pkt0Type
 	pkt7 pkt7Type
+
+	retransmitBurst retransmitBurst
 }
 
 func (s *streamCommon) send(d []byte) error {
 	if _, err := s.conn.Write(d); err != nil {
 		return err
 	}
-	netstat.add(len(d), 0)
+	netstat.add(s.name, len(d), 0)
 	return nil
 }
 
@@ -38,7 +77,7 @@ func (s *streamCommon) read() ([]byte, error) {
 	b := make([]byte, 1500)
 	n, _, err := s.conn.ReadFromUDP(b)
 	if err == nil {
-		netstat.add(0, n)
+		netstat.add(s.name, 0, n)
 	}
 	return b[:n], err
 }
@@ -78,6 +117,7 @@ func (s *streamCommon) tryReceivePacket(timeout time.Duration, packetLength, mat
 		case <-timer.C:
 			return nil
 		}
+		sdNotify.heartbeat()
 
 		if len(r) == packetLength && bytes.Equal(r[matchStartByte:len(b)+matchStartByte], b) {
 			break
@@ -89,7 +129,8 @@ func (s *streamCommon) tryReceivePacket(timeout time.Duration, packetLength, mat
 func (s *streamCommon) expect(packetLength int, b []byte) ([]byte, error) {
 	r := s.tryReceivePacket(expectTimeoutDuration, packetLength, 0, b)
 	if r == nil {
-		return nil, errors.New(s.name + "/expect timeout - the server did not answer, check if it's running")
+		return nil, newConnError(connErrorExpectTimeout,
+			s.name+"/expect timeout - the server did not answer, check if it's running")
 	}
 	return r, nil
 }
@@ -108,7 +149,7 @@ func (s *streamCommon) sendPkt3() error {
 }
 
 func (s *streamCommon) waitForPkt4Answer() error {
-	log.Debug(s.name + "/expecting a pkt4 answer")
+	log.Debugw("expecting a pkt4 answer", "stream", s.name)
 	// Example answer from radio: 0x10, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x8c, 0x7d, 0x45, 0x7a, 0x1d, 0xf6, 0xe9, 0x0b
 	r, err := s.expect(16, []byte{0x10, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00})
 	if err != nil {
@@ -133,7 +174,7 @@ func (s *streamCommon) sendPkt6() error {
 }
 
 func (s *streamCommon) waitForPkt6Answer() error {
-	log.Debug(s.name + "/expecting pkt6 answer")
+	log.Debugw("expecting pkt6 answer", "stream", s.name)
 	// Example answer from radio: 0x10, 0x00, 0x00, 0x00, 0x06, 0x00, 0x01, 0x00, 0xe8, 0xd0, 0x44, 0x50, 0xa0, 0x61, 0x39, 0xbe
 	_, err := s.expect(16, []byte{0x10, 0x00, 0x00, 0x00, 0x06, 0x00, 0x01, 0x00})
 	return err
@@ -180,15 +221,16 @@ func (s *streamCommon) requestRetransmit(r seqNumRange) error {
 		return errors.New("retransmit range too large")
 	}
 
+	netstat.reportRetransmitRequestSent(s.name, diff+1)
+	s.retransmitBurst.report(s.name, diff+1)
+
 	if diff == 0 {
-		log.Debug(s.name+"/requesting pkt #", r[0], " retransmit")
-		netstat.reportRetransmit(diff)
+		log.Debugw("requesting retransmit", "stream", s.name, "seq", r[0])
 		if err := s.sendRetransmitRequest(uint16(r[0])); err != nil {
 			return err
 		}
 	} else {
-		log.Debug(s.name+"/requesting pkt #", r[0], "-#", r[1], " retransmit")
-		netstat.reportRetransmit(diff)
+		log.Debugw("requesting retransmit", "stream", s.name, "from", r[0], "to", r[1])
 		if err := s.sendRetransmitRequestForRanges([]seqNumRange{r}); err != nil {
 			return err
 		}
@@ -197,7 +239,7 @@ func (s *streamCommon) requestRetransmit(r seqNumRange) error {
 }
 
 func (s *streamCommon) sendDisconnect() error {
-	log.Print(s.name + "/disconnecting")
+	log.Printw("disconnecting", "stream", s.name)
 	p := []byte{0x10, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00,
 		byte(s.localSID >> 24), byte(s.localSID >> 16), byte(s.localSID >> 8), byte(s.localSID),
 		byte(s.remoteSID >> 24), byte(s.remoteSID >> 16), byte(s.remoteSID >> 8), byte(s.remoteSID)}
@@ -226,7 +268,7 @@ func (s *streamCommon) start() error {
 func (s *streamCommon) init(name string, portNumber int) error {
 	s.name = name
 	hostPort := fmt.Sprint(connectAddress, ":", portNumber)
-	log.Print(s.name+"/connecting to ", hostPort)
+	log.Printw("connecting", "stream", s.name, "address", hostPort)
 	raddr, err := net.ResolveUDPAddr("udp", hostPort)
 	if err != nil {
 		return err
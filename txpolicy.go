@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A frequency range where transmitting is allowed.
+type txPolicySegment struct {
+	freqFrom      uint
+	freqTo        uint
+	maxPwrPercent int
+}
+
+func (s *txPolicySegment) String() string {
+	return fmt.Sprint(s.freqFrom, "-", s.freqTo, " Hz")
+}
+
+// Parses a comma separated list of segments in the format from-to[:maxpowerpercent], frequencies are in Hz.
+// Example: 3500000-3800000:50,7000000-7200000
+func parseTxPolicySegments(str string) (res []txPolicySegment, err error) {
+	for _, s := range strings.Split(str, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		seg := txPolicySegment{maxPwrPercent: 100}
+		rangeStr := s
+		if i := strings.Index(s, ":"); i >= 0 {
+			rangeStr = s[:i]
+			seg.maxPwrPercent, err = strconv.Atoi(s[i+1:])
+			if err != nil || seg.maxPwrPercent < 0 || seg.maxPwrPercent > 100 {
+				return nil, errors.New("invalid max power in TX segment " + s)
+			}
+		}
+		freqs := strings.Split(rangeStr, "-")
+		if len(freqs) != 2 {
+			return nil, errors.New("invalid TX segment " + s)
+		}
+		from, err := strconv.ParseUint(freqs[0], 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid TX segment " + s)
+		}
+		to, err := strconv.ParseUint(freqs[1], 10, 64)
+		if err != nil || to < from {
+			return nil, errors.New("invalid TX segment " + s)
+		}
+		seg.freqFrom = uint(from)
+		seg.freqTo = uint(to)
+		res = append(res, seg)
+	}
+	return res, nil
+}
+
+var errTxBlocked = errors.New("tx blocked")
+
+// Decides if transmitting is allowed. All TX attempts go through this, no matter if they are coming from
+// kappanhang itself (hotkeys, rigctld, API) or from raw CI-V clients on the virtual serial port or TCP.
+type txPolicyStruct struct {
+	mutex     sync.Mutex
+	lastTxEnd time.Time
+}
+
+var txPolicy txPolicyStruct
+
+// Returns nil if TX is allowed everywhere (no segments configured), or if the frequency is outside
+// all segments.
+func (p *txPolicyStruct) findSegment(freq uint) *txPolicySegment {
+	for i := range txSegments {
+		if freq >= txSegments[i].freqFrom && freq <= txSegments[i].freqTo {
+			return &txSegments[i]
+		}
+	}
+	return nil
+}
+
+func (p *txPolicyStruct) reject(source civSource, reason string) error {
+	log.Printw("tx blocked", "source", source.String(), "reason", reason)
+	eventBus.publishEvent("txBlocked", map[string]string{"source": source.String(), "reason": reason})
+	return fmt.Errorf("%w: %s", errTxBlocked, reason)
+}
+
+func (p *txPolicyStruct) checkFreq(source civSource, freq uint) error {
+	if len(txSegments) > 0 && p.findSegment(freq) == nil {
+		return p.reject(source, fmt.Sprint(freq, " Hz is outside the allowed TX segments"))
+	}
+	return nil
+}
+
+func (p *txPolicyStruct) checkPwr(source civSource, freq uint, pwrPercent int) error {
+	seg := p.findSegment(freq)
+	if seg != nil && pwrPercent > seg.maxPwrPercent {
+		return p.reject(source, fmt.Sprint("RF power ", pwrPercent, "% is above the ", seg.maxPwrPercent,
+			"% limit of the ", seg.String(), " segment"))
+	}
+	return nil
+}
+
+// Called before starting a transmission (PTT or tune).
+func (p *txPolicyStruct) checkTx(source civSource, freq uint, pwrPercent int) error {
+	if err := p.checkFreq(source, freq); err != nil {
+		return err
+	}
+	if err := p.checkPwr(source, freq, pwrPercent); err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	remaining := txCooldown - time.Since(p.lastTxEnd)
+	p.mutex.Unlock()
+	if remaining > 0 {
+		return p.reject(source, fmt.Sprint("cool-down, TX is allowed again in ", remaining.Round(100*time.Millisecond)))
+	}
+	return nil
+}
+
+// Called when a transmission (PTT or tune) ends, this starts the cool-down.
+func (p *txPolicyStruct) reportTxEnd() {
+	p.mutex.Lock()
+	p.lastTxEnd = time.Now()
+	p.mutex.Unlock()
+}
+
+// Checks a raw CI-V frame sent by a client on the virtual serial port or TCP. The from address is set
+// by the client, so it is not used for skipping the checks.
+func (p *txPolicyStruct) checkCIVFrame(f *civFrame, source civSource) error {
+	if len(f.data) == 0 {
+		return nil
+	}
+
+	civControl.state.mutex.Lock()
+	transmitting := civControl.state.ptt || civControl.state.tune
+	split := civControl.state.splitMode == splitModeOn
+	txFreq := civControl.getTxFreq()
+	pwrPercent := civControl.state.pwrPercent
+	civControl.state.mutex.Unlock()
+
+	switch {
+	case f.cmd == 0x1c && f.hasSubCmd && f.subCmd == 0x00 && f.data[0] == 1: // PTT on.
+		if !transmitting {
+			return p.checkTx(source, txFreq, pwrPercent)
+		}
+	case f.cmd == 0x1c && f.hasSubCmd && f.subCmd == 0x01 && f.data[0] == 2: // Tune.
+		if !transmitting {
+			return p.checkTx(source, txFreq, pwrPercent)
+		}
+	case f.cmd == 0x14 && f.hasSubCmd && f.subCmd == 0x0a && len(f.data) >= 2: // RF power.
+		hex := uint16(f.data[0])<<8 | uint16(f.data[1])
+		return p.checkPwr(source, txFreq, int(math.Round((float64(hex)/0x0255)*100)))
+	case f.cmd == 0x00, f.cmd == 0x05: // Frequency of the selected VFO.
+		if transmitting && !split {
+			return p.checkFreq(source, civControl.decodeFreqData(f.data))
+		}
+	case f.cmd == 0x25 && f.hasSubCmd && len(f.data) >= 5: // VFO frequency.
+		if transmitting && (f.subCmd == 0x01) == split {
+			return p.checkFreq(source, civControl.decodeFreqData(f.data[:5]))
+		}
+	}
+	return nil
+}
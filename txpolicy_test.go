@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTxPolicySegments(t *testing.T) {
+	tests := []struct {
+		str  string
+		ok   bool
+		want []txPolicySegment
+	}{
+		{str: "", ok: true},
+		{str: "3500000-3800000", ok: true, want: []txPolicySegment{{3500000, 3800000, 100}}},
+		{str: "3500000-3800000:50, 7000000-7200000", ok: true,
+			want: []txPolicySegment{{3500000, 3800000, 50}, {7000000, 7200000, 100}}},
+		{str: "7000000-7000000:0,", ok: true, want: []txPolicySegment{{7000000, 7000000, 0}}},
+		{str: "3500000"},
+		{str: "3500000-3800000-3900000"},
+		{str: "3800000-3500000"},
+		{str: "3500000-x"},
+		{str: "-3500000-3800000"},
+		{str: "3500000-3800000:101"},
+		{str: "3500000-3800000:-1"},
+		{str: "3500000-3800000:x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.str, func(t *testing.T) {
+			res, err := parseTxPolicySegments(tt.str)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err = %v, want ok = %v", err, tt.ok)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %v, want %v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestTxPolicyCheckTx(t *testing.T) {
+	log.Init()
+	prevTxSegments, prevTxCooldown := txSegments, txCooldown
+	t.Cleanup(func() { txSegments, txCooldown = prevTxSegments, prevTxCooldown })
+
+	var err error
+	txSegments, err = parseTxPolicySegments("3500000-3800000:50,7000000-7200000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	txCooldown = time.Minute
+
+	tests := []struct {
+		name       string
+		freq       uint
+		pwrPercent int
+		lastTxEnd  time.Time
+		ok         bool
+	}{
+		{"inside segment", 7100000, 100, time.Time{}, true},
+		{"segment edge", 3800000, 50, time.Time{}, true},
+		{"outside segments", 14200000, 10, time.Time{}, false},
+		{"below segment", 3499999, 10, time.Time{}, false},
+		{"power above limit", 3600000, 51, time.Time{}, false},
+		{"cool-down", 7100000, 100, time.Now(), false},
+		{"cool-down passed", 7100000, 100, time.Now().Add(-2 * time.Minute), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := txPolicyStruct{lastTxEnd: tt.lastTxEnd}
+			err := p.checkTx(civSourceRigctld, tt.freq, tt.pwrPercent)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err = %v, want ok = %v", err, tt.ok)
+			}
+			if err != nil && !errors.Is(err, errTxBlocked) {
+				t.Errorf("err = %v, want %v", err, errTxBlocked)
+			}
+		})
+	}
+}
+
+func TestCIVControlGetTxFreq(t *testing.T) {
+	tests := []struct {
+		name       string
+		splitMode  splitMode
+		offset     uint
+		xitEnabled bool
+		ritOffset  int
+		want       uint
+	}{
+		{"simplex", splitModeOff, 600000, false, 0, 145500000},
+		{"split", splitModeOn, 600000, false, 0, 145700000},
+		{"dup-", splitModeDUPMinus, 600000, false, 0, 144900000},
+		{"dup+", splitModeDUPPlus, 600000, false, 0, 146100000},
+		{"xit", splitModeOff, 0, true, -1200, 145498800},
+		{"split and xit", splitModeOn, 0, true, 500, 145700500},
+		{"rit offset without xit", splitModeOff, 0, false, 500, 145500000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s civControlStruct
+			s.state.freq = 145500000
+			s.state.subFreq = 145700000
+			s.state.splitMode = tt.splitMode
+			s.state.offset = tt.offset
+			s.state.xitEnabled = tt.xitEnabled
+			s.state.ritOffset = tt.ritOffset
+			if got := s.getTxFreq(); got != tt.want {
+				t.Errorf("getTxFreq() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTxPolicyCheckCIVFrameSpoofedFromAddress(t *testing.T) {
+	log.Init()
+	prevCIVAddress, prevTxSegments := civAddress, txSegments
+	prevFreq := civControl.state.freq
+	t.Cleanup(func() {
+		civAddress, txSegments = prevCIVAddress, prevTxSegments
+		civControl.state.freq = prevFreq
+	})
+	civAddress = testCIVAddress
+
+	var err error
+	txSegments, err = parseTxPolicySegments("7000000-7200000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	civControl.state.freq = 14200000
+
+	// PTT on with the from address set to the radio's address.
+	f, ok := parseCIVFrame([]byte{0xfe, 0xfe, testCIVAddress, testCIVAddress, 0x1c, 0x00, 0x01, 0xfd})
+	if !ok {
+		t.Fatal("can't parse frame")
+	}
+	var p txPolicyStruct
+	if err := p.checkCIVFrame(&f, civSourceTCP); !errors.Is(err, errTxBlocked) {
+		t.Errorf("err = %v, want %v", err, errTxBlocked)
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
-import "strings"
+import (
+	"net"
+	"strings"
+)
 
 // Checks if all bytes are zeros
 func isAllZero(s []byte) bool {
@@ -19,3 +22,12 @@ func parseNullTerminatedString(d []byte) (res string) {
 	}
 	return
 }
+
+// Returns true if the given listen address can only be reached from the local machine.
+func isLoopbackAddress(addr string) bool {
+	if addr == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.IsLoopback()
+}
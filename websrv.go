@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const webSrvClientSendQueueLength = 16
+const webSrvAudioClientSendQueueLength = 64
+const webSrvTxAudioTimeout = 100 * time.Millisecond
+
+type webSrvClient struct {
+	conn     *websocket.Conn
+	msgType  int
+	sendChan chan []byte
+}
+
+type webSrvStruct struct {
+	listener net.Listener
+	srv      *http.Server
+	upgrader websocket.Upgrader
+
+	mutex        sync.Mutex
+	scopeClients map[*webSrvClient]bool
+	audioClients map[*webSrvClient]bool
+}
+
+var webSrv webSrvStruct
+
+type webSrvScopeCmd struct {
+	Enabled *bool    `json:"enabled"`
+	Span    *uint    `json:"span"`
+	Edges   *[2]uint `json:"edges"`
+	Mode    *string  `json:"mode"`
+}
+
+type webSrvSetRequest struct {
+	Value interface{} `json:"value"`
+}
+
+type webSrvResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func (s *webSrvStruct) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debug("can't write http response: ", err)
+	}
+}
+
+func (s *webSrvStruct) writeResult(w http.ResponseWriter, status int, err error) {
+	if err != nil {
+		s.writeJSON(w, status, webSrvResponse{Error: err.Error()})
+		return
+	}
+	s.writeJSON(w, status, webSrvResponse{OK: true})
+}
+
+func (s *webSrvStruct) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeResult(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, getRadioState())
+}
+
+var errWebSrvUnauthorized = errors.New("unauthorized")
+var errWebSrvNotJSON = errors.New("content type must be application/json")
+
+// Returns true if the request has the token set with --http-token, either as a bearer token or as the
+// password of HTTP basic auth (so browsers can ask for it). All requests are authorized if no token is set.
+func (s *webSrvStruct) isAuthorized(r *http.Request) bool {
+	if httpToken == "" {
+		return true
+	}
+
+	var token string
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	} else {
+		var ok bool
+		if _, token, ok = r.BasicAuth(); !ok {
+			return false
+		}
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(httpToken)) == 1
+}
+
+// All endpoints (including the browser UI and the audio feed) need the token.
+func (s *webSrvStruct) requireAuth(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.isAuthorized(r) {
+			log.Debug("unauthorized http request from ", r.RemoteAddr, " to ", r.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Basic realm="kappanhang"`)
+			s.writeResult(w, http.StatusUnauthorized, errWebSrvUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Reads the value to set from a JSON body like {"value":14074000}. Other content types are not accepted,
+// as browsers can send them from other sites without asking (CSRF).
+func (s *webSrvStruct) getSetRequestValue(r *http.Request) (string, error) {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		return "", errWebSrvNotJSON
+	}
+
+	var req webSrvSetRequest
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&req); err != nil {
+		return "", err
+	}
+	if req.Value == nil {
+		return "", fmt.Errorf("missing value")
+	}
+	return fmt.Sprint(req.Value), nil
+}
+
+func (s *webSrvStruct) writeSetRequestError(w http.ResponseWriter, err error) {
+	if err == errWebSrvNotJSON {
+		s.writeResult(w, http.StatusUnsupportedMediaType, err)
+		return
+	}
+	s.writeResult(w, http.StatusBadRequest, err)
+}
+
+func (s *webSrvStruct) handleSet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeResult(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if _, ok := getRadioParamSetter(name); !ok {
+		s.writeResult(w, http.StatusNotFound, errUnknownRadioParam)
+		return
+	}
+
+	v, err := s.getSetRequestValue(r)
+	if err != nil {
+		s.writeSetRequestError(w, err)
+		return
+	}
+
+	log.Debug("http client ", r.RemoteAddr, " sets ", name, " to ", v)
+	if err := setRadioParam(civSourceAPI, name, v); err != nil {
+		s.writeResult(w, http.StatusBadRequest, err)
+		return
+	}
+	s.writeResult(w, http.StatusOK, nil)
+}
+
+func (s *webSrvStruct) writeLoop(c *webSrvClient) {
+	for b := range c.sendChan {
+		if err := c.conn.WriteMessage(c.msgType, b); err != nil {
+			log.Debug("client ", c.conn.RemoteAddr().String(), " write error: ", err)
+			c.conn.Close()
+			for range c.sendChan { // Depleting the channel until the client gets removed.
+			}
+			return
+		}
+	}
+}
+
+func (s *webSrvStruct) handleScopeCmd(cmd webSrvScopeCmd) (err error) {
+	if cmd.Enabled != nil {
+		if err = civControl.setScopeEnabled(civSourceAPI, *cmd.Enabled); err != nil {
+			return
+		}
+	}
+	if cmd.Mode != nil {
+		if err = civControl.setScopeMode(civSourceAPI, *cmd.Mode != "fixed"); err != nil {
+			return
+		}
+	}
+	if cmd.Span != nil {
+		if err = civControl.setScopeSpan(civSourceAPI, *cmd.Span); err != nil {
+			return
+		}
+	}
+	if cmd.Edges != nil {
+		err = civControl.setScopeEdges(civSourceAPI, cmd.Edges[0], cmd.Edges[1])
+	}
+	return
+}
+
+func (s *webSrvStruct) handleScope(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	log.Print("scope client ", conn.RemoteAddr().String(), " connected")
+
+	c := &webSrvClient{
+		conn:     conn,
+		msgType:  websocket.TextMessage,
+		sendChan: make(chan []byte, webSrvClientSendQueueLength),
+	}
+	s.mutex.Lock()
+	s.scopeClients[c] = true
+	s.mutex.Unlock()
+
+	go s.writeLoop(c)
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.scopeClients, c)
+		close(c.sendChan)
+		s.mutex.Unlock()
+
+		conn.Close()
+		log.Print("scope client ", conn.RemoteAddr().String(), " disconnected")
+	}()
+
+	for {
+		var cmd webSrvScopeCmd
+		if err := conn.ReadJSON(&cmd); err != nil {
+			switch err.(type) {
+			case *json.SyntaxError, *json.UnmarshalTypeError:
+				log.Error("invalid scope cmd: ", err)
+				continue
+			}
+			return
+		}
+		if err := s.handleScopeCmd(cmd); err != nil {
+			log.Error("can't handle scope cmd: ", err)
+		}
+	}
+}
+
+func (s *webSrvStruct) handleEvents(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	log.Print("events client ", conn.RemoteAddr().String(), " connected")
+
+	c := &webSrvClient{
+		conn:     conn,
+		msgType:  websocket.TextMessage,
+		sendChan: eventBus.subscribe(),
+	}
+	go s.writeLoop(c)
+
+	defer func() {
+		eventBus.unsubscribe(c.sendChan)
+		conn.Close()
+		log.Print("events client ", conn.RemoteAddr().String(), " disconnected")
+	}()
+
+	// The events feed is read only, we only read to detect when the client goes away.
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// Sends the received audio frame to the radio, waits a little if the audio stream is busy.
+func (s *webSrvStruct) sendTxAudio(b []byte) {
+	if isAllZero(b) || listenOnly { // Do not send silence frames to the radio unnecessarily.
+		return
+	}
+	if !pttArbiter.isAudioTxRouteActive(audioTxRouteWeb) {
+		return
+	}
+	select {
+	case audio.rec <- b:
+	case <-time.After(webSrvTxAudioTimeout):
+		log.Debug("dropping tx audio frame")
+	}
+}
+
+// RX audio is sent to the client in binary messages, and the client can send TX audio the same way.
+// The audio format is signed 16 bit little endian mono PCM with 48kHz sample rate in both directions.
+func (s *webSrvStruct) handleAudio(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	log.Print("audio client ", conn.RemoteAddr().String(), " connected")
+
+	c := &webSrvClient{
+		conn:     conn,
+		msgType:  websocket.BinaryMessage,
+		sendChan: make(chan []byte, webSrvAudioClientSendQueueLength),
+	}
+	s.mutex.Lock()
+	s.audioClients[c] = true
+	s.mutex.Unlock()
+
+	go s.writeLoop(c)
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.audioClients, c)
+		close(c.sendChan)
+		s.mutex.Unlock()
+
+		conn.Close()
+		log.Print("audio client ", conn.RemoteAddr().String(), " disconnected")
+	}()
+
+	txBuf := bytes.NewBuffer([]byte{})
+	for {
+		msgType, d, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if msgType != websocket.BinaryMessage {
+			continue
+		}
+
+		txBuf.Write(d)
+		for txBuf.Len() >= audioFrameSize {
+			// We need to create a new []byte slice for each frame to be able to send it through the rec chan.
+			b := make([]byte, audioFrameSize)
+			_, _ = txBuf.Read(b)
+			s.sendTxAudio(b)
+		}
+	}
+}
+
+func (s *webSrvStruct) broadcastAudio(d []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for c := range s.audioClients {
+		// Non-blocking send, slow clients will miss frames.
+		select {
+		case c.sendChan <- d:
+		default:
+		}
+	}
+}
+
+func (s *webSrvStruct) handleHotkey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeResult(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	v, err := s.getSetRequestValue(r)
+	if err != nil {
+		s.writeSetRequestError(w, err)
+		return
+	}
+	// Quitting the app and redrawing the status bar is not allowed remotely.
+	if len(v) != 1 || v[0] == 'q' || v[0] == '\n' {
+		s.writeResult(w, http.StatusBadRequest, fmt.Errorf("invalid hotkey %q", v))
+		return
+	}
+
+	log.Debug("http client ", r.RemoteAddr, " sends hotkey ", v)
+	handleHotkey(civSourceAPI, v[0])
+	s.writeResult(w, http.StatusOK, nil)
+}
+
+func (s *webSrvStruct) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metrics.write(w)
+}
+
+func (s *webSrvStruct) handleRoot(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		s.handleSet(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeResult(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(webUIHTML))
+}
+
+func (s *webSrvStruct) broadcastScope(f *scopeFrame) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.scopeClients) == 0 {
+		return
+	}
+
+	b, err := json.Marshal(f)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for c := range s.scopeClients {
+		// Non-blocking send, slow clients will miss frames.
+		select {
+		case c.sendChan <- b:
+		default:
+		}
+	}
+}
+
+// The web server is started on startup and kept running between reconnects, so clients won't have issues
+// with the interface going down, and the metrics and the reconnect state are available while the radio is
+// not connected.
+func (s *webSrvStruct) init() (err error) {
+	if httpPort == 0 {
+		return
+	}
+
+	addr := net.JoinHostPort(httpAddress, fmt.Sprint(httpPort))
+	s.listener, err = net.Listen("tcp", addr)
+	if err != nil {
+		return
+	}
+
+	if httpTLSCertFile != "" {
+		log.Print("starting https server on ", addr)
+	} else {
+		log.Print("starting http server on ", addr)
+	}
+
+	s.scopeClients = make(map[*webSrvClient]bool)
+	s.audioClients = make(map[*webSrvClient]bool)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scope", s.handleScope)
+	mux.HandleFunc("/state", s.handleState)
+	mux.HandleFunc("/events", s.handleEvents)
+	mux.HandleFunc("/audio", s.handleAudio)
+	mux.HandleFunc("/hotkey", s.handleHotkey)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/", s.handleRoot)
+	s.srv = &http.Server{Handler: s.requireAuth(mux)}
+
+	go func() {
+		var err error
+		if httpTLSCertFile != "" {
+			err = s.srv.ServeTLS(s.listener, httpTLSCertFile, httpTLSKeyFile)
+		} else {
+			err = s.srv.Serve(s.listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			reportError(err)
+		}
+	}()
+	return
+}
+
+func (s *webSrvStruct) deinit() {
+	if s.srv != nil {
+		s.srv.Close()
+	}
+}
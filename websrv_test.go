@@ -0,0 +1,187 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebSrvRequireAuth(t *testing.T) {
+	log.Init()
+	prevHTTPToken := httpToken
+	httpToken = "secret"
+	t.Cleanup(func() { httpToken = prevHTTPToken })
+
+	var s webSrvStruct
+	h := s.requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		setReq func(r *http.Request)
+		want   int
+	}{
+		{"no token", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, http.StatusOK},
+		{"invalid bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret2") }, http.StatusUnauthorized},
+		{"basic auth", func(r *http.Request) { r.SetBasicAuth("", "secret") }, http.StatusOK},
+		{"invalid basic auth", func(r *http.Request) { r.SetBasicAuth("secret", "") }, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/audio", nil)
+			tt.setReq(r)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSrvGetSetRequestValue(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		ok          bool
+		want        string
+	}{
+		{"json", "application/json", `{"value":14074000}`, true, "14074000"},
+		{"json with charset", "application/json; charset=utf-8", `{"value":"USB"}`, true, "USB"},
+		{"missing value", "application/json", `{}`, false, ""},
+		{"form", "application/x-www-form-urlencoded", "value=14074000", false, ""},
+		{"text", "text/plain", `{"value":14074000}`, false, ""},
+	}
+
+	var s webSrvStruct
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/freq", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			v, err := s.getSetRequestValue(r)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err = %v, want ok = %v", err, tt.ok)
+			}
+			if v != tt.want {
+				t.Errorf("value = %q, want %q", v, tt.want)
+			}
+		})
+	}
+}
+
+// Posts the value to the parameter's endpoint, and returns the status code.
+func testWebSrvSet(t *testing.T, name, value string) int {
+	t.Helper()
+	log.Init()
+
+	var s webSrvStruct
+	r := httptest.NewRequest(http.MethodPost, "/"+name, strings.NewReader(`{"value":`+value+`}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.handleRoot(w, r)
+	if w.Code != http.StatusOK {
+		t.Logf("POST /%s %s: %s", name, value, strings.TrimSpace(w.Body.String()))
+	}
+	return w.Code
+}
+
+func TestWebSrvHandleSet(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{"freq", "14074000", http.StatusOK},
+		{"subFreq", "14074000", http.StatusOK},
+		{"dataMode", "true", http.StatusOK},
+		{"nrenabled", "false", http.StatusOK},
+		{"unknownParam", "1", http.StatusNotFound},
+		{"freq", `"x"`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testWebSrvSet(t, tt.name, tt.value); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSrvSetRepeaterParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{"offset", "600000", http.StatusOK},
+		{"toneMode", `"TSQL"`, http.StatusOK},
+		{"toneMode", `"x"`, http.StatusBadRequest},
+		{"tone", "88.5", http.StatusOK},
+		{"tsqlTone", "88.5", http.StatusOK},
+		{"dcsCode", "23", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testWebSrvSet(t, tt.name, tt.value); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSrvSetRITAndPBTParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{"rit", "-500", http.StatusOK},
+		{"ritEnabled", "true", http.StatusOK},
+		{"xitEnabled", "false", http.StatusOK},
+		{"pbtIn", "200", http.StatusOK},
+		{"pbtOut", "-200", http.StatusOK},
+		{"pbtIn", `"x"`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testWebSrvSet(t, tt.name, tt.value); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSrvSetDSPParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{"att", "0", http.StatusOK},
+		{"att", "7", http.StatusBadRequest},
+		{"nb", "50", http.StatusOK},
+		{"nbEnabled", "true", http.StatusOK},
+		{"anfEnabled", "true", http.StatusOK},
+		{"mnEnabled", "false", http.StatusOK},
+		{"compLevel", "5", http.StatusOK},
+		{"compEnabled", "true", http.StatusOK},
+		{"monGain", "30", http.StatusOK},
+		{"monEnabled", "false", http.StatusOK},
+		{"voxGain", "40", http.StatusOK},
+		{"voxEnabled", "true", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testWebSrvSet(t, tt.name, tt.value); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
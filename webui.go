@@ -0,0 +1,445 @@
+package main
+
+// The single page browser UI served by the HTTP server on /.
+// It uses the /state, /events, /audio and /hotkey endpoints, and the setter endpoints.
+const webUIHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1, user-scalable=no">
+<title>kappanhang</title>
+<style>
+body { background: #111; color: #ddd; font-family: sans-serif; margin: 0; padding: 8px; user-select: none; -webkit-user-select: none; }
+button { background: #333; color: #ddd; border: 1px solid #555; border-radius: 4px; padding: 8px 10px; margin: 2px; font-size: 15px; }
+button.active { background: #2a6; color: #fff; border-color: #3c8; }
+button:disabled { opacity: 0.4; }
+.row { display: flex; flex-wrap: wrap; align-items: center; margin: 6px 0; }
+.box { background: #1b1b1b; border: 1px solid #333; border-radius: 6px; padding: 8px; margin: 4px 0; }
+#conn { font-size: 13px; padding: 2px 6px; border-radius: 3px; background: #622; }
+#conn.up { background: #264; }
+#freq { font-family: monospace; font-size: 44px; color: #7f7; margin-right: 16px; }
+#freq.tx { color: #f55; }
+#info span { margin-right: 12px; }
+#knob { width: 150px; height: 150px; border-radius: 50%; background: radial-gradient(#555, #222); border: 2px solid #666; position: relative; touch-action: none; margin: 8px auto; }
+#knobmark { position: absolute; width: 10px; height: 10px; border-radius: 50%; background: #ddd; left: 70px; top: 10px; }
+.gauge { position: relative; height: 18px; background: #222; border: 1px solid #444; border-radius: 3px; flex: 1; min-width: 150px; margin-left: 8px; }
+.gauge div { height: 100%; background: linear-gradient(90deg, #2a6, #dd3, #d33); }
+.gaugelabel { width: 110px; font-family: monospace; }
+#ptt { width: 100%; height: 70px; font-size: 24px; }
+#ptt.active { background: #c22; border-color: #f44; }
+input[type=range] { flex: 1; min-width: 150px; }
+.levellabel { width: 90px; }
+#log { font-family: monospace; font-size: 12px; height: 80px; overflow-y: auto; color: #999; }
+h3 { margin: 4px 0; font-size: 14px; color: #999; }
+</style>
+</head>
+<body>
+<div class="row">
+ <span id="conn">disconnected</span>
+</div>
+<div class="box">
+ <div class="row"><span id="freq">-</span></div>
+ <div class="row" id="info">
+  <span id="vfo"></span><span id="split"></span><span id="subfreq"></span><span id="ts"></span><span id="pamp"></span><span id="agc"></span><span id="vd"></span>
+ </div>
+</div>
+<div class="box">
+ <div class="row">
+  <button data-key="v">Band -</button><button data-key="b">Band +</button>
+  <button data-key="{">TS -</button><button data-key="}">TS +</button>
+  <button data-key="o">VFO A/B</button><button data-key="s">Split</button>
+ </div>
+ <div id="knob"><div id="knobmark"></div></div>
+</div>
+<div class="box">
+ <h3>Mode</h3>
+ <div class="row" id="modes"></div>
+ <div class="row" id="filters"></div>
+</div>
+<div class="box">
+ <div class="row"><span class="gaugelabel" id="slabel">S</span><div class="gauge"><div id="sgauge" style="width: 0%"></div></div></div>
+ <div class="row"><span class="gaugelabel" id="swrlabel">SWR</span><div class="gauge"><div id="swrgauge" style="width: 0%"></div></div></div>
+</div>
+<div class="box">
+ <button id="ptt">PTT</button>
+ <div class="row">
+  <button id="tune">Tune</button>
+  <button id="rxaudio">RX audio</button>
+  <button id="mic">Browser mic TX</button>
+  <span id="audiomsg"></span>
+ </div>
+</div>
+<div class="box">
+ <div class="row"><span class="levellabel">TX power</span><input type="range" min="0" max="100" id="pwr" data-param="pwr"><span id="pwrval"></span></div>
+ <div class="row"><span class="levellabel">RF gain</span><input type="range" min="0" max="100" id="rfgain" data-param="rfgain"><span id="rfgainval"></span></div>
+ <div class="row"><span class="levellabel">Squelch</span><input type="range" min="0" max="100" id="sql" data-param="sql"><span id="sqlval"></span></div>
+ <div class="row"><span class="levellabel">NR</span><input type="range" min="0" max="100" id="nr" data-param="nr"><span id="nrval"></span></div>
+</div>
+<div class="box">
+ <h3>Hotkeys</h3>
+ <div class="row" id="hotkeys"></div>
+</div>
+<div class="box"><div id="log"></div></div>
+<script>
+"use strict";
+var modes = ["LSB", "USB", "AM", "CW", "RTTY", "FM", "WFM", "CW-R", "RTTY-R", "DV"];
+var filters = ["FIL1", "FIL2", "FIL3"];
+var hotkeys = [
+ ["l", "Server audio monitor"], [" ", "Server mic TX"], ["t", "Tune"],
+ ["-", "Pwr -"], ["+", "Pwr +"], ["[", "Freq -"], ["]", "Freq +"], ["{", "TS -"], ["}", "TS +"],
+ [";", "RFG -"], ["'", "RFG +"], [":", "SQL -"], ["\"", "SQL +"], [",", "NR -"], [".", "NR +"], ["/", "NR on/off"],
+ ["n", "Mode -"], ["m", "Mode +"], ["d", "Filter -"], ["f", "Filter +"], ["D", "Data mode"],
+ ["v", "Band -"], ["b", "Band +"], ["p", "Preamp"], ["a", "AGC"], ["o", "VFO A/B"], ["s", "Split"],
+ ["w", "Scope"], ["<", "Span -"], [">", "Span +"], ["T", "ATU on/off"], ["A", "Antenna"],
+ ["r", "Duplex"], ["E", "Offset"], ["e", "Tone mode"], ["c", "Tone -"], ["C", "Tone +"], ["R", "ARP"],
+ ["i", "RIT"], ["x", "XIT"], ["j", "RIT -"], ["k", "RIT +"], ["Z", "RIT clear"],
+ ["J", "PBT in -"], ["K", "PBT in +"], ["N", "PBT out -"], ["M", "PBT out +"],
+ ["z", "ATT"], ["B", "NB"], ["h", "Auto notch"], ["H", "Manual notch"], ["g", "COMP"], ["G", "Monitor"], ["V", "VOX"]
+];
+
+var state = {};
+var connected = false;
+
+function $(id) { return document.getElementById(id); }
+
+function log(msg) {
+ var l = $("log");
+ var d = document.createElement("div");
+ d.textContent = new Date().toLocaleTimeString() + " " + msg;
+ l.appendChild(d);
+ while (l.childNodes.length > 100) { l.removeChild(l.firstChild); }
+ l.scrollTop = l.scrollHeight;
+}
+
+function wsURL(path) {
+ return (location.protocol === "https:" ? "wss://" : "ws://") + location.host + path;
+}
+
+function post(path, value) {
+ return fetch(path, { method: "POST", headers: { "Content-Type": "application/json" }, body: JSON.stringify({ value: value }) })
+  .then(function (r) { return r.json(); })
+  .then(function (j) { if (!j.ok) { log(path + ": " + j.error); } })
+  .catch(function (e) { log(path + ": " + e); });
+}
+
+function sendHotkey(k) { post("/hotkey", k); }
+
+function formatFreq(f) {
+ if (!f) { return "-"; }
+ var s = String(f);
+ while (s.length < 7) { s = "0" + s; }
+ var hz = s.slice(-3), khz = s.slice(-6, -3), mhz = s.slice(0, -6);
+ return mhz + "." + khz + "." + hz;
+}
+
+function sToValue(s) {
+ if (!s) { return 0; }
+ var m = /^S(\d+)(\+(\d+))?/.exec(s);
+ if (!m) { return 0; }
+ var v = parseInt(m[1], 10);
+ if (m[3]) { v += parseInt(m[3], 10) / 10; }
+ return v;
+}
+
+function render() {
+ $("conn").textContent = connected ? "connected" : "disconnected";
+ $("conn").className = connected ? "up" : "";
+ $("freq").textContent = formatFreq(state.freq);
+ $("freq").className = (state.ptt || state.tune) ? "tx" : "";
+ $("vfo").textContent = "VFO " + (state.vfo || "A");
+ $("split").textContent = (state.split && state.split !== "off") ? "SPLIT " + state.split : "";
+ $("subfreq").textContent = state.split === "on" ? "TX " + formatFreq(state.subFreq) : "";
+ $("ts").textContent = state.ts ? "TS " + state.ts + " Hz" : "";
+ $("pamp").textContent = "PAMP" + (state.preamp || 0);
+ $("agc").textContent = state.agc ? "AGC" + state.agc : "";
+ $("vd").textContent = state.vd ? state.vd.toFixed(1) + "V" : "";
+
+ var i, b;
+ var mb = $("modes").childNodes;
+ for (i = 0; i < mb.length; i++) { mb[i].className = mb[i].dataset.mode === state.mode ? "active" : ""; }
+ var fb = $("filters").childNodes;
+ for (i = 0; i < fb.length; i++) {
+  b = fb[i];
+  if (b.dataset.filter) { b.className = b.dataset.filter === state.filter ? "active" : ""; }
+  else { b.className = state.dataMode ? "active" : ""; }
+ }
+
+ var sv = sToValue(state.s);
+ $("slabel").textContent = (state.ptt || state.tune) ? ("TX " + (state.po || 0).toFixed(1) + "W") :
+  ((state.s || "S0") + (state.ovf ? " OVF" : "") + (state.sDBm ? " " + state.sDBm.toFixed(0) + "dBm" : ""));
+ $("sgauge").style.width = (state.ptt || state.tune ? 0 : Math.min(100, sv / 15 * 100)) + "%";
+ var swr = state.swr || 0;
+ $("swrlabel").textContent = "SWR " + (swr ? swr.toFixed(1) : "-");
+ $("swrgauge").style.width = (swr > 1 ? Math.min(100, (swr - 1) / 2 * 100) : 0) + "%";
+
+ $("ptt").className = state.ptt ? "active" : "";
+ $("tune").className = state.tune ? "active" : "";
+
+ var levels = { pwr: "pwr", rfgain: "rfGain", sql: "sql", nr: "nr" };
+ for (var id in levels) {
+  var v = state[levels[id]];
+  if (v === undefined) { continue; }
+  if (!$(id).dragging) { $(id).value = v; }
+  $(id + "val").textContent = v + "%";
+ }
+ if (state.nrEnabled === false) { $("nrval").textContent += " (off)"; }
+}
+
+function connectEvents() {
+ var ws = new WebSocket(wsURL("/events"));
+ ws.onmessage = function (e) {
+  var m = JSON.parse(e.data);
+  if (m.type === "state") {
+   for (var k in m.state) { state[k] = m.state[k]; }
+  } else if (m.type === "event") {
+   if (m.event === "connected") { connected = true; }
+   if (m.event === "disconnected") { connected = false; }
+   log(m.event + (m.data ? " " + JSON.stringify(m.data) : ""));
+  }
+  render();
+ };
+ ws.onopen = function () {
+  fetch("/state").then(function (r) { return r.json(); }).then(function (s) {
+   for (var k in s) { if (state[k] === undefined) { state[k] = s[k]; } }
+   connected = !!s.freq;
+   render();
+  });
+ };
+ ws.onclose = function () {
+  connected = false;
+  render();
+  setTimeout(connectEvents, 2000);
+ };
+}
+
+// Frequency knob: a full turn is 36 tuning steps.
+var knob = { active: false, angle: 0, rot: 0, steps: 0, pendingFreq: 0 };
+
+function knobAngle(e) {
+ var r = $("knob").getBoundingClientRect();
+ return Math.atan2(e.clientY - (r.top + r.height / 2), e.clientX - (r.left + r.width / 2)) * 180 / Math.PI;
+}
+
+function knobTurn(deg) {
+ knob.rot += deg;
+ $("knob").style.transform = "rotate(" + knob.rot + "deg)";
+ knob.acc = (knob.acc || 0) + deg;
+ while (knob.acc >= 10) { knob.steps++; knob.acc -= 10; }
+ while (knob.acc <= -10) { knob.steps--; knob.acc += 10; }
+}
+
+function knobFlush() {
+ if (knob.steps === 0 || !state.freq) { return; }
+ var ts = state.ts || 1000;
+ var base = knob.pendingFreq || state.freq;
+ var f = Math.round(base / ts) * ts + knob.steps * ts;
+ knob.steps = 0;
+ knob.pendingFreq = f;
+ state.freq = f;
+ render();
+ post("/freq", f).then(function () { knob.pendingFreq = 0; });
+}
+
+function initKnob() {
+ var k = $("knob");
+ k.addEventListener("pointerdown", function (e) { knob.active = true; knob.angle = knobAngle(e); k.setPointerCapture(e.pointerId); });
+ k.addEventListener("pointermove", function (e) {
+  if (!knob.active) { return; }
+  var a = knobAngle(e);
+  var d = a - knob.angle;
+  if (d > 180) { d -= 360; }
+  if (d < -180) { d += 360; }
+  knob.angle = a;
+  knobTurn(d);
+ });
+ k.addEventListener("pointerup", function () { knob.active = false; });
+ k.addEventListener("pointercancel", function () { knob.active = false; });
+ k.addEventListener("wheel", function (e) { e.preventDefault(); knobTurn(e.deltaY < 0 ? 10 : -10); });
+ setInterval(knobFlush, 100);
+}
+
+// Audio handling.
+var audioCtx = null;
+var audioWS = null;
+var playTime = 0;
+var mic = { enabled: false, stream: null, src: null, node: null, tx: false };
+
+function getAudioCtx() {
+ if (!audioCtx) { audioCtx = new (window.AudioContext || window.webkitAudioContext)(); }
+ if (audioCtx.state === "suspended") { audioCtx.resume(); }
+ return audioCtx;
+}
+
+function playRx(ab) {
+ if (!$("rxaudio").classList.contains("active")) { return; }
+ var pcm = new Int16Array(ab);
+ if (!pcm.length) { return; }
+ var ctx = getAudioCtx();
+ var buf = ctx.createBuffer(1, pcm.length, 48000);
+ var ch = buf.getChannelData(0);
+ for (var i = 0; i < pcm.length; i++) { ch[i] = pcm[i] / 32768; }
+ var src = ctx.createBufferSource();
+ src.buffer = buf;
+ src.connect(ctx.destination);
+ var now = ctx.currentTime;
+ if (playTime < now + 0.02 || playTime > now + 0.5) { playTime = now + 0.1; }
+ src.start(playTime);
+ playTime += buf.duration;
+}
+
+function openAudioWS() {
+ if (audioWS) { return; }
+ audioWS = new WebSocket(wsURL("/audio"));
+ audioWS.binaryType = "arraybuffer";
+ audioWS.onmessage = function (e) { playRx(e.data); };
+ audioWS.onclose = function () {
+  audioWS = null;
+  if ($("rxaudio").classList.contains("active") || mic.enabled) { setTimeout(openAudioWS, 2000); }
+ };
+}
+
+function closeAudioWSIfUnused() {
+ if (audioWS && !$("rxaudio").classList.contains("active") && !mic.enabled) {
+  audioWS.close();
+  audioWS = null;
+ }
+}
+
+function toggleRxAudio() {
+ var b = $("rxaudio");
+ if (b.classList.contains("active")) {
+  b.classList.remove("active");
+  closeAudioWSIfUnused();
+ } else {
+  getAudioCtx();
+  b.classList.add("active");
+  openAudioWS();
+ }
+}
+
+// Converts the captured float samples to 16 bit 48kHz PCM.
+function micToPCM(inp, rate) {
+ var n = Math.floor(inp.length * 48000 / rate);
+ var out = new Int16Array(n);
+ for (var i = 0; i < n; i++) {
+  var pos = i * rate / 48000;
+  var j = Math.floor(pos);
+  var f = pos - j;
+  var v = inp[j] * (1 - f) + (j + 1 < inp.length ? inp[j + 1] : inp[j]) * f;
+  v = Math.max(-1, Math.min(1, v));
+  out[i] = v * 32767;
+ }
+ return out;
+}
+
+function toggleMic() {
+ if (mic.enabled) {
+  mic.enabled = false;
+  $("mic").className = "";
+  if (mic.node) { mic.node.disconnect(); mic.src.disconnect(); }
+  if (mic.stream) { mic.stream.getTracks().forEach(function (t) { t.stop(); }); }
+  mic.stream = mic.src = mic.node = null;
+  closeAudioWSIfUnused();
+  return;
+ }
+ if (!navigator.mediaDevices || !navigator.mediaDevices.getUserMedia) {
+  $("audiomsg").textContent = "mic access needs HTTPS (see --http-tls-cert)";
+  return;
+ }
+ var ctx = getAudioCtx();
+ navigator.mediaDevices.getUserMedia({ audio: { echoCancellation: false, noiseSuppression: false, autoGainControl: false } })
+  .then(function (stream) {
+   mic.stream = stream;
+   mic.src = ctx.createMediaStreamSource(stream);
+   mic.node = ctx.createScriptProcessor(2048, 1, 1);
+   mic.node.onaudioprocess = function (e) {
+    if (!mic.tx || !audioWS || audioWS.readyState !== 1) { return; }
+    audioWS.send(micToPCM(e.inputBuffer.getChannelData(0), ctx.sampleRate).buffer);
+   };
+   mic.src.connect(mic.node);
+   mic.node.connect(ctx.destination);
+   mic.enabled = true;
+   $("mic").className = "active";
+   $("audiomsg").textContent = "";
+   openAudioWS();
+  })
+  .catch(function (e) { $("audiomsg").textContent = "can't open mic: " + e; });
+}
+
+function pttOn() {
+ if (state.ptt) { return; }
+ mic.tx = mic.enabled;
+ post("/ptt", true);
+}
+
+function pttOff() {
+ mic.tx = false;
+ post("/ptt", false);
+}
+
+function initButtons() {
+ var i, b;
+ modes.forEach(function (m) {
+  b = document.createElement("button");
+  b.textContent = m;
+  b.dataset.mode = m;
+  b.onclick = function () { post("/mode", m); };
+  $("modes").appendChild(b);
+ });
+ filters.forEach(function (f) {
+  b = document.createElement("button");
+  b.textContent = f;
+  b.dataset.filter = f;
+  b.onclick = function () { post("/filter", f); };
+  $("filters").appendChild(b);
+ });
+ b = document.createElement("button");
+ b.textContent = "DATA";
+ b.onclick = function () { post("/datamode", !state.dataMode); };
+ $("filters").appendChild(b);
+
+ hotkeys.forEach(function (h) {
+  b = document.createElement("button");
+  b.textContent = h[1];
+  b.title = "hotkey: " + (h[0] === " " ? "space" : h[0]);
+  b.dataset.key = h[0];
+  $("hotkeys").appendChild(b);
+ });
+ var kb = document.querySelectorAll("button[data-key]");
+ for (i = 0; i < kb.length; i++) {
+  (function (b) { b.onclick = function () { sendHotkey(b.dataset.key); }; })(kb[i]);
+ }
+
+ var p = $("ptt");
+ p.addEventListener("pointerdown", function (e) { e.preventDefault(); p.setPointerCapture(e.pointerId); pttOn(); });
+ p.addEventListener("pointerup", pttOff);
+ p.addEventListener("pointercancel", pttOff);
+ $("tune").onclick = function () { post("/tune", !state.tune); };
+ $("rxaudio").onclick = toggleRxAudio;
+ $("mic").onclick = toggleMic;
+
+ var ranges = document.querySelectorAll("input[data-param]");
+ for (i = 0; i < ranges.length; i++) {
+  (function (r) {
+   r.addEventListener("pointerdown", function () { r.dragging = true; });
+   r.addEventListener("pointerup", function () { r.dragging = false; });
+   r.addEventListener("change", function () { r.dragging = false; post("/" + r.dataset.param, parseInt(r.value, 10)); });
+  })(ranges[i]);
+ }
+
+ // Keyboard hotkeys are the same as in the terminal.
+ document.addEventListener("keydown", function (e) {
+  if (e.ctrlKey || e.altKey || e.metaKey || e.key.length !== 1 || e.key === "q") { return; }
+  e.preventDefault();
+  sendHotkey(e.key);
+ });
+}
+
+initButtons();
+initKnob();
+render();
+connectEvents();
+</script>
+</body>
+</html>
+`